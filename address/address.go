@@ -0,0 +1,325 @@
+// Package address splits the formatted addresses of the Places API into street, city, region, postal code and country
+// without calling any API. It knows the address formats of the United States, Canada, the United Kingdom, Ireland and
+// continental Europe; other addresses are split on a best effort basis with a lower confidence.
+package address
+
+import (
+	"github.com/kardolus/maps/types"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// style is the address format of a country
+type style string
+
+const (
+	styleUS style = "US" // Columbus, OH 43221
+	styleCA style = "CA" // Toronto, ON M5R 3R9
+	styleGB style = "GB" // London SW1A 2AA
+	styleIE style = "IE" // Dublin 1, D01 F5P2
+	styleEU style = "EU" // 10117 Berlin
+	styleIT style = "IT" // 00184 Roma RM
+)
+
+// weights of the parts in the confidence; the region only counts for the formats that have one
+const (
+	weightStreet  = 0.2
+	weightCity    = 0.25
+	weightRegion  = 0.1
+	weightPostal  = 0.25
+	weightCountry = 0.2
+)
+
+var countries = map[string]style{
+	"united states": styleUS, "united states of america": styleUS, "usa": styleUS, "us": styleUS,
+	"canada":         styleCA,
+	"united kingdom": styleGB, "uk": styleGB, "great britain": styleGB, "england": styleGB, "scotland": styleGB,
+	"wales": styleGB, "northern ireland": styleGB,
+	"ireland": styleIE, "éire": styleIE,
+	"italy": styleIT, "italia": styleIT,
+	"germany": styleEU, "deutschland": styleEU, "france": styleEU, "netherlands": styleEU, "the netherlands": styleEU,
+	"nederland": styleEU, "spain": styleEU, "españa": styleEU, "portugal": styleEU, "belgium": styleEU,
+	"belgië": styleEU, "belgique": styleEU, "luxembourg": styleEU, "austria": styleEU, "österreich": styleEU,
+	"switzerland": styleEU, "schweiz": styleEU, "suisse": styleEU, "svizzera": styleEU, "denmark": styleEU,
+	"danmark": styleEU, "sweden": styleEU, "sverige": styleEU, "norway": styleEU, "norge": styleEU,
+	"finland": styleEU, "suomi": styleEU, "poland": styleEU, "polska": styleEU, "czechia": styleEU,
+	"czech republic": styleEU, "slovakia": styleEU, "hungary": styleEU, "greece": styleEU, "slovenia": styleEU,
+	"croatia": styleEU, "estonia": styleEU, "latvia": styleEU, "lithuania": styleEU, "iceland": styleEU,
+}
+
+var usStates = set("AL AK AZ AR CA CO CT DE DC FL GA HI ID IL IN IA KS KY LA ME MD MA MI MN MS MO MT NE NV NH NJ NM " +
+	"NY NC ND OH OK OR PA RI SC SD TN TX UT VT VA WA WV WI WY PR VI GU AS MP")
+
+var caProvinces = set("AB BC MB NB NL NS NT NU ON PE QC SK YT")
+
+// regionNames maps the names of the US states and Canadian provinces to their codes
+var regionNames = map[string]string{
+	"alabama": "AL", "alaska": "AK", "arizona": "AZ", "arkansas": "AR", "california": "CA", "colorado": "CO",
+	"connecticut": "CT", "delaware": "DE", "district of columbia": "DC", "florida": "FL", "georgia": "GA",
+	"hawaii": "HI", "idaho": "ID", "illinois": "IL", "indiana": "IN", "iowa": "IA", "kansas": "KS", "kentucky": "KY",
+	"louisiana": "LA", "maine": "ME", "maryland": "MD", "massachusetts": "MA", "michigan": "MI", "minnesota": "MN",
+	"mississippi": "MS", "missouri": "MO", "montana": "MT", "nebraska": "NE", "nevada": "NV", "new hampshire": "NH",
+	"new jersey": "NJ", "new mexico": "NM", "new york": "NY", "north carolina": "NC", "north dakota": "ND",
+	"ohio": "OH", "oklahoma": "OK", "oregon": "OR", "pennsylvania": "PA", "rhode island": "RI",
+	"south carolina": "SC", "south dakota": "SD", "tennessee": "TN", "texas": "TX", "utah": "UT", "vermont": "VT",
+	"virginia": "VA", "washington": "WA", "west virginia": "WV", "wisconsin": "WI", "wyoming": "WY", "puerto rico": "PR",
+	"alberta": "AB", "british columbia": "BC", "manitoba": "MB", "new brunswick": "NB",
+	"newfoundland and labrador": "NL", "nova scotia": "NS", "northwest territories": "NT", "nunavut": "NU",
+	"ontario": "ON", "prince edward island": "PE", "quebec": "QC", "québec": "QC", "saskatchewan": "SK",
+	"yukon": "YT",
+}
+
+var (
+	usPattern = regexp.MustCompile(`^(?:(.*?)\s+)?([A-Z]{2})(?:\s+(\d{5}(?:-\d{4})?))?$`)
+	caPattern = regexp.MustCompile(`^(?:(.*?)\s+)?([A-Z]{2})(?:\s+([A-Z]\d[A-Z])\s?(\d[A-Z]\d))?$`)
+	gbPattern = regexp.MustCompile(`^(?:(.*?)\s+)?([A-Z]{1,2}\d[A-Z\d]?)\s?(\d[A-Z]{2})$`)
+	iePattern = regexp.MustCompile(`^([AC-FHKNPRTV-Y]\d{2}|D6W)\s?([0-9AC-FHKNPRTV-Y]{4})$`)
+	euPattern = regexp.MustCompile(`^((?:[A-Z]{1,2}-)?(?:\d{4}\s?[A-Z]{2}|\d{3}\s\d{2}|\d{2}-\d{3}|\d{4}-\d{3}|\d{4,5}))\s+(.+)$`)
+	itPattern = regexp.MustCompile(`^(.+?)\s+([A-Z]{2})$`)
+)
+
+// match is what a postal format found in the last part of an address
+type match struct {
+	style  style
+	city   string
+	region string
+	postal string
+}
+
+// Parse splits a formatted address, like "1555 W Lane Ave, Columbus, OH 43221, United States", into its parts. Parts
+// are kept as written: an address without a country gets no country, even when its format gives it away.
+func Parse(formatted string) types.Address {
+	var result types.Address
+
+	parts := split(formatted)
+	if len(parts) == 0 {
+		return result
+	}
+
+	countryStyle, countryKnown := style(""), false
+	if s, ok := countries[strings.ToLower(parts[len(parts)-1])]; ok {
+		result.Country, countryStyle, countryKnown = parts[len(parts)-1], s, true
+		parts = parts[:len(parts)-1]
+	} else if _, ok := find(parts, ""); !ok && len(parts) >= 3 && !hasDigit(parts[len(parts)-1]) {
+		result.Country = parts[len(parts)-1] // a country this parser does not know
+		parts = parts[:len(parts)-1]
+	}
+
+	found, ok := find(parts, countryStyle)
+	if !ok {
+		fallback(&result, parts)
+		result.Confidence = confidence(result, "", countryKnown, 0.5)
+		return result
+	}
+
+	last := len(parts) - 1
+	result.PostalCode, result.Region = found.postal, found.region
+
+	cityAt := last
+	if found.city != "" {
+		result.City = found.city
+	} else if last > 0 {
+		cityAt = last - 1
+		result.City = parts[cityAt]
+	}
+	result.Street = strings.Join(parts[:cityAt], ", ")
+
+	agreement := 1.0
+	if countryKnown && !compatible(countryStyle, found.style) {
+		agreement = 0.6
+	}
+
+	result.Confidence = confidence(result, found.style, countryKnown, agreement)
+	return result
+}
+
+// ParseAll returns copies of the locations with their formatted address parsed
+func ParseAll(locations []types.Location) []types.Location {
+	result := make([]types.Location, len(locations))
+	for i, location := range locations {
+		parsed := Parse(location.FormattedAddress)
+		location.Address = &parsed
+		result[i] = location
+	}
+	return result
+}
+
+// RegionCode returns the code of a US state or Canadian province given by name or code, like OH for Ohio, and any
+// other region as is
+func RegionCode(region string) string {
+	region = strings.TrimSpace(region)
+	if code, ok := regionNames[strings.ToLower(region)]; ok {
+		return code
+	}
+	if upper := strings.ToUpper(region); usStates[upper] || caProvinces[upper] {
+		return upper
+	}
+	return region
+}
+
+// find matches the last part of the address against the postal formats, those of the country first
+func find(parts []string, country style) (match, bool) {
+	if len(parts) == 0 {
+		return match{}, false
+	}
+
+	last := parts[len(parts)-1]
+	matchers := []func(string) (match, bool){matchUS, matchCA, matchGB, matchIE}
+
+	// a number followed by a word is also a street, so the European format needs a country or a street before it
+	if compatible(country, styleEU) || len(parts) > 1 {
+		matchers = append(matchers, func(part string) (match, bool) { return matchEU(part, country == styleIT) })
+	}
+
+	for _, preferred := range []bool{true, false} {
+		for _, matcher := range matchers {
+			m, ok := matcher(last)
+			if ok && (country == "" || compatible(country, m.style) == preferred) {
+				return m, true
+			}
+		}
+	}
+
+	return match{}, false
+}
+
+func matchUS(part string) (match, bool) {
+	m := usPattern.FindStringSubmatch(part)
+	if m == nil || !usStates[m[2]] {
+		return match{}, false
+	}
+	return match{style: styleUS, city: m[1], region: m[2], postal: m[3]}, true
+}
+
+func matchCA(part string) (match, bool) {
+	m := caPattern.FindStringSubmatch(part)
+	if m == nil || !caProvinces[m[2]] {
+		return match{}, false
+	}
+	result := match{style: styleCA, city: m[1], region: m[2]}
+	if m[3] != "" {
+		result.postal = m[3] + " " + m[4]
+	}
+	return result, true
+}
+
+func matchGB(part string) (match, bool) {
+	m := gbPattern.FindStringSubmatch(part)
+	if m == nil {
+		return match{}, false
+	}
+	return match{style: styleGB, city: m[1], postal: m[2] + " " + m[3]}, true
+}
+
+func matchIE(part string) (match, bool) {
+	m := iePattern.FindStringSubmatch(part)
+	if m == nil {
+		return match{}, false
+	}
+	return match{style: styleIE, postal: m[1] + " " + m[2]}, true
+}
+
+// matchEU reads a postal code followed by the city. Italian addresses add the province after the city.
+func matchEU(part string, italian bool) (match, bool) {
+	m := euPattern.FindStringSubmatch(part)
+	if m == nil || hasDigit(m[2]) {
+		return match{}, false
+	}
+
+	result := match{style: styleEU, postal: m[1], city: m[2]}
+	if province := itPattern.FindStringSubmatch(m[2]); province != nil && (italian || len(m[1]) == 5) {
+		result.style, result.city, result.region = styleIT, province[1], province[2]
+	}
+
+	return result, true
+}
+
+// fallback splits an address no postal format matched: the last part is the city, unless it looks like a street
+func fallback(result *types.Address, parts []string) {
+	switch {
+	case len(parts) == 0:
+	case len(parts) == 1 && hasDigit(parts[0]):
+		result.Street = parts[0]
+	default:
+		result.City = stripDigits(parts[len(parts)-1])
+		result.Street = strings.Join(parts[:len(parts)-1], ", ")
+	}
+}
+
+// confidence weighs the parts that were found against the parts the format has. A country the parser does not know
+// counts half, and the agreement lowers the score of formats that do not fit the country or were guessed.
+func confidence(a types.Address, format style, countryKnown bool, agreement float64) float64 {
+	var score, total float64
+
+	add := func(value string, weight float64) {
+		total += weight
+		if value != "" {
+			score += weight
+		}
+	}
+
+	add(a.Street, weightStreet)
+	add(a.City, weightCity)
+	add(a.PostalCode, weightPostal)
+	if format == styleUS || format == styleCA || format == styleIT {
+		add(a.Region, weightRegion)
+	}
+
+	total += weightCountry
+	switch {
+	case countryKnown:
+		score += weightCountry
+	case a.Country != "":
+		score += weightCountry / 2
+	}
+
+	return math.Round(score/total*agreement*100) / 100
+}
+
+// compatible reports whether addresses of a country can use the format. Italy uses the European format, with or
+// without a province.
+func compatible(country, format style) bool {
+	switch {
+	case country == format:
+		return true
+	case country == styleIT:
+		return format == styleEU
+	case country == styleEU:
+		return format == styleIT
+	}
+	return false
+}
+
+func split(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+func stripDigits(value string) string {
+	var words []string
+	for _, word := range strings.Fields(value) {
+		if !hasDigit(word) {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func hasDigit(value string) bool {
+	return strings.IndexFunc(value, unicode.IsDigit) >= 0
+}
+
+func set(values string) map[string]bool {
+	result := make(map[string]bool)
+	for _, value := range strings.Fields(values) {
+		result[value] = true
+	}
+	return result
+}
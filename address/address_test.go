@@ -0,0 +1,190 @@
+package address_test
+
+import (
+	"testing"
+
+	"github.com/kardolus/maps/address"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitAddress(t *testing.T) {
+	spec.Run(t, "Address Unit Tests", testAddress, spec.Report(report.Terminal{}))
+}
+
+type parts struct {
+	street, city, region, postal, country string
+}
+
+func testAddress(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	check := func(corpus map[string]parts) {
+		for formatted, expected := range corpus {
+			parsed := address.Parse(formatted)
+			Expect(parts{parsed.Street, parsed.City, parsed.Region, parsed.PostalCode, parsed.Country}).To(Equal(expected), formatted)
+		}
+	}
+
+	when("parsing addresses in the United States", func() {
+		it("splits the common shapes", func() {
+			check(map[string]parts{
+				"1555 W Lane Ave, Columbus, OH 43221, United States":        {"1555 W Lane Ave", "Columbus", "OH", "43221", "United States"},
+				"13998 Cedar Rd, South Euclid, OH 44118, USA":               {"13998 Cedar Rd", "South Euclid", "OH", "44118", "USA"},
+				"3135 Kingsdale Center, Upper Arlington, OH 43221, US":      {"3135 Kingsdale Center", "Upper Arlington", "OH", "43221", "US"},
+				"1 Apple Park Way, Cupertino, CA 95014-0642, United States": {"1 Apple Park Way", "Cupertino", "CA", "95014-0642", "United States"},
+				"350 5th Ave, New York, NY 10118":                           {"350 5th Ave", "New York", "NY", "10118", ""},
+				"500 W 2nd St Suite 1900, Austin, TX 78701, United States":  {"500 W 2nd St Suite 1900", "Austin", "TX", "78701", "United States"},
+				"Kingsdale Shopping Center, 3135 Tremont Rd, Upper Arlington, OH 43221, United States": {
+					"Kingsdale Shopping Center, 3135 Tremont Rd", "Upper Arlington", "OH", "43221", "United States"},
+				"1600 Pennsylvania Avenue NW, Washington, DC 20500, United States": {"1600 Pennsylvania Avenue NW", "Washington", "DC", "20500", "United States"},
+				"Columbus, OH, USA":                                   {"", "Columbus", "OH", "", "USA"},
+				"Columbus, OH 43215":                                  {"", "Columbus", "OH", "43215", ""},
+				"Columbus OH 43215":                                   {"", "Columbus", "OH", "43215", ""},
+				"Columbus, OH":                                        {"", "Columbus", "OH", "", ""},
+				"OH 43215, United States":                             {"", "", "OH", "43215", "United States"},
+				"PO Box 123, Honolulu, HI 96801, United States":       {"PO Box 123", "Honolulu", "HI", "96801", "United States"},
+				"1050 Ashford Ave, San Juan, PR 00907, United States": {"1050 Ashford Ave", "San Juan", "PR", "00907", "United States"},
+				"200 E Randolph St, Chicago, IL 60601, United States of America": {
+					"200 E Randolph St", "Chicago", "IL", "60601", "United States of America"},
+			})
+		})
+	})
+
+	when("parsing addresses in Canada", func() {
+		it("splits the common shapes", func() {
+			check(map[string]parts{
+				"87 Avenue Rd, Toronto, ON M5R 3R9, Canada":                 {"87 Avenue Rd", "Toronto", "ON", "M5R 3R9", "Canada"},
+				"1675 Robson St, Vancouver, BC V6G 1C8, Canada":             {"1675 Robson St", "Vancouver", "BC", "V6G 1C8", "Canada"},
+				"1234 Rue Sainte-Catherine O, Montréal, QC H3G 1P1, Canada": {"1234 Rue Sainte-Catherine O", "Montréal", "QC", "H3G 1P1", "Canada"},
+				"10 Main St, Halifax, NS B3H1A1":                            {"10 Main St", "Halifax", "NS", "B3H 1A1", ""},
+				"Calgary, AB T2P 1J9, Canada":                               {"", "Calgary", "AB", "T2P 1J9", "Canada"},
+				"Unit 5, 2500 Bridgeland Dr, Ottawa, ON K2B 7X7, Canada":    {"Unit 5, 2500 Bridgeland Dr", "Ottawa", "ON", "K2B 7X7", "Canada"},
+				"Winnipeg, MB, Canada":                                      {"", "Winnipeg", "MB", "", "Canada"},
+			})
+		})
+	})
+
+	when("parsing addresses in the United Kingdom and Ireland", func() {
+		it("splits the common shapes", func() {
+			check(map[string]parts{
+				"10 Downing St, London SW1A 2AA, United Kingdom":                  {"10 Downing St", "London", "", "SW1A 2AA", "United Kingdom"},
+				"221B Baker St, Marylebone, London NW1 6XE, UK":                   {"221B Baker St, Marylebone", "London", "", "NW1 6XE", "UK"},
+				"High St, Oxford OX1 4AA, United Kingdom":                         {"High St", "Oxford", "", "OX1 4AA", "United Kingdom"},
+				"63-97 Kensington High St, London W8 5SE, United Kingdom":         {"63-97 Kensington High St", "London", "", "W8 5SE", "United Kingdom"},
+				"Princes St, Edinburgh EH2 2EN, Scotland":                         {"Princes St", "Edinburgh", "", "EH2 2EN", "Scotland"},
+				"1 Deansgate, Manchester M3 1AZ":                                  {"1 Deansgate", "Manchester", "", "M3 1AZ", ""},
+				"Cardiff CF10 1AA, UK":                                            {"", "Cardiff", "", "CF10 1AA", "UK"},
+				"Unit 4, Retail Park, Birmingham, B5 4BU, United Kingdom":         {"Unit 4, Retail Park", "Birmingham", "", "B5 4BU", "United Kingdom"},
+				"O'Connell Street Upper, North City, Dublin 1, D01 F5P2, Ireland": {"O'Connell Street Upper, North City", "Dublin 1", "", "D01 F5P2", "Ireland"},
+				"Grand Parade, Centre, Cork, T12 X70A, Ireland":                   {"Grand Parade, Centre", "Cork", "", "T12 X70A", "Ireland"},
+				"Stillorgan Rd, Dublin, D6W XY12, Ireland":                        {"Stillorgan Rd", "Dublin", "", "D6W XY12", "Ireland"},
+			})
+		})
+	})
+
+	when("parsing addresses in continental Europe", func() {
+		it("splits the common shapes", func() {
+			check(map[string]parts{
+				"Unter den Linden 77, 10117 Berlin, Germany":                {"Unter den Linden 77", "Berlin", "", "10117", "Germany"},
+				"Marienplatz 8, 80331 München, Deutschland":                 {"Marienplatz 8", "München", "", "80331", "Deutschland"},
+				"5 Av. Anatole France, 75007 Paris, France":                 {"5 Av. Anatole France", "Paris", "", "75007", "France"},
+				"Dam 1, 1012 JS Amsterdam, Netherlands":                     {"Dam 1", "Amsterdam", "", "1012 JS", "Netherlands"},
+				"Coolsingel 40, 3011AD Rotterdam, Nederland":                {"Coolsingel 40", "Rotterdam", "", "3011AD", "Nederland"},
+				"C. de Alcalá, 42, 28014 Madrid, Spain":                     {"C. de Alcalá, 42", "Madrid", "", "28014", "Spain"},
+				"Piazza del Colosseo, 1, 00184 Roma RM, Italy":              {"Piazza del Colosseo, 1", "Roma", "RM", "00184", "Italy"},
+				"Via Dante, 12, 20121 Milano MI, Italia":                    {"Via Dante, 12", "Milano", "MI", "20121", "Italia"},
+				"Piazza San Marco, 30124 Venezia, Italy":                    {"Piazza San Marco", "Venezia", "", "30124", "Italy"},
+				"R. Augusta 1, 1100-048 Lisboa, Portugal":                   {"R. Augusta 1", "Lisboa", "", "1100-048", "Portugal"},
+				"Marszałkowska 1, 00-001 Warszawa, Poland":                  {"Marszałkowska 1", "Warszawa", "", "00-001", "Poland"},
+				"Drottninggatan 1, 111 51 Stockholm, Sweden":                {"Drottninggatan 1", "Stockholm", "", "111 51", "Sweden"},
+				"Grand Place 1, 1000 Bruxelles, Belgium":                    {"Grand Place 1", "Bruxelles", "", "1000", "Belgium"},
+				"Stephansplatz 1, 1010 Wien, Austria":                       {"Stephansplatz 1", "Wien", "", "1010", "Austria"},
+				"Bahnhofstrasse 1, 8001 Zürich, Switzerland":                {"Bahnhofstrasse 1", "Zürich", "", "8001", "Switzerland"},
+				"Strøget 1, 1160 København, Denmark":                        {"Strøget 1", "København", "", "1160", "Denmark"},
+				"Karl Johans gate 1, 0154 Oslo, Norway":                     {"Karl Johans gate 1", "Oslo", "", "0154", "Norway"},
+				"Mannerheimintie 1, 00100 Helsinki, Finland":                {"Mannerheimintie 1", "Helsinki", "", "00100", "Finland"},
+				"Václavské nám. 1, 110 00 Praha, Czechia":                   {"Václavské nám. 1", "Praha", "", "110 00", "Czechia"},
+				"Rue du Marché-aux-Herbes 1, L-1728 Luxembourg, Luxembourg": {"Rue du Marché-aux-Herbes 1", "Luxembourg", "", "L-1728", "Luxembourg"},
+				"Ermou 1, 105 63 Athina, Greece":                            {"Ermou 1", "Athina", "", "105 63", "Greece"},
+				"Alexanderplatz 1, 10178 Berlin":                            {"Alexanderplatz 1", "Berlin", "", "10178", ""},
+			})
+		})
+	})
+
+	when("parsing other addresses", func() {
+		it("splits them on a best effort basis", func() {
+			check(map[string]parts{
+				"":                          {},
+				" , ":                       {},
+				"Columbus":                  {"", "Columbus", "", "", ""},
+				"1555 W Lane Ave":           {"1555 W Lane Ave", "", "", "", ""},
+				"1555 W Lane Ave, Columbus": {"1555 W Lane Ave", "Columbus", "", "", ""},
+				"1-1 Marunouchi, Chiyoda City, Tokyo, Japan":        {"1-1 Marunouchi, Chiyoda City", "Tokyo", "", "", "Japan"},
+				"Av. Paulista, 1578, Bela Vista, São Paulo, Brazil": {"Av. Paulista, 1578, Bela Vista", "São Paulo", "", "", "Brazil"},
+				"Germany": {"", "", "", "", "Germany"},
+			})
+		})
+
+		it("keeps a postal format that does not fit the country", func() {
+			parsed := address.Parse("1555 W Lane Ave, Columbus, OH 43221, Germany")
+			Expect(parsed.Region).To(Equal("OH"))
+			Expect(parsed.Country).To(Equal("Germany"))
+			Expect(parsed.Confidence).To(BeNumerically("<", 0.7))
+		})
+	})
+
+	when("scoring the confidence", func() {
+		it("is highest for complete addresses in a known format", func() {
+			for _, formatted := range []string{
+				"1555 W Lane Ave, Columbus, OH 43221, United States",
+				"87 Avenue Rd, Toronto, ON M5R 3R9, Canada",
+				"10 Downing St, London SW1A 2AA, United Kingdom",
+				"Unter den Linden 77, 10117 Berlin, Germany",
+				"Piazza del Colosseo, 1, 00184 Roma RM, Italy",
+			} {
+				Expect(address.Parse(formatted).Confidence).To(Equal(1.0), formatted)
+			}
+		})
+
+		it("drops with every missing part", func() {
+			complete := address.Parse("1555 W Lane Ave, Columbus, OH 43221, United States").Confidence
+			noCountry := address.Parse("1555 W Lane Ave, Columbus, OH 43221").Confidence
+			noStreet := address.Parse("Columbus, OH 43221").Confidence
+			cityOnly := address.Parse("Columbus").Confidence
+
+			Expect(noCountry).To(BeNumerically("<", complete))
+			Expect(noStreet).To(BeNumerically("<", noCountry))
+			Expect(cityOnly).To(BeNumerically("<", noStreet))
+			Expect(address.Parse("").Confidence).To(BeZero())
+		})
+
+		it("is lower for countries it does not know", func() {
+			Expect(address.Parse("1-1 Marunouchi, Chiyoda City, Tokyo, Japan").Confidence).To(BeNumerically("<", 0.5))
+		})
+	})
+
+	it("normalizes the names of states and provinces", func() {
+		for name, code := range map[string]string{"Ohio": "OH", "oh": "OH", " New York ": "NY", "Québec": "QC", "ON": "ON", "Bavaria": "Bavaria"} {
+			Expect(address.RegionCode(name)).To(Equal(code), name)
+		}
+	})
+
+	it("parses the addresses of locations", func() {
+		locations := []types.Location{
+			{PlaceId: "a", FormattedAddress: "1555 W Lane Ave, Columbus, OH 43221, United States"},
+			{PlaceId: "b"},
+		}
+
+		parsed := address.ParseAll(locations)
+		Expect(parsed[0].Address).To(Equal(&types.Address{
+			Street: "1555 W Lane Ave", City: "Columbus", Region: "OH", PostalCode: "43221", Country: "United States", Confidence: 1,
+		}))
+		Expect(parsed[1].Address).To(Equal(&types.Address{}))
+		Expect(locations[0].Address).To(BeNil())
+	})
+}
@@ -0,0 +1,320 @@
+package app
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+)
+
+const DefaultBatchRetries = 1
+
+var ErrNoQueries = errors.New("the input does not contain any queries")
+
+//go:generate mockgen -destination=batchmocks_test.go -package=app_test github.com/kardolus/maps/app Finder,RowWriter
+type Finder interface {
+	Find(opts Options) ([]types.Location, Summary, error)
+}
+
+type RowWriter interface {
+	WriteRows(rows []output.Row) error
+}
+
+type RequestCounter interface {
+	Requests() int
+}
+
+// BatchResult is the outcome of a single query of a batch
+type BatchResult struct {
+	Query     string
+	Locations []types.Location
+	Requests  int
+	Attempts  int
+	Err       error
+}
+
+type Batch struct {
+	finder   Finder
+	counter  RequestCounter
+	dir      *output.Dir
+	combined RowWriter
+	stream   RowWriter
+	retries  int
+	log      io.Writer
+}
+
+// NewBatch runs queries one after the other through the finder. The counter is read before and after every query
+// to attribute the Places API requests to it.
+func NewBatch(finder Finder, counter RequestCounter) *Batch {
+	return &Batch{
+		finder:  finder,
+		counter: counter,
+		retries: DefaultBatchRetries,
+		log:     io.Discard,
+	}
+}
+
+// WithRetries configures how many more times failed queries are run after the first pass over the batch
+func (b *Batch) WithRetries(retries int) *Batch {
+	b.retries = retries
+	return b
+}
+
+// WithDir writes the results of every query to its own file as soon as the query completes
+func (b *Batch) WithDir(dir *output.Dir) *Batch {
+	b.dir = dir
+	return b
+}
+
+// WithCombined writes the results of all successful queries to a single output with a query column
+func (b *Batch) WithCombined(writer RowWriter) *Batch {
+	b.combined = writer
+	return b
+}
+
+// WithStream writes the results of every query with a query column as soon as the query completes
+func (b *Batch) WithStream(writer RowWriter) *Batch {
+	b.stream = writer
+	return b
+}
+
+// WithLog configures where progress messages are written
+func (b *Batch) WithLog(log io.Writer) *Batch {
+	b.log = log
+	return b
+}
+
+// Run runs every query and writes the results. Failed queries are retried after the rest of the batch, since by then
+// a transient error has had time to clear. When the Places API quota runs out, the remaining queries are not run.
+func (b *Batch) Run(opts Options, queries []string) ([]BatchResult, error) {
+	results := make([]BatchResult, len(queries))
+
+	var pending []int
+	for i, query := range queries {
+		results[i].Query = query
+		pending = append(pending, i)
+	}
+
+	for attempt := 0; attempt <= b.retries && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			fmt.Fprintf(b.log, "Retrying %d failed queries\n", len(pending))
+		}
+
+		var failed []int
+
+		for n, i := range pending {
+			result := &results[i]
+
+			queryOpts, err := opts.ForQuery(result.Query)
+			if err != nil {
+				result.Err = err // invalid rules do not get better with retries
+				continue
+			}
+
+			before := b.counter.Requests()
+			locations, _, err := b.finder.Find(queryOpts)
+
+			result.Requests += b.counter.Requests() - before
+			result.Attempts++
+			result.Err = err
+
+			if err != nil {
+				fmt.Fprintf(b.log, "[%d/%d] %s: %s\n", n+1, len(pending), result.Query, err)
+
+				if client.IsQuotaError(err) {
+					return results, b.writeCombined(results)
+				}
+
+				failed = append(failed, i)
+				continue
+			}
+
+			result.Locations = locations
+			fmt.Fprintf(b.log, "[%d/%d] %s: %d results\n", n+1, len(pending), result.Query, len(locations))
+
+			if b.dir != nil {
+				if err := b.dir.For(result.Query).Write(locations); err != nil {
+					return results, err
+				}
+			}
+
+			if b.stream != nil {
+				if err := b.stream.WriteRows(output.Rows(result.Query, locations)); err != nil {
+					return results, err
+				}
+			}
+		}
+
+		pending = failed
+	}
+
+	return results, b.writeCombined(results)
+}
+
+func (b *Batch) writeCombined(results []BatchResult) error {
+	if b.combined == nil {
+		return nil
+	}
+
+	var rows []output.Row
+	for _, result := range results {
+		if result.Err == nil {
+			rows = append(rows, output.Rows(result.Query, result.Locations)...)
+		}
+	}
+
+	return b.combined.WriteRows(rows)
+}
+
+// Failed returns the queries that did not complete, including the ones that were skipped
+func Failed(results []BatchResult) []string {
+	var result []string
+	for _, r := range results {
+		if r.Err != nil || r.Attempts == 0 {
+			result = append(result, r.Query)
+		}
+	}
+	return result
+}
+
+// WriteBatchSummary renders a table with the results, requests and status of every query followed by the totals
+func WriteBatchSummary(w io.Writer, results []BatchResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "QUERY\tRESULTS\tREQUESTS\tATTEMPTS\tSTATUS")
+
+	var locations, requests, failed int
+	for _, r := range results {
+		status := "ok"
+		switch {
+		case r.Err != nil:
+			status = r.Err.Error()
+			failed++
+		case r.Attempts == 0:
+			status = "skipped"
+			failed++
+		}
+
+		locations += len(r.Locations)
+		requests += r.Requests
+
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", r.Query, len(r.Locations), r.Requests, r.Attempts, status)
+	}
+
+	fmt.Fprintf(tw, "TOTAL (%d failed)\t%d\t%d\t\t\n", failed, locations, requests)
+
+	return tw.Flush()
+}
+
+// ReadQueries reads the queries of a batch. CSV files use the "query" column, or the first column when there is no
+// such header. Any other file has one query per line, where blank lines and lines starting with # are skipped.
+// Duplicates are dropped. A path of "-" reads the lines from stdin.
+func ReadQueries(path string, stdin io.Reader) ([]string, error) {
+	if path == Stdin {
+		return ParseQueries(stdin)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var queries []string
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		queries, err = readCSV(file)
+	} else {
+		queries, err = readLines(file)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queries from %s: %w", path, err)
+	}
+
+	return unique(queries)
+}
+
+// ParseQueries reads one query per line
+func ParseQueries(r io.Reader) ([]string, error) {
+	queries, err := readLines(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queries: %w", err)
+	}
+
+	return unique(queries)
+}
+
+func unique(queries []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]struct{})
+
+	for _, query := range queries {
+		query = strings.TrimSpace(query)
+		key := strings.ToLower(query)
+
+		if _, ok := seen[key]; ok || query == "" {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		result = append(result, query)
+	}
+
+	if len(result) == 0 {
+		return nil, ErrNoQueries
+	}
+
+	return result, nil
+}
+
+func readLines(r io.Reader) ([]string, error) {
+	var result []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		result = append(result, line)
+	}
+
+	return result, scanner.Err()
+}
+
+func readCSV(r io.Reader) ([]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	column := 0
+	for i, name := range records[0] {
+		if strings.EqualFold(strings.TrimSpace(name), "query") {
+			column = i
+			records = records[1:]
+			break
+		}
+	}
+
+	var result []string
+	for _, record := range records {
+		if column < len(record) {
+			result = append(result, record[column])
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,227 @@
+package app_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+type fakeCounter struct {
+	requests int
+}
+
+func (f *fakeCounter) Requests() int {
+	return f.requests
+}
+
+func TestUnitBatch(t *testing.T) {
+	spec.Run(t, "Batch Unit Tests", testBatch, spec.Report(report.Terminal{}))
+}
+
+func testBatch(t *testing.T, when spec.G, it spec.S) {
+	var (
+		ctrl     *gomock.Controller
+		finder   *MockFinder
+		combined *MockRowWriter
+		counter  *fakeCounter
+		subject  *app.Batch
+		opts     app.Options
+		queries  = []string{"Whole Foods in Ohio", "Trader Joe's in Ohio"}
+		found    = func(query string, requests int, locations ...types.Location) func(app.Options) ([]types.Location, app.Summary, error) {
+			return func(o app.Options) ([]types.Location, app.Summary, error) {
+				Expect(o.Query).To(Equal(query))
+				counter.requests += requests
+				return locations, app.Summary{Query: query}, nil
+			}
+		}
+		failing = func(requests int, err error) func(app.Options) ([]types.Location, app.Summary, error) {
+			return func(o app.Options) ([]types.Location, app.Summary, error) {
+				counter.requests += requests
+				return nil, app.Summary{}, err
+			}
+		}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		ctrl = gomock.NewController(t)
+		finder = NewMockFinder(ctrl)
+		combined = NewMockRowWriter(ctrl)
+		counter = &fakeCounter{}
+
+		subject = app.NewBatch(finder, counter).WithCombined(combined)
+		opts = app.Options{}
+	})
+
+	it.After(func() {
+		ctrl.Finish()
+	})
+
+	it("runs every query and writes the combined rows", func() {
+		finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[0], 3, types.Location{PlaceId: "a"}, types.Location{PlaceId: "b"}))
+		finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[1], 1, types.Location{PlaceId: "c"}))
+		combined.EXPECT().WriteRows([]output.Row{
+			{Query: queries[0], Location: types.Location{PlaceId: "a"}},
+			{Query: queries[0], Location: types.Location{PlaceId: "b"}},
+			{Query: queries[1], Location: types.Location{PlaceId: "c"}},
+		}).Return(nil)
+
+		results, err := subject.Run(opts, queries)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		Expect(results[0].Requests).To(Equal(3))
+		Expect(results[1].Requests).To(Equal(1))
+		Expect(results[1].Attempts).To(Equal(1))
+		Expect(app.Failed(results)).To(BeEmpty())
+	})
+
+	it("retries failed queries after the rest of the batch", func() {
+		gomock.InOrder(
+			finder.EXPECT().Find(gomock.Any()).DoAndReturn(failing(1, errors.New("timeout"))),
+			finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[1], 1)),
+			finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[0], 2, types.Location{PlaceId: "a"})),
+		)
+		combined.EXPECT().WriteRows(gomock.Len(1)).Return(nil)
+
+		results, err := subject.Run(opts, queries)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[0].Err).NotTo(HaveOccurred())
+		Expect(results[0].Attempts).To(Equal(2))
+		Expect(results[0].Requests).To(Equal(3))
+	})
+
+	it("lists the queries that still fail after the retries", func() {
+		subject.WithRetries(0)
+
+		finder.EXPECT().Find(gomock.Any()).DoAndReturn(failing(1, errors.New("timeout")))
+		finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[1], 1))
+		combined.EXPECT().WriteRows(gomock.Len(0)).Return(nil)
+
+		results, err := subject.Run(opts, queries)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(app.Failed(results)).To(Equal([]string{queries[0]}))
+	})
+
+	it("stops when the quota is exhausted", func() {
+		quota := &client.StatusError{Status: client.StatusOverQueryLimit, Query: queries[0]}
+
+		finder.EXPECT().Find(gomock.Any()).DoAndReturn(failing(1, quota))
+		combined.EXPECT().WriteRows(gomock.Len(0)).Return(nil)
+
+		results, err := subject.Run(opts, queries)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(results[1].Attempts).To(BeZero())
+		Expect(app.Failed(results)).To(Equal(queries))
+	})
+
+	it("writes one file per query", func() {
+		dir := output.NewDir(t.TempDir(), output.FormatJSON)
+		subject = app.NewBatch(finder, counter).WithDir(dir)
+
+		finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[0], 1, types.Location{PlaceId: "a"}))
+		finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[1], 1))
+
+		_, err := subject.Run(opts, queries)
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := os.ReadFile(dir.For(queries[0]).Path())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"place_id": "a"`))
+
+		data, err = os.ReadFile(dir.For(queries[1]).Path())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("[]\n"))
+	})
+
+	it("streams the rows of every query as it completes", func() {
+		stream := NewMockRowWriter(ctrl)
+		subject = app.NewBatch(finder, counter).WithStream(stream)
+
+		gomock.InOrder(
+			finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[0], 1, types.Location{PlaceId: "a"})),
+			stream.EXPECT().WriteRows([]output.Row{{Query: queries[0], Location: types.Location{PlaceId: "a"}}}).Return(nil),
+			finder.EXPECT().Find(gomock.Any()).DoAndReturn(found(queries[1], 1)),
+			stream.EXPECT().WriteRows(gomock.Len(0)).Return(nil),
+		)
+
+		_, err := subject.Run(opts, queries)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("renders a summary table", func() {
+		results := []app.BatchResult{
+			{Query: queries[0], Locations: make([]types.Location, 12), Requests: 4, Attempts: 1},
+			{Query: queries[1], Requests: 2, Attempts: 2, Err: errors.New("timeout")},
+			{Query: "Aldi in Ohio"},
+		}
+
+		var buf bytes.Buffer
+		Expect(app.WriteBatchSummary(&buf, results)).To(Succeed())
+
+		Expect(buf.String()).To(Equal("" +
+			"QUERY                 RESULTS  REQUESTS  ATTEMPTS  STATUS\n" +
+			"Whole Foods in Ohio   12       4         1         ok\n" +
+			"Trader Joe's in Ohio  0        2         2         timeout\n" +
+			"Aldi in Ohio          0        0         0         skipped\n" +
+			"TOTAL (2 failed)      12       6                   \n"))
+	})
+
+	when("reading queries", func() {
+		write := func(name, content string) string {
+			path := filepath.Join(t.TempDir(), name)
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+			return path
+		}
+
+		it("reads one query per line", func() {
+			path := write("queries.txt", "# brands\nWhole Foods in Ohio\n\n  Trader Joe's in Ohio  \nwhole foods in ohio\n")
+
+			result, err := app.ReadQueries(path, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(queries))
+		})
+
+		it("reads the query column of a CSV file", func() {
+			path := write("queries.csv", "brand,query\nwf,Whole Foods in Ohio\ntj,\"Trader Joe's in Ohio\"\n")
+
+			result, err := app.ReadQueries(path, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(queries))
+		})
+
+		it("reads the first column of a CSV file without a header", func() {
+			path := write("queries.csv", "Whole Foods in Ohio,wf\nTrader Joe's in Ohio,tj\n")
+
+			result, err := app.ReadQueries(path, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(queries))
+		})
+
+		it("reads stdin for -", func() {
+			result, err := app.ReadQueries("-", strings.NewReader("Whole Foods in Ohio\nTrader Joe's in Ohio\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(queries))
+		})
+
+		it("returns an error for an empty file", func() {
+			_, err := app.ReadQueries(write("queries.txt", "# nothing yet\n"), nil)
+			Expect(err).To(MatchError(app.ErrNoQueries))
+		})
+	})
+}
@@ -0,0 +1,204 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/output"
+	"github.com/spf13/viper"
+	"io"
+	"strings"
+)
+
+const (
+	// Stdin is the file name that reads from standard input
+	Stdin = "-"
+	// Stdout is the file name that writes to standard output
+	Stdout = "-"
+)
+
+var (
+	ErrMissingAPIKey = errors.New("missing Google Places API key, set it via --api-key flag or GOOGLE_API_KEY environment variable, or list several via --api-keys or GOOGLE_API_KEYS")
+	ErrMissingQuery  = errors.New("missing query, pass it via --query, as an argument or use - to read it from stdin")
+	ErrQueryTwice    = errors.New("the query was given both via --query and as an argument, use one or the other")
+)
+
+// Options holds the settings of a single search, read from the flags, the environment and the config file
+type Options struct {
+	Query             string
+	APIKey            string
+	Keys              *client.Keys
+	PlacesURL         string
+	PageDelay         int
+	Language          string
+	Region            string
+	LLM               llm.Provider
+	Output            string
+	Format            output.Format
+	PromptDir         string
+	QueryPrompt       string
+	FilterPrompt      string
+	MaxResults        int
+	Rounds            int
+	Locale            string
+	Rules             filter.Rules
+	Where             *filter.Expression
+	Classify          bool
+	KeepIrrelevant    bool
+	ClassifyBatchSize int
+	VerdictCache      string
+	Dedupe            bool
+	DedupeRadius      float64
+	DedupeSimilarity  float64
+	DedupeReport      string
+	ParseAddresses    bool
+	WithProvenance    bool
+
+	flagRules filter.Rules
+	ruleFile  *filter.RuleFile
+}
+
+// NewOptions reads the options from v and validates them, so mistakes are reported before any request is made
+func NewOptions(v *viper.Viper) (Options, error) {
+	opts := Options{
+		Query:             v.GetString("query"),
+		APIKey:            v.GetString("api-key"),
+		PlacesURL:         v.GetString("places-url"),
+		PageDelay:         v.GetInt("page-delay"),
+		Language:          v.GetString("language"),
+		Region:            v.GetString("region"),
+		Output:            v.GetString("output"),
+		PromptDir:         v.GetString("prompt-dir"),
+		QueryPrompt:       v.GetString("query-prompt"),
+		FilterPrompt:      v.GetString("filter-prompt"),
+		MaxResults:        v.GetInt("max-results"),
+		Rounds:            v.GetInt("rounds"),
+		Locale:            v.GetString("locale"),
+		Classify:          v.GetBool("classify"),
+		KeepIrrelevant:    v.GetBool("keep-irrelevant"),
+		ClassifyBatchSize: v.GetInt("classify-batch-size"),
+		VerdictCache:      v.GetString("verdict-cache"),
+		Dedupe:            v.GetBool("dedupe"),
+		DedupeRadius:      v.GetFloat64("dedupe-radius"),
+		DedupeSimilarity:  v.GetFloat64("dedupe-similarity"),
+		DedupeReport:      v.GetString("dedupe-report"),
+		ParseAddresses:    v.GetBool("parse-address"),
+		WithProvenance:    v.GetBool("with-provenance"),
+	}
+
+	opts.LLM = llm.Provider{
+		URL:    v.GetString("llm.url"),
+		Model:  v.GetString("llm.model"),
+		APIKey: v.GetString("llm.api-key"),
+	}
+
+	var keys []string
+	for _, value := range append([]string{opts.APIKey}, v.GetStringSlice("api-keys")...) {
+		keys = append(keys, strings.Split(value, ",")...)
+	}
+
+	rotation, err := client.ParseRotation(v.GetString("key-rotation"))
+	if err != nil {
+		return Options{}, err
+	}
+
+	// the keys are shared by every search of the run, so the rotation and usage span all of them
+	opts.Keys = client.NewKeys(keys...).WithRotation(rotation)
+	if opts.Keys.Len() == 0 {
+		return Options{}, ErrMissingAPIKey
+	}
+	for _, key := range keys {
+		if opts.APIKey = strings.TrimSpace(key); opts.APIKey != "" {
+			break
+		}
+	}
+
+	format, err := output.ParseFormat(v.GetString("format"))
+	if err != nil {
+		return Options{}, err
+	}
+	opts.Format = format
+
+	if expr := v.GetString("where"); expr != "" {
+		where, err := filter.Compile(expr)
+		if err != nil {
+			return Options{}, fmt.Errorf("invalid --where expression: %w", err)
+		}
+		opts.Where = where
+	}
+
+	if opts.Dedupe && opts.DedupeRadius < 0 {
+		return Options{}, fmt.Errorf("invalid --dedupe-radius %v, use a distance in meters", opts.DedupeRadius)
+	}
+
+	if opts.Dedupe && (opts.DedupeSimilarity <= 0 || opts.DedupeSimilarity > 1) {
+		return Options{}, fmt.Errorf("invalid --dedupe-similarity %v, use a value between 0 and 1", opts.DedupeSimilarity)
+	}
+
+	opts.flagRules = filter.Rules{
+		Exclude:      v.GetStringSlice("exclude"),
+		NameRegex:    v.GetStringSlice("name-regex"),
+		ExcludeRegex: v.GetStringSlice("exclude-regex"),
+	}
+
+	if path := v.GetString("filter-file"); path != "" {
+		file, err := filter.LoadRules(path)
+		if err != nil {
+			return Options{}, err
+		}
+		opts.ruleFile = file
+	}
+
+	return opts.ForQuery(opts.Query)
+}
+
+// ForQuery returns a copy of the options for another query, with the name rules of the filter file for that query
+func (o Options) ForQuery(query string) (Options, error) {
+	o.Query = query
+	o.Rules = o.flagRules
+
+	if o.ruleFile != nil {
+		o.Rules = o.Rules.Merge(o.ruleFile.For(query))
+	}
+
+	// Validate the regular expressions before spending any requests
+	if _, err := o.Rules.Compile(); err != nil {
+		return Options{}, err
+	}
+
+	return o, nil
+}
+
+// ResolveQuery picks the query from the --query flag or the positional arguments, where a single "-" reads the query
+// from stdin. Stdin must contain exactly one query; use the batch command for more.
+func ResolveQuery(flag string, args []string, stdin io.Reader) (string, error) {
+	if flag != "" && len(args) > 0 {
+		return "", ErrQueryTwice
+	}
+
+	if len(args) == 1 && args[0] == Stdin {
+		queries, err := ParseQueries(stdin)
+		if err != nil {
+			return "", err
+		}
+
+		if len(queries) > 1 {
+			return "", fmt.Errorf("stdin contains %d queries, use maps batch --input - to run more than one", len(queries))
+		}
+
+		return queries[0], nil
+	}
+
+	query := strings.TrimSpace(flag)
+	if len(args) > 0 {
+		query = strings.TrimSpace(strings.Join(args, " "))
+	}
+
+	if query == "" {
+		return "", ErrMissingQuery
+	}
+
+	return query, nil
+}
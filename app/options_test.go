@@ -0,0 +1,182 @@
+package app_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/output"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+	"github.com/spf13/viper"
+)
+
+func TestUnitOptions(t *testing.T) {
+	spec.Run(t, "Options Unit Tests", testOptions, spec.Report(report.Terminal{}))
+}
+
+func testOptions(t *testing.T, when spec.G, it spec.S) {
+	var v *viper.Viper
+
+	it.Before(func() {
+		RegisterTestingT(t)
+
+		v = viper.New()
+		v.Set("query", "Whole Foods in USA")
+		v.Set("api-key", "key")
+	})
+
+	it("reads the flags", func() {
+		v.Set("output", "results.json")
+		v.Set("rounds", 3)
+		v.Set("classify", true)
+		v.Set("exclude", []string{"cafe"})
+
+		opts, err := app.NewOptions(v)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Query).To(Equal("Whole Foods in USA"))
+		Expect(opts.Output).To(Equal("results.json"))
+		Expect(opts.Rounds).To(Equal(3))
+		Expect(opts.Classify).To(BeTrue())
+		Expect(opts.Rules.Exclude).To(Equal([]string{"cafe"}))
+		Expect(opts.Where).To(BeNil())
+	})
+
+	it("validates the output format", func() {
+		v.Set("format", "ndjson")
+
+		opts, err := app.NewOptions(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Format).To(Equal(output.FormatNDJSON))
+
+		v.Set("format", "xml")
+
+		_, err = app.NewOptions(v)
+		Expect(err).To(MatchError(ContainSubstring("unsupported output format")))
+	})
+
+	it("validates the dedupe similarity", func() {
+		v.Set("dedupe", true)
+		v.Set("dedupe-radius", 50)
+		v.Set("dedupe-similarity", 0.8)
+
+		opts, err := app.NewOptions(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.DedupeSimilarity).To(Equal(0.8))
+
+		v.Set("dedupe-similarity", 80)
+
+		_, err = app.NewOptions(v)
+		Expect(err).To(MatchError(ContainSubstring("invalid --dedupe-similarity 80")))
+	})
+
+	it("requires an API key", func() {
+		v.Set("api-key", "")
+
+		_, err := app.NewOptions(v)
+		Expect(err).To(MatchError(app.ErrMissingAPIKey))
+	})
+
+	it("pools the API keys", func() {
+		v.Set("api-key", "")
+		v.Set("api-keys", []string{"key-1,key-2", "key-3", "key-1"})
+		v.Set("key-rotation", client.RotationFailover)
+
+		opts, err := app.NewOptions(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.APIKey).To(Equal("key-1"))
+		Expect(opts.Keys.Len()).To(Equal(3))
+		Expect(opts.Keys.Next()).To(Equal("key-1"))
+		Expect(opts.Keys.Next()).To(Equal("key-1"))
+
+		v.Set("key-rotation", "random")
+		_, err = app.NewOptions(v)
+		Expect(err).To(MatchError(ContainSubstring("invalid key rotation")))
+	})
+
+	it("compiles the where expression", func() {
+		v.Set("where", "rating >= 4")
+
+		opts, err := app.NewOptions(v)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Where.String()).To(Equal("rating >= 4"))
+
+		v.Set("where", "rating >= 'high'")
+
+		_, err = app.NewOptions(v)
+		Expect(err).To(MatchError(ContainSubstring("invalid --where expression")))
+	})
+
+	it("rejects an invalid regular expression", func() {
+		v.Set("name-regex", []string{"whole (foods"})
+
+		_, err := app.NewOptions(v)
+		Expect(err).To(HaveOccurred())
+	})
+
+	it("merges the rules of the filter file for the query", func() {
+		path := filepath.Join(t.TempDir(), "filters.yaml")
+		Expect(os.WriteFile(path, []byte("default:\n  exclude: [kitchen]\nqueries:\n  whole foods in usa:\n    contains: [whole foods market]\n"), 0644)).To(Succeed())
+
+		v.Set("filter-file", path)
+		v.Set("exclude", []string{"cafe"})
+
+		opts, err := app.NewOptions(v)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts.Rules.Contains).To(Equal([]string{"whole foods market"}))
+		Expect(opts.Rules.Exclude).To(ConsistOf("cafe", "kitchen"))
+		Expect(opts.Rules.HasIncludes()).To(BeTrue())
+	})
+
+	when("resolving the query", func() {
+		it("uses the flag", func() {
+			query, err := app.ResolveQuery(" Trader Joe's in Ohio ", nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(query).To(Equal("Trader Joe's in Ohio"))
+		})
+
+		it("joins the positional arguments", func() {
+			query, err := app.ResolveQuery("", []string{"Trader", "Joe's", "in", "Ohio"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(query).To(Equal("Trader Joe's in Ohio"))
+		})
+
+		it("reads a single query from stdin", func() {
+			query, err := app.ResolveQuery("", []string{"-"}, strings.NewReader("\nTrader Joe's in Ohio\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(query).To(Equal("Trader Joe's in Ohio"))
+
+			_, err = app.ResolveQuery("", []string{"-"}, strings.NewReader("Whole Foods in Ohio\nTrader Joe's in Ohio\n"))
+			Expect(err).To(MatchError(ContainSubstring("stdin contains 2 queries")))
+
+			_, err = app.ResolveQuery("", []string{"-"}, strings.NewReader(""))
+			Expect(err).To(MatchError(app.ErrNoQueries))
+		})
+
+		it("requires a query", func() {
+			_, err := app.ResolveQuery("", nil, nil)
+			Expect(err).To(MatchError(app.ErrMissingQuery))
+
+			_, err = app.ResolveQuery("  ", nil, nil)
+			Expect(err).To(MatchError(app.ErrMissingQuery))
+		})
+
+		it("does not accept the query twice", func() {
+			_, err := app.ResolveQuery("Whole Foods in Ohio", []string{"Trader Joe's"}, nil)
+			Expect(err).To(MatchError(app.ErrQueryTwice))
+		})
+	})
+
+	it("returns an error for a missing filter file", func() {
+		v.Set("filter-file", filepath.Join(t.TempDir(), "missing.yaml"))
+
+		_, err := app.NewOptions(v)
+		Expect(err).To(HaveOccurred())
+	})
+}
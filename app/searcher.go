@@ -0,0 +1,238 @@
+// Package app runs a search from start to finish: it generates the name filter, plans and fetches the sub-queries,
+// applies the --where expression, the dedupe stage and the relevance classification, parses the addresses and writes
+// the results. Every collaborator is an interface so the whole flow can be tested without network access.
+package app
+
+import (
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/address"
+	"github.com/kardolus/maps/dedupe"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/types"
+	"io"
+	"time"
+)
+
+var ErrNoClassifier = errors.New("classification was requested but no classifier is configured")
+
+//go:generate mockgen -destination=searchermocks_test.go -package=app_test github.com/kardolus/maps/app Places,Planner,FilterGenerator,Classifier,Writer,Clock
+type Places interface {
+	FetchFiltered(entity string, names *filter.Names) ([]types.Location, types.QueryStats, error)
+}
+
+type Planner interface {
+	Plan(query string, names *filter.Names) (*llm.PlanNode, []types.Location, error)
+}
+
+type FilterGenerator interface {
+	ClearHistory() error
+	GenerateFilter(query string) ([]string, []string, error)
+}
+
+type Classifier interface {
+	Classify(query string, locations []types.Location) ([]types.Location, error)
+}
+
+type Writer interface {
+	Write(locations []types.Location) error
+}
+
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Summary describes what a search did
+type Summary struct {
+	Query      string         `json:"query"`
+	Plan       *llm.PlanNode  `json:"plan"`
+	Fetched    int            `json:"fetched"`
+	Matched    int            `json:"matched"`
+	Classified int            `json:"classified"`
+	Dedupe     *dedupe.Report `json:"dedupe,omitempty"`
+	Written    int            `json:"written"`
+	StartedAt  time.Time      `json:"started_at"`
+	Duration   time.Duration  `json:"duration"`
+}
+
+type Searcher struct {
+	places     Places
+	writer     Writer
+	planner    Planner
+	generator  FilterGenerator
+	classifier Classifier
+	clock      Clock
+	log        io.Writer
+}
+
+func New(places Places, writer Writer) *Searcher {
+	return &Searcher{
+		places: places,
+		writer: writer,
+		clock:  systemClock{},
+		log:    io.Discard,
+	}
+}
+
+// WithPlanner breaks the query down into sub-queries. Without a planner the query is sent to the Places API as is.
+func (s *Searcher) WithPlanner(planner Planner) *Searcher {
+	s.planner = planner
+	return s
+}
+
+// WithFilterGenerator asks the LLM for name rules when the options do not contain any include rules
+func (s *Searcher) WithFilterGenerator(generator FilterGenerator) *Searcher {
+	s.generator = generator
+	return s
+}
+
+// WithClassifier is required when the options ask for relevance classification
+func (s *Searcher) WithClassifier(classifier Classifier) *Searcher {
+	s.classifier = classifier
+	return s
+}
+
+func (s *Searcher) WithClock(clock Clock) *Searcher {
+	s.clock = clock
+	return s
+}
+
+// WithLog configures where progress messages are written
+func (s *Searcher) WithLog(log io.Writer) *Searcher {
+	s.log = log
+	return s
+}
+
+// Search runs the query described by opts, writes the results and returns them together with a summary
+func (s *Searcher) Search(opts Options) ([]types.Location, Summary, error) {
+	locations, summary, err := s.Find(opts)
+	if err != nil {
+		return nil, summary, err
+	}
+
+	if err := s.writer.Write(locations); err != nil {
+		return nil, summary, err
+	}
+
+	summary.Written = len(locations)
+
+	return locations, summary, nil
+}
+
+// Find runs the query described by opts without writing the results
+func (s *Searcher) Find(opts Options) ([]types.Location, Summary, error) {
+	summary := Summary{Query: opts.Query, StartedAt: s.clock.Now()}
+
+	if opts.Classify && s.classifier == nil {
+		return nil, summary, ErrNoClassifier
+	}
+
+	fmt.Fprintf(s.log, "Fetching locations for query: %s\n", opts.Query)
+
+	names, err := s.names(opts)
+	if err != nil {
+		return nil, summary, err
+	}
+
+	tree, locations, err := s.fetch(opts.Query, names)
+	if err != nil {
+		return nil, summary, err
+	}
+
+	summary.Plan = tree
+	summary.Fetched = len(locations)
+
+	fmt.Fprintf(s.log, "Planning tree:\n%s\n", tree)
+
+	if opts.Where != nil {
+		locations = opts.Where.Filter(locations)
+		fmt.Fprintf(s.log, "%d results match %s\n", len(locations), opts.Where)
+	}
+
+	summary.Matched = len(locations)
+
+	if opts.Dedupe {
+		var report dedupe.Report
+		locations, report = dedupe.New().
+			WithRadius(opts.DedupeRadius).
+			WithSimilarity(opts.DedupeSimilarity).
+			Dedupe(locations)
+
+		summary.Dedupe = &report
+
+		fmt.Fprintf(s.log, "Merged %d duplicates into %d places\n", report.Duplicates(), len(report.Groups))
+		if err := report.WriteText(s.log); err != nil {
+			return nil, summary, err
+		}
+	}
+
+	if opts.Classify {
+		locations, err = s.classifier.Classify(opts.Query, locations)
+		if err != nil {
+			return nil, summary, err
+		}
+
+		summary.Classified = len(locations)
+
+		if !opts.KeepIrrelevant {
+			relevant := llm.Relevant(locations)
+			fmt.Fprintf(s.log, "Classified %d results, %d relevant\n", len(locations), len(relevant))
+			locations = relevant
+		}
+	}
+
+	if opts.ParseAddresses {
+		locations = address.ParseAll(locations)
+	}
+
+	summary.Duration = s.clock.Now().Sub(summary.StartedAt)
+
+	return locations, summary, nil
+}
+
+// names compiles the name rules, asking the LLM for include rules when the user did not supply any
+func (s *Searcher) names(opts Options) (*filter.Names, error) {
+	rules := opts.Rules
+
+	if s.generator != nil {
+		if err := s.generator.ClearHistory(); err != nil {
+			return nil, err
+		}
+
+		// User supplied include rules replace the ones generated by the LLM
+		if !rules.HasIncludes() {
+			contains, matches, err := s.generator.GenerateFilter(opts.Query)
+			if err != nil {
+				return nil, err
+			}
+
+			rules = filter.Rules{Contains: contains, Matches: matches}.Merge(rules)
+		}
+
+		if err := s.generator.ClearHistory(); err != nil {
+			return nil, err
+		}
+	}
+
+	return rules.Compile()
+}
+
+func (s *Searcher) fetch(query string, names *filter.Names) (*llm.PlanNode, []types.Location, error) {
+	if s.planner != nil {
+		return s.planner.Plan(query, names)
+	}
+
+	locations, stats, err := s.places.FetchFiltered(query, names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &llm.PlanNode{Query: query, Stats: stats}, locations, nil
+}
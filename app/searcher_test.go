@@ -0,0 +1,267 @@
+package app_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitSearcher(t *testing.T) {
+	spec.Run(t, "Searcher Unit Tests", testSearcher, spec.Report(report.Terminal{}))
+}
+
+func testSearcher(t *testing.T, when spec.G, it spec.S) {
+	const query = "Whole Foods in USA"
+
+	var (
+		ctrl       *gomock.Controller
+		places     *MockPlaces
+		planner    *MockPlanner
+		generator  *MockFilterGenerator
+		classifier *MockClassifier
+		writer     *MockWriter
+		clock      *MockClock
+		log        *bytes.Buffer
+		subject    *app.Searcher
+		opts       app.Options
+		start      = time.Date(2024, 5, 14, 16, 0, 0, 0, time.UTC)
+		locations  = []types.Location{
+			{PlaceId: "a", Name: "Whole Foods Market", Rating: types.Ptr(4.6)},
+			{PlaceId: "b", Name: "Whole Foods Market", Rating: types.Ptr(3.9)},
+		}
+		tree = &llm.PlanNode{Query: query, Stats: types.QueryStats{Query: query, Pages: 2, Results: 30, Kept: 2}}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		ctrl = gomock.NewController(t)
+		places = NewMockPlaces(ctrl)
+		planner = NewMockPlanner(ctrl)
+		generator = NewMockFilterGenerator(ctrl)
+		classifier = NewMockClassifier(ctrl)
+		writer = NewMockWriter(ctrl)
+		clock = NewMockClock(ctrl)
+		log = &bytes.Buffer{}
+
+		subject = app.New(places, writer).
+			WithPlanner(planner).
+			WithFilterGenerator(generator).
+			WithClock(clock).
+			WithLog(log)
+
+		opts = app.Options{Query: query}
+
+		clock.EXPECT().Now().Return(start)
+	})
+
+	it.After(func() {
+		ctrl.Finish()
+	})
+
+	expectGeneratedFilter := func() {
+		generator.EXPECT().ClearHistory().Return(nil).Times(2)
+		generator.EXPECT().GenerateFilter(query).Return([]string{"whole foods market"}, []string{"whole foods"}, nil)
+	}
+
+	it("plans, writes and summarizes the search", func() {
+		expectGeneratedFilter()
+		planner.EXPECT().Plan(query, gomock.Any()).DoAndReturn(func(q string, names *filter.Names) (*llm.PlanNode, []types.Location, error) {
+			Expect(names.Keep("Whole Foods Market")).To(BeTrue())
+			Expect(names.Keep("Trader Joe's")).To(BeFalse())
+			return tree, locations, nil
+		})
+		writer.EXPECT().Write(locations).Return(nil)
+		clock.EXPECT().Now().Return(start.Add(3 * time.Second))
+
+		result, summary, err := subject.Search(opts)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(locations))
+		Expect(summary).To(Equal(app.Summary{
+			Query:     query,
+			Plan:      tree,
+			Fetched:   2,
+			Matched:   2,
+			Written:   2,
+			StartedAt: start,
+			Duration:  3 * time.Second,
+		}))
+		Expect(log.String()).To(ContainSubstring("Fetching locations for query: " + query))
+		Expect(log.String()).To(ContainSubstring("Planning tree:\n" + query + " (30 results, 2 pages, 2 kept)"))
+	})
+
+	it("uses the user's include rules instead of generating them", func() {
+		opts.Rules = filter.Rules{Matches: []string{"whole foods market"}, Exclude: []string{"cafe"}}
+
+		generator.EXPECT().ClearHistory().Return(nil).Times(2)
+		planner.EXPECT().Plan(query, gomock.Any()).DoAndReturn(func(q string, names *filter.Names) (*llm.PlanNode, []types.Location, error) {
+			Expect(names.Keep("Whole Foods Market")).To(BeTrue())
+			Expect(names.Keep("Whole Foods Market Cafe")).To(BeFalse())
+			return tree, nil, nil
+		})
+		writer.EXPECT().Write(gomock.Len(0)).Return(nil)
+		clock.EXPECT().Now().Return(start)
+
+		_, _, err := subject.Search(opts)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("fetches the query directly without a planner or filter generator", func() {
+		subject = app.New(places, writer).WithClock(clock)
+
+		stats := types.QueryStats{Query: query, Pages: 1, Results: 2, Kept: 2}
+		places.EXPECT().FetchFiltered(query, gomock.Any()).Return(locations, stats, nil)
+		writer.EXPECT().Write(locations).Return(nil)
+		clock.EXPECT().Now().Return(start)
+
+		_, summary, err := subject.Search(opts)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Plan).To(Equal(&llm.PlanNode{Query: query, Stats: stats}))
+	})
+
+	it("applies the where expression", func() {
+		where, err := filter.Compile("rating >= 4")
+		Expect(err).NotTo(HaveOccurred())
+		opts.Where = where
+
+		expectGeneratedFilter()
+		planner.EXPECT().Plan(query, gomock.Any()).Return(tree, locations, nil)
+		writer.EXPECT().Write(locations[:1]).Return(nil)
+		clock.EXPECT().Now().Return(start)
+
+		_, summary, err := subject.Search(opts)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Fetched).To(Equal(2))
+		Expect(summary.Matched).To(Equal(1))
+		Expect(log.String()).To(ContainSubstring("1 results match rating >= 4"))
+	})
+
+	it("merges duplicates when asked to", func() {
+		opts.Dedupe = true
+		opts.DedupeRadius = 50
+		opts.DedupeSimilarity = 0.8
+
+		pharmacy := types.Location{PlaceId: "c", Name: "Whole Foods Market Pharmacy"}
+		expected := locations[0]
+		expected.Aliases = []string{"b", "c"}
+
+		expectGeneratedFilter()
+		planner.EXPECT().Plan(query, gomock.Any()).Return(tree, append(locations, pharmacy), nil)
+		writer.EXPECT().Write([]types.Location{expected}).Return(nil)
+		clock.EXPECT().Now().Return(start)
+
+		_, summary, err := subject.Search(opts)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(summary.Matched).To(Equal(3))
+		Expect(summary.Written).To(Equal(1))
+		Expect(summary.Dedupe.Duplicates()).To(Equal(2))
+		Expect(log.String()).To(ContainSubstring("Merged 2 duplicates into 1 places"))
+	})
+
+	it("parses the addresses when asked to", func() {
+		opts.ParseAddresses = true
+
+		located := []types.Location{{PlaceId: "a", FormattedAddress: "1555 W Lane Ave, Columbus, OH 43221, United States"}}
+		expected := located[0]
+		expected.Address = &types.Address{
+			Street: "1555 W Lane Ave", City: "Columbus", Region: "OH", PostalCode: "43221", Country: "United States", Confidence: 1,
+		}
+
+		expectGeneratedFilter()
+		planner.EXPECT().Plan(query, gomock.Any()).Return(tree, located, nil)
+		writer.EXPECT().Write([]types.Location{expected}).Return(nil)
+		clock.EXPECT().Now().Return(start)
+
+		result, _, err := subject.Search(opts)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal([]types.Location{expected}))
+		Expect(located[0].Address).To(BeNil())
+	})
+
+	when("classifying", func() {
+		var classified []types.Location
+
+		it.Before(func() {
+			opts.Classify = true
+			subject.WithClassifier(classifier)
+
+			classified = []types.Location{locations[0], locations[1]}
+			classified[0].Relevance = &types.Verdict{Relevant: true}
+			classified[1].Relevance = &types.Verdict{Relevant: false, Reason: "closed"}
+
+			expectGeneratedFilter()
+			planner.EXPECT().Plan(query, gomock.Any()).Return(tree, locations, nil)
+			classifier.EXPECT().Classify(query, locations).Return(classified, nil)
+			clock.EXPECT().Now().Return(start)
+		})
+
+		it("drops irrelevant results", func() {
+			writer.EXPECT().Write(classified[:1]).Return(nil)
+
+			_, summary, err := subject.Search(opts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Classified).To(Equal(2))
+			Expect(summary.Written).To(Equal(1))
+		})
+
+		it("keeps irrelevant results when asked to", func() {
+			opts.KeepIrrelevant = true
+			writer.EXPECT().Write(classified).Return(nil)
+
+			_, summary, err := subject.Search(opts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(summary.Written).To(Equal(2))
+		})
+	})
+
+	when("something goes wrong", func() {
+		it("requires a classifier when classification is requested", func() {
+			opts.Classify = true
+
+			_, _, err := subject.Search(opts)
+			Expect(err).To(MatchError(app.ErrNoClassifier))
+		})
+
+		it("returns the filter generation error", func() {
+			generator.EXPECT().ClearHistory().Return(nil)
+			generator.EXPECT().GenerateFilter(query).Return(nil, nil, errors.New("llm error"))
+
+			_, _, err := subject.Search(opts)
+			Expect(err).To(MatchError("llm error"))
+		})
+
+		it("returns the planning error without writing", func() {
+			expectGeneratedFilter()
+			planner.EXPECT().Plan(query, gomock.Any()).Return(nil, nil, errors.New("REQUEST_DENIED"))
+
+			_, _, err := subject.Search(opts)
+			Expect(err).To(MatchError("REQUEST_DENIED"))
+		})
+
+		it("returns the write error", func() {
+			expectGeneratedFilter()
+			planner.EXPECT().Plan(query, gomock.Any()).Return(tree, locations, nil)
+			writer.EXPECT().Write(locations).Return(errors.New("disk full"))
+			clock.EXPECT().Now().Return(start)
+
+			_, _, err := subject.Search(opts)
+			Expect(err).To(MatchError("disk full"))
+		})
+	})
+}
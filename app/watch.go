@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/watch"
+	"io"
+	"time"
+)
+
+const (
+	DefaultWatchInterval = 24 * time.Hour
+	DefaultQuotaBackoff  = 15 * time.Minute
+)
+
+// ErrStore wraps the errors of the snapshot store, which stop a watch since no run could be compared to the next
+var ErrStore = errors.New("snapshot store error")
+
+//go:generate mockgen -destination=watchmocks_test.go -package=app_test github.com/kardolus/maps/app SnapshotStore,EventSink,Sleeper
+type SnapshotStore interface {
+	Load() (*watch.Snapshot, error)
+	Save(snapshot watch.Snapshot) error
+}
+
+type EventSink interface {
+	Send(events []watch.Event) error
+}
+
+type Sleeper interface {
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+type systemSleeper struct{}
+
+// Sleep waits for the duration or until the context is done, whichever comes first
+func (systemSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type Watcher struct {
+	finder  Finder
+	store   SnapshotStore
+	sink    EventSink
+	clock   Clock
+	sleeper Sleeper
+	every   time.Duration
+	backoff time.Duration
+	log     io.Writer
+}
+
+// NewWatcher re-runs a search through the finder, compares the results to the snapshot of the previous run and sends
+// the changes to the sink
+func NewWatcher(finder Finder, store SnapshotStore, sink EventSink) *Watcher {
+	return &Watcher{
+		finder:  finder,
+		store:   store,
+		sink:    sink,
+		clock:   systemClock{},
+		sleeper: systemSleeper{},
+		every:   DefaultWatchInterval,
+		backoff: DefaultQuotaBackoff,
+		log:     io.Discard,
+	}
+}
+
+// WithEvery configures the time between two runs
+func (w *Watcher) WithEvery(every time.Duration) *Watcher {
+	w.every = every
+	return w
+}
+
+// WithBackoff configures how long to wait after the Places API quota ran out. The wait doubles with every quota error
+// in a row, up to the time between two runs.
+func (w *Watcher) WithBackoff(backoff time.Duration) *Watcher {
+	w.backoff = backoff
+	return w
+}
+
+func (w *Watcher) WithClock(clock Clock) *Watcher {
+	w.clock = clock
+	return w
+}
+
+func (w *Watcher) WithSleeper(sleeper Sleeper) *Watcher {
+	w.sleeper = sleeper
+	return w
+}
+
+// WithLog configures where progress messages are written
+func (w *Watcher) WithLog(log io.Writer) *Watcher {
+	w.log = log
+	return w
+}
+
+// Run scans until the context is done. After a restart, the first scan waits until the interval since the last
+// snapshot has passed. Failed scans are retried at the next interval, or sooner with a growing backoff when the quota
+// ran out. Only store errors stop the watch.
+func (w *Watcher) Run(ctx context.Context, opts Options) error {
+	previous, err := w.store.Load()
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrStore, err)
+	}
+
+	wait := time.Duration(0)
+	if previous != nil {
+		wait = previous.TakenAt.Add(w.every).Sub(w.clock.Now())
+	}
+
+	backoff := w.backoff
+
+	for {
+		if wait > 0 {
+			fmt.Fprintf(w.log, "Next scan at %s\n", w.clock.Now().Add(wait).Format(time.RFC3339))
+			if err := w.sleeper.Sleep(ctx, wait); err != nil {
+				return nil
+			}
+		}
+
+		_, err := w.Scan(opts)
+
+		switch {
+		case errors.Is(err, ErrStore):
+			return err
+		case client.IsQuotaError(err):
+			wait = backoff
+			backoff = min(backoff*2, w.every)
+			fmt.Fprintf(w.log, "Scan failed: %s, retrying in %s\n", err, wait)
+		case err != nil:
+			wait = w.every
+			backoff = w.backoff
+			fmt.Fprintf(w.log, "Scan failed: %s\n", err)
+		default:
+			wait = w.every
+			backoff = w.backoff
+		}
+	}
+}
+
+// Scan runs the search once and sends the changes since the last snapshot. The first scan only saves a snapshot. The
+// snapshot is not replaced when the events could not be sent, so the next scan reports them again.
+func (w *Watcher) Scan(opts Options) ([]watch.Event, error) {
+	previous, err := w.store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStore, err)
+	}
+
+	if previous != nil && previous.Query != opts.Query {
+		return nil, fmt.Errorf("%w: the store holds the snapshots of %q, use another store for %q", ErrStore, previous.Query, opts.Query)
+	}
+
+	locations, _, err := w.finder.Find(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := watch.Snapshot{Query: opts.Query, TakenAt: w.clock.Now(), Locations: locations}
+
+	var events []watch.Event
+	if previous == nil {
+		fmt.Fprintf(w.log, "Saved the first snapshot with %d places\n", len(locations))
+	} else {
+		events = watch.Events(opts.Query, snapshot.TakenAt, diff.New().Compare(previous.Locations, locations))
+		fmt.Fprintf(w.log, "Found %d places, %d changes since %s\n", len(locations), len(events), previous.TakenAt.Format(time.RFC3339))
+	}
+
+	if len(events) > 0 {
+		if err := w.sink.Send(events); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.store.Save(snapshot); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStore, err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,180 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/types"
+	"github.com/kardolus/maps/watch"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitWatch(t *testing.T) {
+	spec.Run(t, "Watch Unit Tests", testWatch, spec.Report(report.Terminal{}))
+}
+
+func testWatch(t *testing.T, when spec.G, it spec.S) {
+	const query = "Whole Foods in Ohio"
+
+	var (
+		ctrl    *gomock.Controller
+		finder  *MockFinder
+		store   *MockSnapshotStore
+		sink    *MockEventSink
+		sleeper *MockSleeper
+		clock   *MockClock
+		log     *bytes.Buffer
+		subject *app.Watcher
+		opts    = app.Options{Query: query}
+		now     = time.Date(2024, 5, 14, 16, 0, 0, 0, time.UTC)
+		ctx     = context.Background()
+		stop    = errors.New("context canceled")
+
+		columbus  = types.Location{PlaceId: "a", Name: "Whole Foods Market", BusinessStatus: "OPERATIONAL"}
+		cleveland = types.Location{PlaceId: "b", Name: "Whole Foods Market", BusinessStatus: "OPERATIONAL"}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		ctrl = gomock.NewController(t)
+		finder = NewMockFinder(ctrl)
+		store = NewMockSnapshotStore(ctrl)
+		sink = NewMockEventSink(ctrl)
+		sleeper = NewMockSleeper(ctrl)
+		clock = NewMockClock(ctrl)
+		log = &bytes.Buffer{}
+
+		subject = app.NewWatcher(finder, store, sink).
+			WithEvery(24 * time.Hour).
+			WithBackoff(time.Hour).
+			WithClock(clock).
+			WithSleeper(sleeper).
+			WithLog(log)
+	})
+
+	it.After(func() {
+		ctrl.Finish()
+	})
+
+	when("scanning", func() {
+		it("saves the first snapshot without events", func() {
+			store.EXPECT().Load().Return(nil, nil)
+			finder.EXPECT().Find(opts).Return([]types.Location{columbus}, app.Summary{}, nil)
+			clock.EXPECT().Now().Return(now)
+			store.EXPECT().Save(watch.Snapshot{Query: query, TakenAt: now, Locations: []types.Location{columbus}}).Return(nil)
+
+			events, err := subject.Scan(opts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(BeEmpty())
+			Expect(log.String()).To(ContainSubstring("Saved the first snapshot with 1 places"))
+		})
+
+		it("sends the changes since the last snapshot", func() {
+			closed := cleveland
+			closed.BusinessStatus = "CLOSED_TEMPORARILY"
+			dayton := types.Location{PlaceId: "c", Name: "Whole Foods Market"}
+			dayton.Geometry.Location.Lat = 39.6359
+
+			previous := &watch.Snapshot{Query: query, TakenAt: now.Add(-24 * time.Hour), Locations: []types.Location{columbus, cleveland}}
+			current := []types.Location{closed, dayton}
+
+			store.EXPECT().Load().Return(previous, nil)
+			finder.EXPECT().Find(opts).Return(current, app.Summary{}, nil)
+			clock.EXPECT().Now().Return(now)
+			sink.EXPECT().Send([]watch.Event{
+				{Type: watch.EventNewPlace, Query: query, DetectedAt: now, Place: dayton},
+				{Type: watch.EventClosedPlace, Query: query, DetectedAt: now, Place: columbus},
+				{Type: watch.EventStatusChange, Query: query, DetectedAt: now, OldStatus: "OPERATIONAL", NewStatus: "CLOSED_TEMPORARILY", Place: closed},
+			}).Return(nil)
+			store.EXPECT().Save(watch.Snapshot{Query: query, TakenAt: now, Locations: current}).Return(nil)
+
+			events, err := subject.Scan(opts)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(HaveLen(3))
+		})
+
+		it("keeps the snapshot when the events cannot be sent", func() {
+			previous := &watch.Snapshot{Query: query, TakenAt: now.Add(-24 * time.Hour), Locations: []types.Location{columbus}}
+
+			store.EXPECT().Load().Return(previous, nil)
+			finder.EXPECT().Find(opts).Return([]types.Location{columbus, cleveland}, app.Summary{}, nil)
+			clock.EXPECT().Now().Return(now)
+			sink.EXPECT().Send(gomock.Len(1)).Return(errors.New("webhook down"))
+
+			_, err := subject.Scan(opts)
+			Expect(err).To(MatchError("webhook down"))
+		})
+
+		it("refuses a store with the snapshots of another query", func() {
+			store.EXPECT().Load().Return(&watch.Snapshot{Query: "Trader Joe's in Ohio"}, nil)
+
+			_, err := subject.Scan(opts)
+			Expect(err).To(MatchError(app.ErrStore))
+			Expect(err).To(MatchError(ContainSubstring(`the store holds the snapshots of "Trader Joe's in Ohio"`)))
+		})
+	})
+
+	when("running", func() {
+		it("waits for the interval since the last snapshot after a restart", func() {
+			previous := &watch.Snapshot{Query: query, TakenAt: now.Add(-20 * time.Hour), Locations: []types.Location{columbus}}
+
+			store.EXPECT().Load().Return(previous, nil)
+			clock.EXPECT().Now().Return(now).Times(2)
+			sleeper.EXPECT().Sleep(ctx, 4*time.Hour).Return(stop)
+
+			Expect(subject.Run(ctx, opts)).To(Succeed())
+			Expect(log.String()).To(ContainSubstring("Next scan at 2024-05-14T20:00:00Z"))
+		})
+
+		it("backs off when the quota runs out", func() {
+			quota := &client.StatusError{Status: client.StatusOverQueryLimit, Query: query}
+
+			store.EXPECT().Load().Return(nil, nil).Times(5)
+			finder.EXPECT().Find(opts).Return(nil, app.Summary{}, quota).Times(3)
+			finder.EXPECT().Find(opts).Return([]types.Location{columbus}, app.Summary{}, nil)
+			clock.EXPECT().Now().Return(now).AnyTimes()
+			store.EXPECT().Save(gomock.Any()).Return(nil)
+
+			gomock.InOrder(
+				sleeper.EXPECT().Sleep(ctx, time.Hour).Return(nil),
+				sleeper.EXPECT().Sleep(ctx, 2*time.Hour).Return(nil),
+				sleeper.EXPECT().Sleep(ctx, 4*time.Hour).Return(nil),
+				sleeper.EXPECT().Sleep(ctx, 24*time.Hour).Return(stop),
+			)
+
+			Expect(subject.Run(ctx, opts)).To(Succeed())
+			Expect(log.String()).To(ContainSubstring("retrying in 1h0m0s"))
+		})
+
+		it("retries other errors at the next interval", func() {
+			store.EXPECT().Load().Return(nil, nil).Times(2)
+			finder.EXPECT().Find(opts).Return(nil, app.Summary{}, errors.New("http status 500"))
+			clock.EXPECT().Now().Return(now).AnyTimes()
+			sleeper.EXPECT().Sleep(ctx, 24*time.Hour).Return(stop)
+
+			Expect(subject.Run(ctx, opts)).To(Succeed())
+			Expect(log.String()).To(ContainSubstring("Scan failed: http status 500"))
+		})
+
+		it("stops on store errors", func() {
+			store.EXPECT().Load().Return(nil, nil).Times(2)
+			finder.EXPECT().Find(opts).Return([]types.Location{columbus}, app.Summary{}, nil)
+			clock.EXPECT().Now().Return(now)
+			store.EXPECT().Save(gomock.Any()).Return(errors.New("disk full"))
+
+			err := subject.Run(ctx, opts)
+			Expect(err).To(MatchError(app.ErrStore))
+			Expect(err).To(MatchError(ContainSubstring("disk full")))
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	errFailedToLoad  = "failed to load cache %s: %w"
+	errFailedToStore = "failed to store cache %s: %w"
+)
+
+// Cache is a key/value store for JSON serializable values. It lives in memory and can optionally be persisted to a
+// file so entries survive between runs.
+type Cache struct {
+	mu      sync.RWMutex
+	entries map[string]json.RawMessage
+	path    string
+	dirty   bool
+}
+
+// New creates an in-memory cache
+func New() *Cache {
+	return &Cache{
+		entries: make(map[string]json.RawMessage),
+	}
+}
+
+// NewFile creates a cache that is backed by the given file. Existing entries are loaded when the file exists.
+func NewFile(path string) (*Cache, error) {
+	c := New()
+	c.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(errFailedToLoad, path, err)
+	}
+
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		return nil, fmt.Errorf(errFailedToLoad, path, err)
+	}
+
+	return c, nil
+}
+
+// Get decodes the entry stored under key into v and reports whether it was found
+func (c *Cache) Get(key string, v interface{}) (bool, error) {
+	c.mu.RLock()
+	raw, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Set stores v under key
+func (c *Cache) Set(key string, v interface{}) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.entries[key] = raw
+	c.dirty = true
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Len returns the number of entries in the cache
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.entries)
+}
+
+// Flush writes the cache to its backing file. It is a no-op for in-memory caches or when nothing changed.
+func (c *Cache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.path == "" || !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf(errFailedToStore, c.path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf(errFailedToStore, c.path, err)
+	}
+
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf(errFailedToStore, c.path, err)
+	}
+
+	c.dirty = false
+
+	return nil
+}
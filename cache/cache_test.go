@@ -0,0 +1,84 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kardolus/maps/cache"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+type entry struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestUnitCache(t *testing.T) {
+	spec.Run(t, "Cache Package Unit Tests", testCache, spec.Report(report.Terminal{}))
+}
+
+func testCache(t *testing.T, when spec.G, it spec.S) {
+	var path string
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		path = filepath.Join(t.TempDir(), "nested", "cache.json")
+	})
+
+	it("returns false for a missing key", func() {
+		var result entry
+
+		ok, err := cache.New().Get("missing", &result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	it("round-trips values in memory", func() {
+		subject := cache.New()
+		Expect(subject.Set("key", entry{Name: "name", Count: 2})).To(Succeed())
+
+		var result entry
+		ok, err := subject.Get("key", &result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(result).To(Equal(entry{Name: "name", Count: 2}))
+		Expect(subject.Len()).To(Equal(1))
+	})
+
+	it("persists entries to the backing file", func() {
+		subject, err := cache.NewFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Set("key", entry{Name: "name"})).To(Succeed())
+		Expect(subject.Flush()).To(Succeed())
+
+		reloaded, err := cache.NewFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var result entry
+		ok, err := reloaded.Get("key", &result)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(result.Name).To(Equal("name"))
+	})
+
+	it("does not write a file when nothing changed", func() {
+		subject, err := cache.NewFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(subject.Flush()).To(Succeed())
+
+		_, err = os.Stat(path)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	it("returns an error for a corrupt file", func() {
+		Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+		Expect(os.WriteFile(path, []byte("{"), 0644)).To(Succeed())
+
+		_, err := cache.NewFile(path)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("failed to load cache"))
+	})
+}
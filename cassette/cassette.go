@@ -0,0 +1,336 @@
+// Package cassette records HTTP interactions with the Places API and the LLM into files and replays them without
+// network access. API keys are scrubbed before anything is written to disk.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	gpthttp "github.com/kardolus/chatgpt-cli/http"
+	gpttypes "github.com/kardolus/chatgpt-cli/types"
+	"github.com/kardolus/maps/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Mode int
+
+const (
+	ModeReplay Mode = iota
+	ModeRecord
+)
+
+const (
+	Redacted           = "REDACTED"
+	methodGet          = "GET"
+	methodPost         = "POST"
+	errNoInteraction   = "cassette %s has no recorded %s %s"
+	errRequestDrift    = "cassette %s has no recorded %s %s with this request body, the request drifted from the recording:\n%s"
+	errFailedToLoad    = "failed to load cassette %s: %w"
+	errFailedToSave    = "failed to save cassette %s: %w"
+	errReplayOnly      = "cassette %s is in replay mode"
+	errMissingUpstream = "cassette %s has no upstream caller to record"
+)
+
+// secretParams are query parameters whose values never make it into a cassette
+var secretParams = []string{"key", "api_key", "apikey"}
+
+// Interaction is a single recorded request and its outcome
+type Interaction struct {
+	Method   string          `json:"method"`
+	URL      string          `json:"url"`
+	Request  json.RawMessage `json:"request,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type Cassette struct {
+	Name string `json:"name"`
+	// RecordedAt is when the cassette was recorded, and is left out of cassettes that were written by hand
+	RecordedAt *time.Time `json:"recorded_at,omitempty"`
+	// Synthetic marks cassettes that were written by hand rather than recorded from the real services
+	Synthetic    bool          `json:"synthetic,omitempty"`
+	Interactions []Interaction `json:"interactions"`
+
+	mu      sync.Mutex
+	path    string
+	mode    Mode
+	secrets []string
+	played  map[string]int
+}
+
+// Load opens the cassette at path. In replay mode the file must exist. In record mode any existing interactions are
+// discarded and replaced by the ones recorded during this run when Save is called.
+func Load(path string, mode Mode) (*Cassette, error) {
+	c := &Cassette{
+		Name:   strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		path:   path,
+		mode:   mode,
+		played: make(map[string]int),
+	}
+
+	if mode == ModeRecord {
+		now := time.Now().UTC()
+		c.RecordedAt = &now
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(errFailedToLoad, path, err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf(errFailedToLoad, path, err)
+	}
+
+	return c, nil
+}
+
+// WithSecret scrubs the value from everything that is recorded, in addition to the API key query parameters
+func (c *Cassette) WithSecret(secret string) *Cassette {
+	if secret != "" {
+		c.secrets = append(c.secrets, secret)
+	}
+	return c
+}
+
+// Mode returns whether the cassette records or replays
+func (c *Cassette) Mode() Mode {
+	return c.mode
+}
+
+// Places wraps a Places API caller. In replay mode next may be nil.
+func (c *Cassette) Places(next http.Caller) http.Caller {
+	return &placesCaller{cassette: c, next: next}
+}
+
+// LLM wraps the caller factory used by the ChatGPT client. In replay mode the real caller is never used.
+func (c *Cassette) LLM(factory gpthttp.CallerFactory) gpthttp.CallerFactory {
+	return func(cfg gpttypes.Config) gpthttp.Caller {
+		var next gpthttp.Caller
+		if c.mode == ModeRecord {
+			next = factory(cfg)
+		}
+		return &llmCaller{cassette: c, next: next}
+	}
+}
+
+// Save writes the recorded interactions to disk. It fails in replay mode.
+func (c *Cassette) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.mode != ModeRecord {
+		return fmt.Errorf(errReplayOnly, c.Name)
+	}
+
+	// keep URLs readable, the default encoder escapes "&" in query strings
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(c); err != nil {
+		return fmt.Errorf(errFailedToSave, c.path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf(errFailedToSave, c.path, err)
+	}
+
+	return os.WriteFile(c.path, buf.Bytes(), 0644)
+}
+
+// do records or replays a single interaction
+func (c *Cassette) do(method, rawURL string, body []byte, call func() ([]byte, error)) ([]byte, error) {
+	sanitizedURL := c.sanitizeURL(rawURL)
+
+	if c.mode == ModeReplay {
+		return c.replay(method, sanitizedURL, c.sanitizeJSON(body))
+	}
+
+	if call == nil {
+		return nil, fmt.Errorf(errMissingUpstream, c.Name)
+	}
+
+	response, err := call()
+
+	interaction := Interaction{
+		Method:  method,
+		URL:     sanitizedURL,
+		Request: c.sanitizeJSON(body),
+	}
+
+	if err != nil {
+		interaction.Error = c.sanitize(err.Error())
+	} else {
+		interaction.Response = c.sanitizeJSON(response)
+	}
+
+	c.mu.Lock()
+	c.Interactions = append(c.Interactions, interaction)
+	c.mu.Unlock()
+
+	return response, err
+}
+
+// replay returns the next unplayed interaction for the method, URL and request body. Identical requests are served in
+// the order they were recorded, which keeps retries and polling deterministic. Bodies are compared as JSON documents, so
+// a changed prompt or request shape fails instead of replaying a stale response.
+func (c *Cassette) replay(method, sanitizedURL string, request json.RawMessage) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	body := canonical(request)
+	key := method + " " + sanitizedURL + " " + string(body)
+	skip := c.played[key]
+
+	var drifted json.RawMessage
+	for _, interaction := range c.Interactions {
+		if interaction.Method != method || interaction.URL != sanitizedURL {
+			continue
+		}
+
+		if recorded := canonical(interaction.Request); !bytes.Equal(recorded, body) {
+			drifted = recorded
+			continue
+		}
+
+		if skip > 0 {
+			skip--
+			continue
+		}
+
+		c.played[key]++
+
+		if interaction.Error != "" {
+			return nil, errors.New(interaction.Error)
+		}
+
+		return decodeResponse(interaction.Response), nil
+	}
+
+	if drifted != nil {
+		return nil, fmt.Errorf(errRequestDrift, c.Name, method, sanitizedURL, body)
+	}
+
+	return nil, fmt.Errorf(errNoInteraction, c.Name, method, sanitizedURL)
+}
+
+// canonical re-encodes the JSON with sorted keys, so documents that only differ in formatting, key order or escaping
+// compare equal. Anything that is not JSON is returned as is.
+func canonical(data json.RawMessage) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return data
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return data
+	}
+	return encoded
+}
+
+func (c *Cassette) sanitizeURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return c.sanitize(rawURL)
+	}
+
+	query := parsed.Query()
+	for _, param := range secretParams {
+		if query.Has(param) {
+			query.Set(param, Redacted)
+		}
+	}
+
+	parsed.RawQuery = query.Encode()
+
+	return c.sanitize(parsed.String())
+}
+
+func (c *Cassette) sanitize(value string) string {
+	for _, secret := range c.secrets {
+		value = strings.ReplaceAll(value, secret, Redacted)
+	}
+	return value
+}
+
+// sanitizeJSON scrubs secrets and stores valid JSON as is, anything else as a JSON string
+func (c *Cassette) sanitizeJSON(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+
+	value := c.sanitize(string(data))
+
+	if json.Valid([]byte(value)) {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, []byte(value)); err == nil {
+			return buf.Bytes()
+		}
+	}
+
+	encoded, _ := json.Marshal(value)
+	return encoded
+}
+
+// decodeResponse undoes sanitizeJSON: strings come back verbatim, JSON documents compacted as they were recorded
+func decodeResponse(raw json.RawMessage) []byte {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []byte(text)
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return raw
+	}
+
+	return buf.Bytes()
+}
+
+type placesCaller struct {
+	cassette *Cassette
+	next     http.Caller
+}
+
+func (p *placesCaller) Get(url string) ([]byte, error) {
+	var call func() ([]byte, error)
+	if p.next != nil {
+		call = func() ([]byte, error) { return p.next.Get(url) }
+	}
+	return p.cassette.do(methodGet, url, nil, call)
+}
+
+type llmCaller struct {
+	cassette *Cassette
+	next     gpthttp.Caller
+}
+
+func (l *llmCaller) Get(url string) ([]byte, error) {
+	var call func() ([]byte, error)
+	if l.next != nil {
+		call = func() ([]byte, error) { return l.next.Get(url) }
+	}
+	return l.cassette.do(methodGet, url, nil, call)
+}
+
+func (l *llmCaller) Post(url string, body []byte, stream bool) ([]byte, error) {
+	var call func() ([]byte, error)
+	if l.next != nil {
+		call = func() ([]byte, error) { return l.next.Post(url, body, stream) }
+	}
+	return l.cassette.do(methodPost, url, body, call)
+}
@@ -0,0 +1,237 @@
+package cassette_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	gpthttp "github.com/kardolus/chatgpt-cli/http"
+	gpttypes "github.com/kardolus/chatgpt-cli/types"
+	"github.com/kardolus/maps/cassette"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+//go:generate mockgen -destination=callermocks_test.go -package=cassette_test github.com/kardolus/maps/http Caller
+
+const (
+	placesURL    = "https://maps.googleapis.com/maps/api/place/textsearch/json?query=Whole+Foods&key=secret-key"
+	sanitizedURL = "https://maps.googleapis.com/maps/api/place/textsearch/json?key=REDACTED&query=Whole+Foods"
+	llmURL       = "https://api.openai.com/v1/chat/completions"
+)
+
+type fakeLLMCaller struct {
+	bodies [][]byte
+}
+
+func (f *fakeLLMCaller) Get(url string) ([]byte, error) {
+	return []byte(`{"data": []}`), nil
+}
+
+func (f *fakeLLMCaller) Post(url string, body []byte, stream bool) ([]byte, error) {
+	f.bodies = append(f.bodies, body)
+	return []byte(`{"choices": [{"message": {"content": "search [1]: Whole Foods in Ohio"}}]}`), nil
+}
+
+func TestUnitCassette(t *testing.T) {
+	spec.Run(t, "Cassette Package Unit Tests", testCassette, spec.Report(report.Terminal{}))
+}
+
+func testCassette(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mockCtrl   *gomock.Controller
+		mockCaller *MockCaller
+		path       string
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		mockCtrl = gomock.NewController(t)
+		mockCaller = NewMockCaller(mockCtrl)
+		path = filepath.Join(t.TempDir(), "cassettes", "places.json")
+	})
+
+	it.After(func() {
+		mockCtrl.Finish()
+	})
+
+	when("recording", func() {
+		it("scrubs API keys and secrets before saving", func() {
+			mockCaller.EXPECT().Get(placesURL).Return([]byte(`{"results": [{"name": "Whole Foods", "note": "token other-secret"}], "status": "OK"}`), nil)
+			mockCaller.EXPECT().Get(placesURL).Return(nil, fmt.Errorf("http status 500: other-secret"))
+
+			subject, err := cassette.Load(path, cassette.ModeRecord)
+			Expect(err).NotTo(HaveOccurred())
+			subject.WithSecret("other-secret")
+
+			caller := subject.Places(mockCaller)
+
+			response, err := caller.Get(placesURL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(response)).To(ContainSubstring("other-secret")) // the caller still sees the real response
+
+			_, err = caller.Get(placesURL)
+			Expect(err).To(HaveOccurred())
+
+			Expect(subject.Save()).To(Succeed())
+
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).NotTo(ContainSubstring("secret-key"))
+			Expect(string(data)).NotTo(ContainSubstring("other-secret"))
+			Expect(string(data)).To(ContainSubstring(sanitizedURL))
+			Expect(string(data)).To(ContainSubstring(`"error": "http status 500: REDACTED"`))
+		})
+
+		it("records LLM requests and responses", func() {
+			upstream := &fakeLLMCaller{}
+			factory := func(cfg gpttypes.Config) gpthttp.Caller { return upstream }
+
+			subject, err := cassette.Load(path, cassette.ModeRecord)
+			Expect(err).NotTo(HaveOccurred())
+
+			caller := subject.LLM(factory)(gpttypes.Config{})
+			_, err = caller.Post(llmURL, []byte(`{"messages": [{"role": "user", "content": "hi"}]}`), false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(upstream.bodies).To(HaveLen(1))
+
+			Expect(subject.Interactions).To(HaveLen(1))
+			Expect(subject.Interactions[0].Method).To(Equal("POST"))
+			Expect(string(subject.Interactions[0].Request)).To(Equal(`{"messages":[{"role":"user","content":"hi"}]}`))
+		})
+	})
+
+	when("replaying", func() {
+		it.Before(func() {
+			recorder, err := cassette.Load(path, cassette.ModeRecord)
+			Expect(err).NotTo(HaveOccurred())
+
+			mockCaller.EXPECT().Get(placesURL).Return([]byte(`{"status": "INVALID_REQUEST"}`), nil)
+			mockCaller.EXPECT().Get(placesURL).Return([]byte(`{"status": "OK"}`), nil)
+			mockCaller.EXPECT().Get("https://example.com/plain").Return([]byte("not json"), nil)
+
+			caller := recorder.Places(mockCaller)
+			_, _ = caller.Get(placesURL)
+			_, _ = caller.Get(placesURL)
+			_, _ = caller.Get("https://example.com/plain")
+
+			upstream := &fakeLLMCaller{}
+			_, _ = recorder.LLM(func(cfg gpttypes.Config) gpthttp.Caller { return upstream })(gpttypes.Config{}).Post(llmURL, []byte(`{}`), false)
+
+			Expect(recorder.Save()).To(Succeed())
+		})
+
+		it("serves identical requests in recorded order without calling upstream", func() {
+			subject, err := cassette.Load(path, cassette.ModeReplay)
+			Expect(err).NotTo(HaveOccurred())
+
+			caller := subject.Places(nil)
+
+			response, err := caller.Get("https://maps.googleapis.com/maps/api/place/textsearch/json?query=Whole+Foods&key=another-key")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(response)).To(Equal(`{"status":"INVALID_REQUEST"}`))
+
+			response, err = caller.Get(placesURL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(response)).To(Equal(`{"status":"OK"}`))
+
+			response, err = caller.Get("https://example.com/plain")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(response)).To(Equal("not json"))
+
+			_, err = caller.Get(placesURL)
+			Expect(err).To(MatchError(ContainSubstring("cassette places has no recorded GET " + sanitizedURL)))
+		})
+
+		it("replays LLM calls without creating the real caller", func() {
+			subject, err := cassette.Load(path, cassette.ModeReplay)
+			Expect(err).NotTo(HaveOccurred())
+
+			factory := subject.LLM(func(cfg gpttypes.Config) gpthttp.Caller {
+				panic("the real caller must not be created in replay mode")
+			})
+
+			response, err := factory(gpttypes.Config{}).Post(llmURL, []byte(`{ }`), false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(response)).To(ContainSubstring("Whole Foods in Ohio"))
+		})
+
+		it("does not replay a request whose body drifted from the recording", func() {
+			subject, err := cassette.Load(path, cassette.ModeReplay)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = subject.LLM(nil)(gpttypes.Config{}).Post(llmURL, []byte(`{"different": "body"}`), false)
+			Expect(err).To(MatchError(ContainSubstring("cassette places has no recorded POST " + llmURL + " with this request body")))
+			Expect(err).To(MatchError(ContainSubstring(`{"different":"body"}`)))
+		})
+
+		it("marks recorded cassettes with their recording time", func() {
+			subject, err := cassette.Load(path, cassette.ModeReplay)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subject.RecordedAt).NotTo(BeNil())
+			Expect(subject.Synthetic).To(BeFalse())
+		})
+
+		it("cannot be saved", func() {
+			subject, err := cassette.Load(path, cassette.ModeReplay)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(subject.Save()).To(MatchError("cassette places is in replay mode"))
+		})
+	})
+
+	it("fails to replay a missing cassette", func() {
+		_, err := cassette.Load(path, cassette.ModeReplay)
+		Expect(err).To(MatchError(ContainSubstring("failed to load cassette")))
+	})
+
+	when("comparing schemas", func() {
+		it("describes the shape of a document independent of array length", func() {
+			schema, err := cassette.Schema([]byte(`{"results": [{"name": "a", "rating": 4.5}, {"name": "b", "open": true, "price": null}], "status": "OK"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(schema).To(Equal([]string{
+				"results: array",
+				"results[].name: string",
+				"results[].open: boolean",
+				"results[].price: null",
+				"results[].rating: number",
+				"results[]: object",
+				"status: string",
+			}))
+		})
+
+		it("reports added and removed fields", func() {
+			committed := &cassette.Cassette{Interactions: []cassette.Interaction{
+				{Method: "GET", URL: sanitizedURL, Response: []byte(`{"results": [{"name": "a", "reference": "r"}]}`)},
+			}}
+			fresh := &cassette.Cassette{Interactions: []cassette.Interaction{
+				{Method: "GET", URL: sanitizedURL, Response: []byte(`{"results": [{"name": "a", "business_status": "OPERATIONAL"}]}`)},
+			}}
+
+			drift, err := cassette.Drift(committed, fresh)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift).To(Equal([]string{
+				"+ results[].business_status: string (GET " + sanitizedURL + ")",
+				"- results[].reference: string (GET " + sanitizedURL + ")",
+			}))
+
+			drift, err = cassette.Drift(committed, committed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift).To(BeEmpty())
+		})
+
+		it("reports a changed sequence of requests", func() {
+			committed := &cassette.Cassette{Interactions: []cassette.Interaction{{Method: "GET", URL: "a"}}}
+			fresh := &cassette.Cassette{Interactions: []cassette.Interaction{{Method: "GET", URL: "b"}, {Method: "GET", URL: "c"}}}
+
+			drift, err := cassette.Drift(committed, fresh)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(drift).To(Equal([]string{
+				"~ interaction count changed from 1 to 2",
+				"~ interaction 0 changed from GET a to GET b",
+			}))
+		})
+	})
+}
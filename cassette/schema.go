@@ -0,0 +1,134 @@
+package cassette
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Schema describes the shape of a JSON document as a sorted list of "path: type" entries, e.g.
+// "results[].geometry.location.lat: number". Array elements are merged, so the schema does not depend on how many
+// results were returned.
+func Schema(data []byte) ([]string, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]struct{})
+	walk(value, "", paths)
+
+	var result []string
+	for path := range paths {
+		result = append(result, path)
+	}
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// Drift compares the response schemas of a committed cassette with a fresh recording. Interactions are paired by
+// position. Every entry of the result is prefixed with "+" for paths that appeared upstream or "-" for paths that
+// disappeared.
+func Drift(committed, fresh *Cassette) ([]string, error) {
+	var result []string
+
+	if len(committed.Interactions) != len(fresh.Interactions) {
+		result = append(result, fmt.Sprintf("~ interaction count changed from %d to %d", len(committed.Interactions), len(fresh.Interactions)))
+	}
+
+	for i := 0; i < len(committed.Interactions) && i < len(fresh.Interactions); i++ {
+		before, after := committed.Interactions[i], fresh.Interactions[i]
+
+		if before.Method+before.URL != after.Method+after.URL {
+			result = append(result, fmt.Sprintf("~ interaction %d changed from %s %s to %s %s", i, before.Method, before.URL, after.Method, after.URL))
+			continue
+		}
+
+		diff, err := diffSchemas(before.Response, after.Response)
+		if err != nil {
+			return nil, fmt.Errorf("interaction %d: %w", i, err)
+		}
+
+		for _, line := range diff {
+			result = append(result, fmt.Sprintf("%s (%s %s)", line, after.Method, after.URL))
+		}
+	}
+
+	return result, nil
+}
+
+func diffSchemas(before, after json.RawMessage) ([]string, error) {
+	if len(before) == 0 || len(after) == 0 {
+		return nil, nil
+	}
+
+	old, err := Schema(decodeResponse(before))
+	if err != nil {
+		return nil, err
+	}
+
+	fresh, err := Schema(decodeResponse(after))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []string
+
+	for _, path := range difference(fresh, old) {
+		result = append(result, "+ "+path)
+	}
+	for _, path := range difference(old, fresh) {
+		result = append(result, "- "+path)
+	}
+
+	return result, nil
+}
+
+// difference returns the entries of a that are not in b
+func difference(a, b []string) []string {
+	seen := make(map[string]struct{}, len(b))
+	for _, item := range b {
+		seen[item] = struct{}{}
+	}
+
+	var result []string
+	for _, item := range a {
+		if _, ok := seen[item]; !ok {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func walk(value interface{}, path string, paths map[string]struct{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if path != "" {
+			paths[path+": object"] = struct{}{}
+		}
+		for key, child := range v {
+			walk(child, join(path, key), paths)
+		}
+	case []interface{}:
+		paths[path+": array"] = struct{}{}
+		for _, child := range v {
+			walk(child, path+"[]", paths)
+		}
+	case string:
+		paths[path+": string"] = struct{}{}
+	case float64:
+		paths[path+": number"] = struct{}{}
+	case bool:
+		paths[path+": boolean"] = struct{}{}
+	case nil:
+		paths[path+": null"] = struct{}{}
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -2,23 +2,80 @@ package client
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/kardolus/maps/filter"
 	"github.com/kardolus/maps/http"
 	"github.com/kardolus/maps/types"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	Endpoint         = "https://maps.googleapis.com/maps/api/place/textsearch/json?query=%s&key=%s"
-	NextPageEndpoint = "https://maps.googleapis.com/maps/api/place/textsearch/json?pagetoken=%s&key=%s"
+	BaseURL          = "https://maps.googleapis.com"
+	TextSearchPath   = "/maps/api/place/textsearch/json"
+	NearbySearchPath = "/maps/api/place/nearbysearch/json"
+	DetailsPath      = "/maps/api/place/details/json"
+	queryParams      = "?query=%s&key=%s"
+	pageTokenParams  = "?pagetoken=%s&key=%s"
+	Endpoint         = BaseURL + TextSearchPath + queryParams
+	NextPageEndpoint = BaseURL + TextSearchPath + pageTokenParams
 	ErrMissingEntity = "entity required"
+	ErrMissingPlace  = "place id required"
+	errStatus        = "places api returned %s for %q"
+	errStatusMessage = "places api returned %s for %q: %s"
+	maxTokenRetries  = 3
+	Provider         = "google_places"
+	DefaultTimeout   = 5000
 )
 
+// Places API response statuses, see https://developers.google.com/maps/documentation/places/web-service/search-text#PlacesSearchStatus
+const (
+	StatusOK             = "OK"
+	StatusZeroResults    = "ZERO_RESULTS"
+	StatusInvalidRequest = "INVALID_REQUEST"
+	StatusOverQueryLimit = "OVER_QUERY_LIMIT"
+	StatusRequestDenied  = "REQUEST_DENIED"
+	StatusUnknownError   = "UNKNOWN_ERROR"
+	StatusNotFound       = "NOT_FOUND"
+)
+
+// StatusError is returned when the Places API responds with a status other than OK or ZERO_RESULTS
+type StatusError struct {
+	Status  string
+	Message string
+	Query   string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf(errStatus, e.Status, e.Query)
+	}
+	return fmt.Sprintf(errStatusMessage, e.Status, e.Query, e.Message)
+}
+
+// IsQuotaError reports whether the error signals that the API key ran out of quota
+func IsQuotaError(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Status == StatusOverQueryLimit
+}
+
 type Client struct {
-	caller  http.Caller
-	timeout int
-	apiKey  string
+	caller     http.Caller
+	timeout    int
+	keys       *Keys
+	baseURL    string
+	language   string
+	region     string
+	provenance bool
+}
+
+// WithBaseURL points the client at a different Places API host, e.g. a local fake
+func (c *Client) WithBaseURL(url string) *Client {
+	c.baseURL = strings.TrimRight(url, "/")
+	return c
 }
 
 // WithTimeout configures the timeout in milliseconds for the pagination loop
@@ -27,92 +84,258 @@ func (c *Client) WithTimeout(timeout int) *Client {
 	return c
 }
 
+// WithLanguage asks the Places API for names and addresses in the language, e.g. de
+func (c *Client) WithLanguage(language string) *Client {
+	c.language = language
+	return c
+}
+
+// WithRegion biases the results of text searches towards the region, a ccTLD code such as de
+func (c *Client) WithRegion(region string) *Client {
+	c.region = region
+	return c
+}
+
+// WithProvenance records on every location the query and page that returned it, see types.Provenance
+func (c *Client) WithProvenance(enabled bool) *Client {
+	c.provenance = enabled
+	return c
+}
+
+// WithKeys spreads the requests over a pool of keys instead of the key given to New. An empty pool is ignored.
+func (c *Client) WithKeys(keys *Keys) *Client {
+	if keys.Len() > 0 {
+		c.keys = keys
+	}
+	return c
+}
+
 func New(caller http.Caller, apiKey string) *Client {
 	return &Client{
-		caller: caller,
-		apiKey: apiKey,
+		caller:  caller,
+		keys:    NewKeys(apiKey),
+		baseURL: BaseURL,
 	}
 }
 
-func (c *Client) FetchAllLocations(queries, contains, matches []string) ([]types.Location, error) {
-	fmt.Printf("queries %v\n", queries)                          // TODO move to debug
-	fmt.Printf("contains: %v\nmatches: %v\n", contains, matches) // TODO move to debug
+func (c *Client) FetchLocations(entity string, contains, matches []string) ([]types.Location, error) {
+	result, _, err := c.FetchLocationsWithStats(entity, contains, matches)
+	return result, err
+}
 
-	var result []types.Location
+// FetchLocationsWithStats behaves like FetchLocations and additionally reports how many pages and unfiltered results
+// the Places API returned for the query
+func (c *Client) FetchLocationsWithStats(entity string, contains, matches []string) ([]types.Location, types.QueryStats, error) {
+	return c.FetchFiltered(entity, filter.NewNames(contains, matches))
+}
 
-	found := make(map[string]struct{})
+// FetchFiltered fetches every page of results for the query and keeps the locations whose name passes the rules. The
+// same rules are applied to every page.
+func (c *Client) FetchFiltered(entity string, names *filter.Names) ([]types.Location, types.QueryStats, error) {
+	if entity == "" {
+		return nil, types.QueryStats{Query: entity}, fmt.Errorf(ErrMissingEntity)
+	}
 
-	for _, query := range queries {
-		locations, err := c.FetchLocations(query, contains, matches)
+	return c.paginate(entity, TextSearchPath, func(key string) string {
+		return c.constructURL(entity, key)
+	}, names)
+}
 
-		if err != nil {
-			return nil, err
-		}
+// NearbyQuery describes a search for places within a radius of a point
+type NearbyQuery struct {
+	Lat     float64
+	Lng     float64
+	Radius  float64 // meters
+	Keyword string
+	Type    string
+}
 
-		for _, location := range locations {
-			if _, ok := found[location.PlaceId]; !ok {
-				result = append(result, location)
-				found[location.PlaceId] = struct{}{}
-			}
-		}
+func (q NearbyQuery) String() string {
+	result := fmt.Sprintf("%s within %gm of %g,%g", q.Type, q.Radius, q.Lat, q.Lng)
+	if q.Keyword != "" {
+		result = q.Keyword + " " + result
 	}
+	return strings.TrimSpace(result)
+}
 
-	return result, nil
+// Nearby fetches every page of places within the radius, optionally matching a keyword and a place type, and keeps
+// the locations whose name passes the rules
+func (c *Client) Nearby(query NearbyQuery, names *filter.Names) ([]types.Location, types.QueryStats, error) {
+	if query.Radius <= 0 {
+		return nil, types.QueryStats{Query: query.String()}, fmt.Errorf("radius must be a positive number of meters")
+	}
+
+	params := url.Values{}
+	params.Set("location", fmt.Sprintf("%g,%g", query.Lat, query.Lng))
+	params.Set("radius", strconv.FormatFloat(query.Radius, 'f', -1, 64))
+	if query.Keyword != "" {
+		params.Set("keyword", query.Keyword)
+	}
+	if query.Type != "" {
+		params.Set("type", query.Type)
+	}
+	if c.language != "" {
+		params.Set("language", c.language)
+	}
+
+	return c.paginate(query.String(), NearbySearchPath, func(key string) string {
+		params.Set("key", key)
+		return c.baseURL + NearbySearchPath + "?" + params.Encode()
+	}, names)
 }
 
-func (c *Client) FetchLocations(entity string, contains, matches []string) ([]types.Location, error) {
-	var (
-		result []types.Location
-		record types.Response
-	)
+// Details fetches a single place by its id
+func (c *Client) Details(placeId string) (types.Location, error) {
+	if placeId == "" {
+		return types.Location{}, fmt.Errorf(ErrMissingPlace)
+	}
 
-	if entity == "" {
-		return nil, fmt.Errorf(ErrMissingEntity)
+	var result types.Location
+	err := c.withKey(func(key string) (err error) {
+		result, err = c.details(placeId, key)
+		return err
+	})
+
+	return result, err
+}
+
+func (c *Client) details(placeId, key string) (types.Location, error) {
+	params := url.Values{}
+	params.Set("place_id", placeId)
+	params.Set("key", key)
+	if c.language != "" {
+		params.Set("language", c.language)
 	}
 
-	bytes, err := c.caller.Get(c.constructURL(entity))
+	c.keys.Used(key)
+	bytes, err := c.caller.Get(c.baseURL + DetailsPath + "?" + params.Encode())
 	if err != nil {
-		return nil, err
+		return types.Location{}, err
 	}
 
+	var record types.DetailsResponse
 	if err := json.Unmarshal(bytes, &record); err != nil {
-		return nil, err
+		return types.Location{}, err
 	}
 
-	if len(contains) == 0 && len(matches) == 0 {
-		result = record.Results
-	} else {
-		for _, location := range record.Results {
-			if containsAny(location.Name, contains) || matchesAny(location.Name, matches) {
-				result = append(result, location)
-			}
+	if err := checkStatus(record.Status, record.ErrorMessage, placeId); err != nil {
+		return types.Location{}, err
+	}
+
+	if record.Status == StatusZeroResults {
+		return types.Location{}, &StatusError{Status: StatusNotFound, Query: placeId}
+	}
+
+	return record.Result, nil
+}
+
+// paginate runs the search at path with a key of the pool, starting over with the next key when the Places API
+// rejects one. The url of the first page is built for the key.
+func (c *Client) paginate(entity, path string, first func(key string) string, names *filter.Names) ([]types.Location, types.QueryStats, error) {
+	var (
+		result []types.Location
+		stats  types.QueryStats
+	)
+
+	err := c.withKey(func(key string) (err error) {
+		result, stats, err = c.fetchPages(entity, path, key, first(key), names)
+		return err
+	})
+
+	return result, stats, err
+}
+
+// withKey runs the request with the next key of the pool. When the key is out of quota or denied, the request is sent
+// again with the other keys until one works.
+func (c *Client) withKey(request func(key string) error) error {
+	var err error
+
+	for attempt := 0; attempt < max(1, c.keys.Len()); attempt++ {
+		key := c.keys.Next()
+
+		err = request(key)
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && (statusErr.Status == StatusOverQueryLimit || statusErr.Status == StatusRequestDenied) {
+			c.keys.Fail(key, statusErr.Status)
+			continue
 		}
+
+		return err
 	}
 
-	// Paginate through results using next_page_token
-	for record.NextPageToken != "" {
-		time.Sleep(time.Duration(c.timeout) * time.Millisecond)
+	return err
+}
+
+// fetchPages follows the next_page_token of the search at path, starting with url. A token is only valid for the key
+// that issued it, so every page is requested with the same key.
+func (c *Client) fetchPages(entity, path, key, url string, names *filter.Names) ([]types.Location, types.QueryStats, error) {
+	var (
+		result []types.Location
+		record types.Response
+	)
 
-		var nextRecord types.Response
-		bytes, err := c.caller.Get(c.constructNextURL(record.NextPageToken))
+	stats := types.QueryStats{Query: entity}
+	retries := 0
+
+	for {
+		c.keys.Used(key)
+		bytes, err := c.caller.Get(url)
 		if err != nil {
-			return nil, err
+			return nil, stats, err
+		}
+
+		record = types.Response{}
+		if err := json.Unmarshal(bytes, &record); err != nil {
+			return nil, stats, err
 		}
 
-		if err := json.Unmarshal(bytes, &nextRecord); err != nil {
-			return nil, err
+		// A next_page_token only becomes valid after a short delay, until then the API answers INVALID_REQUEST
+		if stats.Pages > 0 && record.Status == StatusInvalidRequest && retries < maxTokenRetries {
+			retries++
+			time.Sleep(time.Duration(c.timeout) * time.Millisecond)
+			continue
 		}
 
-		for _, location := range nextRecord.Results {
-			if containsAny(location.Name, contains) || matchesAny(location.Name, matches) {
-				result = append(result, location)
+		if err := checkStatus(record.Status, record.ErrorMessage, entity); err != nil {
+			return nil, stats, err
+		}
+
+		stats.Pages++
+		stats.Results += len(record.Results)
+		fetchedAt := time.Now().UTC()
+
+		for _, location := range record.Results {
+			rule, ok := names.Match(location.Name)
+			if !ok {
+				continue
+			}
+
+			if c.provenance {
+				location.Provenance = &types.Provenance{
+					Query:     entity,
+					Page:      stats.Pages,
+					FetchedAt: fetchedAt,
+					Provider:  Provider,
+					Rule:      rule,
+				}
 			}
+			result = append(result, location)
 		}
 
-		record = nextRecord
+		// Paginate through results using next_page_token
+		if record.NextPageToken == "" {
+			break
+		}
+
+		time.Sleep(time.Duration(c.timeout) * time.Millisecond)
+		url = c.constructNextURL(path, record.NextPageToken, key)
+		retries = 0
 	}
 
-	return result, nil
+	stats.Kept = len(result)
+
+	return result, stats, nil
 }
 
 func (c *Client) buildQuery(entity string) string {
@@ -120,31 +343,30 @@ func (c *Client) buildQuery(entity string) string {
 	return strings.Join(words, "+")
 }
 
-func (c *Client) constructURL(entity string) string {
+func (c *Client) constructURL(entity, key string) string {
 	query := c.buildQuery(entity)
-	return fmt.Sprintf(Endpoint, query, c.apiKey)
-}
+	result := fmt.Sprintf(c.baseURL+TextSearchPath+queryParams, query, key)
+
+	if c.language != "" {
+		result += "&language=" + url.QueryEscape(c.language)
+	}
+	if c.region != "" {
+		result += "&region=" + url.QueryEscape(c.region)
+	}
 
-func (c *Client) constructNextURL(token string) string {
-	return fmt.Sprintf(NextPageEndpoint, token, c.apiKey)
+	return result
 }
 
-func containsAny(name string, list []string) bool {
-	for _, item := range list {
-		item = strings.TrimSpace(item)
-		if strings.Contains(strings.ToLower(name), strings.ToLower(item)) {
-			return true
-		}
-	}
-	return false
+func (c *Client) constructNextURL(path, token, key string) string {
+	return fmt.Sprintf(c.baseURL+path+pageTokenParams, token, key)
 }
 
-func matchesAny(name string, list []string) bool {
-	for _, item := range list {
-		item = strings.TrimSpace(item)
-		if strings.ToLower(name) == strings.ToLower(item) {
-			return true
-		}
+// checkStatus converts an error status into a StatusError. An empty status is treated as OK.
+func checkStatus(status, message, entity string) error {
+	switch status {
+	case "", StatusOK, StatusZeroResults:
+		return nil
 	}
-	return false
+
+	return &StatusError{Status: status, Message: message, Query: entity}
 }
@@ -1,14 +1,17 @@
 package client_test
 
 import (
+	"errors"
 	"fmt"
 	"github.com/golang/mock/gomock"
 	_ "github.com/golang/mock/mockgen/model"
 	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/filter"
 	. "github.com/onsi/gomega"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 	"testing"
+	"time"
 )
 
 //go:generate mockgen -destination=callermocks_test.go -package=client_test github.com/kardolus/maps/http Caller
@@ -89,6 +92,14 @@ func testClient(t *testing.T, when spec.G, it spec.S) {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
+		it("passes the language and region", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey) + "&language=de&region=de"
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{}`), nil)
+
+			_, err := subject.WithLanguage("de").WithRegion("de").FetchLocations(entity, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		it("fetches locations with a single-page result", func() {
 			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
 
@@ -119,6 +130,57 @@ func testClient(t *testing.T, when spec.G, it spec.S) {
 			Expect(result[1].Geometry.Location.Lat).To(Equal(1.0))
 		})
 
+		it("reports the pages and unfiltered results per query", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+			expectedNextPageURL := fmt.Sprintf(client.NextPageEndpoint, "next-page-token", apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(multiPageResponse), nil).Times(1)
+			mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(singlePageResponse), nil).Times(1)
+
+			result, stats, err := subject.FetchLocationsWithStats(entity, []string{"other"}, []string{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result).To(BeEmpty())
+			Expect(stats.Query).To(Equal(entity))
+			Expect(stats.Pages).To(Equal(2))
+			Expect(stats.Results).To(Equal(2))
+			Expect(stats.Kept).To(Equal(0))
+			Expect(stats.Saturated()).To(BeFalse())
+		})
+
+		it("records the provenance of the locations when asked to", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+			expectedNextPageURL := fmt.Sprintf(client.NextPageEndpoint, "next-page-token", apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(multiPageResponse), nil).Times(1)
+			mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(singlePageResponse), nil).Times(1)
+
+			before := time.Now()
+			result, err := subject.WithProvenance(true).FetchLocations(entity, []string{"name"}, []string{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result).To(HaveLen(2))
+			for i, location := range result {
+				Expect(location.Provenance).NotTo(BeNil())
+				Expect(location.Provenance.Query).To(Equal(entity))
+				Expect(location.Provenance.Page).To(Equal(i + 1))
+				Expect(location.Provenance.Provider).To(Equal(client.Provider))
+				Expect(location.Provenance.Rule).To(Equal(filter.RuleContains))
+				Expect(location.Provenance.FetchedAt).To(BeTemporally(">=", before.UTC().Truncate(time.Second)))
+			}
+		})
+
+		it("leaves the provenance out by default", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(singlePageResponse), nil).Times(1)
+
+			result, err := subject.FetchLocations(entity, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(result).To(HaveLen(1))
+			Expect(result[0].Provenance).To(BeNil())
+		})
+
 		it("filters locations based on contains and matches lists", func() {
 			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
 
@@ -168,6 +230,57 @@ func testClient(t *testing.T, when spec.G, it spec.S) {
 			Expect(result[1].Geometry.Location.Lat).To(Equal(3.0))
 		})
 
+		it("returns all locations from every page when the filters are empty", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+			expectedNextPageURL := fmt.Sprintf(client.NextPageEndpoint, "next-page-token", apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(multiPageResponse), nil).Times(1)
+			mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(singlePageResponse), nil).Times(1)
+
+			result, err := subject.FetchLocations(entity, []string{}, []string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(2))
+		})
+
+		it("applies exclusions and regular expressions to every page", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+			expectedNextPageURL := fmt.Sprintf(client.NextPageEndpoint, "next-page-token", apiKey)
+
+			firstPage := `{
+				"results": [
+					{"name": "Starbucks", "geometry": {"location": {"lat": 1.0, "lng": 2.0}}},
+					{"name": "Starbucks inside Target", "geometry": {"location": {"lat": 3.0, "lng": 4.0}}}
+				],
+				"next_page_token": "next-page-token",
+				"status": "OK"
+			}`
+			secondPage := `{
+				"results": [
+					{"name": "Starbucks Reserve", "geometry": {"location": {"lat": 5.0, "lng": 6.0}}},
+					{"name": "Starbucks Terminal 2", "geometry": {"location": {"lat": 7.0, "lng": 8.0}}}
+				],
+				"status": "OK"
+			}`
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(firstPage), nil).Times(1)
+			mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(secondPage), nil).Times(1)
+
+			names, err := filter.Rules{
+				NameRegex:    []string{"^Starbucks"},
+				Exclude:      []string{"target"},
+				ExcludeRegex: []string{`Terminal \d`},
+			}.Compile()
+			Expect(err).NotTo(HaveOccurred())
+
+			result, stats, err := subject.FetchFiltered(entity, names)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(2))
+			Expect(result[0].Name).To(Equal("Starbucks"))
+			Expect(result[1].Name).To(Equal("Starbucks Reserve"))
+			Expect(stats.Results).To(Equal(4))
+			Expect(stats.Kept).To(Equal(2))
+		})
+
 		it("filters locations based on case-insensitive contains and matches", func() {
 			entity := "New York"
 			transformedEntity := "New+York"
@@ -232,5 +345,248 @@ func testClient(t *testing.T, when spec.G, it spec.S) {
 			Expect(err).To(HaveOccurred())
 			Expect(result).To(BeNil())
 		})
+
+		it("uses the configured base URL", func() {
+			subject.WithBaseURL("http://localhost:8080/")
+			expectedURL := fmt.Sprintf("http://localhost:8080"+client.TextSearchPath+"?query=%s&key=%s", transformedEntity, apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(singlePageResponse), nil)
+
+			result, err := subject.FetchLocations(entity, []string{}, []string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+		})
+
+		it("returns a StatusError when the API reports an error", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{"results": [], "status": "OVER_QUERY_LIMIT", "error_message": "quota exceeded"}`), nil)
+
+			_, err := subject.FetchLocations(entity, []string{}, []string{})
+			Expect(err).To(MatchError(`places api returned OVER_QUERY_LIMIT for "New York": quota exceeded`))
+			Expect(client.IsQuotaError(err)).To(BeTrue())
+		})
+
+		it("treats ZERO_RESULTS as an empty result", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{"results": [], "status": "ZERO_RESULTS"}`), nil)
+
+			result, err := subject.FetchLocations(entity, []string{}, []string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(BeEmpty())
+		})
+
+		it("retries a next page token that is not valid yet", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+			expectedNextPageURL := fmt.Sprintf(client.NextPageEndpoint, "next-page-token", apiKey)
+
+			gomock.InOrder(
+				mockCaller.EXPECT().Get(expectedURL).Return([]byte(multiPageResponse), nil),
+				mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(`{"results": [], "status": "INVALID_REQUEST"}`), nil),
+				mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(singlePageResponse), nil),
+			)
+
+			result, stats, err := subject.FetchLocationsWithStats(entity, []string{}, []string{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(2))
+			Expect(stats.Pages).To(Equal(2))
+		})
+
+		it("gives up on a next page token that never becomes valid", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+			expectedNextPageURL := fmt.Sprintf(client.NextPageEndpoint, "next-page-token", apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(multiPageResponse), nil)
+			mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(`{"results": [], "status": "INVALID_REQUEST"}`), nil).Times(4)
+
+			_, err := subject.FetchLocations(entity, []string{}, []string{})
+			Expect(err).To(MatchError(ContainSubstring("INVALID_REQUEST")))
+		})
+
+		it("does not retry an invalid first request", func() {
+			expectedURL := fmt.Sprintf(client.Endpoint, transformedEntity, apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{"results": [], "status": "INVALID_REQUEST"}`), nil).Times(1)
+
+			_, err := subject.FetchLocations(entity, []string{}, []string{})
+			Expect(err).To(HaveOccurred())
+			Expect(client.IsQuotaError(err)).To(BeFalse())
+		})
+	})
+
+	when("Nearby()", func() {
+		query := client.NearbyQuery{Lat: 39.96, Lng: -83, Radius: 5000, Keyword: "whole foods", Type: "grocery_or_supermarket"}
+		expectedURL := client.BaseURL + client.NearbySearchPath +
+			"?key=api-key&keyword=whole+foods&location=39.96%2C-83&radius=5000&type=grocery_or_supermarket"
+
+		it("returns an error when the radius is missing", func() {
+			_, _, err := subject.Nearby(client.NearbyQuery{Lat: 39.96, Lng: -83}, nil)
+			Expect(err).To(MatchError(ContainSubstring("radius must be a positive number")))
+		})
+
+		it("fetches every page of the nearby search", func() {
+			expectedNextPageURL := fmt.Sprintf(client.BaseURL+client.NearbySearchPath+"?pagetoken=%s&key=%s", "next-page-token", apiKey)
+
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(multiPageResponse), nil)
+			mockCaller.EXPECT().Get(expectedNextPageURL).Return([]byte(singlePageResponse), nil)
+
+			result, stats, err := subject.Nearby(query, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(2))
+			Expect(stats.Query).To(Equal("whole foods grocery_or_supermarket within 5000m of 39.96,-83"))
+			Expect(stats.Pages).To(Equal(2))
+		})
+
+		it("passes the language but not the region", func() {
+			expectedURL := client.BaseURL + client.NearbySearchPath +
+				"?key=api-key&keyword=whole+foods&language=de&location=39.96%2C-83&radius=5000&type=grocery_or_supermarket"
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{}`), nil)
+
+			_, _, err := subject.WithLanguage("de").WithRegion("de").Nearby(query, nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("returns a StatusError when the API reports an error", func() {
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{"results": [], "status": "REQUEST_DENIED"}`), nil)
+
+			_, _, err := subject.Nearby(query, nil)
+
+			var statusErr *client.StatusError
+			Expect(errors.As(err, &statusErr)).To(BeTrue())
+			Expect(statusErr.Status).To(Equal(client.StatusRequestDenied))
+		})
+	})
+
+	when("Details()", func() {
+		expectedURL := client.BaseURL + client.DetailsPath + "?key=api-key&place_id=place-1"
+
+		it("returns an error when the place id is empty", func() {
+			_, err := subject.Details("")
+			Expect(err).To(MatchError(client.ErrMissingPlace))
+		})
+
+		it("returns the place", func() {
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{"result": {"place_id": "place-1", "name": "name"}, "status": "OK"}`), nil)
+
+			result, err := subject.Details("place-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.PlaceId).To(Equal("place-1"))
+			Expect(result.Name).To(Equal("name"))
+		})
+
+		it("passes the language but not the region", func() {
+			expectedURL := client.BaseURL + client.DetailsPath + "?key=api-key&language=de&place_id=place-1"
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{"result": {"place_id": "place-1"}, "status": "OK"}`), nil)
+
+			_, err := subject.WithLanguage("de").WithRegion("de").Details("place-1")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("returns a StatusError for unknown places", func() {
+			mockCaller.EXPECT().Get(expectedURL).Return([]byte(`{"status": "NOT_FOUND"}`), nil)
+
+			_, err := subject.Details("place-1")
+
+			var statusErr *client.StatusError
+			Expect(errors.As(err, &statusErr)).To(BeTrue())
+			Expect(statusErr.Status).To(Equal(client.StatusNotFound))
+		})
+	})
+
+	when("several keys are configured", func() {
+		const (
+			quotaResponse  = `{"results": [], "status": "OVER_QUERY_LIMIT"}`
+			deniedResponse = `{"results": [], "status": "REQUEST_DENIED", "error_message": "The provided API key is invalid."}`
+		)
+
+		it("takes turns using the keys", func() {
+			subject.WithKeys(client.NewKeys("key-1", "key-2"))
+
+			mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-1")).Return([]byte(singlePageResponse), nil)
+			mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-2")).Return([]byte(singlePageResponse), nil)
+			mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-1")).Return([]byte(singlePageResponse), nil)
+
+			for i := 0; i < 3; i++ {
+				_, err := subject.FetchLocations(entity, nil, nil)
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		it("fails over to the next key when a key runs out of quota or is denied", func() {
+			keys := client.NewKeys("key-1", "key-2", "key-3")
+			subject.WithKeys(keys)
+
+			gomock.InOrder(
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-1")).Return([]byte(quotaResponse), nil),
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-2")).Return([]byte(deniedResponse), nil),
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-3")).Return([]byte(singlePageResponse), nil),
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-3")).Return([]byte(singlePageResponse), nil),
+			)
+
+			result, err := subject.FetchLocations(entity, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(1))
+
+			// the failed keys are skipped
+			_, err = subject.FetchLocations(entity, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(keys.Usage()).To(Equal([]client.KeyUsage{
+				{Key: "...ey-1", Requests: 1, Failures: 1, LastStatus: client.StatusOverQueryLimit},
+				{Key: "...ey-2", Requests: 1, Failures: 1, LastStatus: client.StatusRequestDenied},
+				{Key: "...ey-3", Requests: 2},
+			}))
+		})
+
+		it("returns the error when every key failed", func() {
+			subject.WithKeys(client.NewKeys("key-1", "key-2"))
+
+			mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-1")).Return([]byte(quotaResponse), nil)
+			mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-2")).Return([]byte(quotaResponse), nil)
+
+			_, err := subject.FetchLocations(entity, nil, nil)
+			Expect(client.IsQuotaError(err)).To(BeTrue())
+		})
+
+		it("requests every page with the key that issued the token", func() {
+			subject.WithKeys(client.NewKeys("key-1", "key-2"))
+
+			gomock.InOrder(
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-1")).Return([]byte(multiPageResponse), nil),
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.NextPageEndpoint, "next-page-token", "key-1")).Return([]byte(singlePageResponse), nil),
+			)
+
+			result, err := subject.FetchLocations(entity, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(2))
+		})
+
+		it("starts the search over with the next key when a page is rejected", func() {
+			subject.WithKeys(client.NewKeys("key-1", "key-2"))
+
+			gomock.InOrder(
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-1")).Return([]byte(multiPageResponse), nil),
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.NextPageEndpoint, "next-page-token", "key-1")).Return([]byte(quotaResponse), nil),
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.Endpoint, transformedEntity, "key-2")).Return([]byte(multiPageResponse), nil),
+				mockCaller.EXPECT().Get(fmt.Sprintf(client.NextPageEndpoint, "next-page-token", "key-2")).Return([]byte(singlePageResponse), nil),
+			)
+
+			result, stats, err := subject.FetchLocationsWithStats(entity, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(2))
+			Expect(stats.Pages).To(Equal(2))
+		})
+
+		it("fails over when fetching the details of a place", func() {
+			subject.WithKeys(client.NewKeys("key-1", "key-2"))
+
+			mockCaller.EXPECT().Get(client.BaseURL+client.DetailsPath+"?key=key-1&place_id=place-1").Return([]byte(`{"status": "OVER_QUERY_LIMIT"}`), nil)
+			mockCaller.EXPECT().Get(client.BaseURL+client.DetailsPath+"?key=key-2&place_id=place-1").Return([]byte(`{"result": {"place_id": "place-1"}, "status": "OK"}`), nil)
+
+			result, err := subject.Details("place-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.PlaceId).To(Equal("place-1"))
+		})
 	})
 }
@@ -0,0 +1,173 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+const (
+	RotationRoundRobin = "round-robin"
+	RotationFailover   = "failover"
+)
+
+// ParseRotation validates the way the keys of a pool take turns, RotationRoundRobin when empty
+func ParseRotation(rotation string) (string, error) {
+	switch rotation {
+	case "":
+		return RotationRoundRobin, nil
+	case RotationRoundRobin, RotationFailover:
+		return rotation, nil
+	default:
+		return "", fmt.Errorf("invalid key rotation %q, use %s or %s", rotation, RotationRoundRobin, RotationFailover)
+	}
+}
+
+// KeyUsage counts the requests sent with a key. Key is masked so the usage can be logged.
+type KeyUsage struct {
+	Key        string `json:"key"`
+	Requests   int    `json:"requests"`
+	Failures   int    `json:"failures"`
+	LastStatus string `json:"last_status,omitempty"`
+}
+
+// Keys is a pool of Places API keys shared by the searches of a run. With RotationRoundRobin every search starts with
+// the next key, with RotationFailover every search uses the first key that works. A key that answers
+// OVER_QUERY_LIMIT or REQUEST_DENIED is skipped until every key has failed, then all of them are tried again.
+type Keys struct {
+	mu       sync.Mutex
+	keys     []string
+	usage    []KeyUsage
+	failed   []bool
+	next     int
+	rotation string
+}
+
+// NewKeys creates a round-robin pool. Empty and repeated keys are dropped.
+func NewKeys(keys ...string) *Keys {
+	result := &Keys{rotation: RotationRoundRobin}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		result.keys = append(result.keys, key)
+		result.usage = append(result.usage, KeyUsage{Key: MaskKey(key)})
+		result.failed = append(result.failed, false)
+	}
+
+	return result
+}
+
+func (k *Keys) WithRotation(rotation string) *Keys {
+	k.rotation = rotation
+	return k
+}
+
+func (k *Keys) Len() int {
+	if k == nil {
+		return 0
+	}
+	return len(k.keys)
+}
+
+// Next picks the key for a new search, an empty string when the pool is empty
+func (k *Keys) Next() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if len(k.keys) == 0 {
+		return ""
+	}
+
+	if k.rotation == RotationFailover {
+		k.next = 0
+	}
+
+	for i := range k.keys {
+		at := (k.next + i) % len(k.keys)
+		if !k.failed[at] {
+			if k.rotation == RotationRoundRobin {
+				k.next = at + 1
+			}
+			return k.keys[at]
+		}
+	}
+
+	// every key failed, maybe the quota was reset since
+	for i := range k.failed {
+		k.failed[i] = false
+	}
+
+	at := k.next % len(k.keys)
+	if k.rotation == RotationRoundRobin {
+		k.next = at + 1
+	}
+	return k.keys[at]
+}
+
+// Used counts a request sent with the key
+func (k *Keys) Used(key string) {
+	k.update(key, func(at int) {
+		k.usage[at].Requests++
+	})
+}
+
+// Fail records the status of a request the key was rejected for and skips the key in the next searches
+func (k *Keys) Fail(key, status string) {
+	k.update(key, func(at int) {
+		k.usage[at].Failures++
+		k.usage[at].LastStatus = status
+		k.failed[at] = true
+	})
+}
+
+func (k *Keys) update(key string, fn func(at int)) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i, candidate := range k.keys {
+		if candidate == key {
+			fn(i)
+			return
+		}
+	}
+}
+
+// Usage returns the usage of every key, in the order the keys were given
+func (k *Keys) Usage() []KeyUsage {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	return append([]KeyUsage{}, k.usage...)
+}
+
+// WriteUsage renders the usage of every key for humans
+func (k *Keys) WriteUsage(w io.Writer) error {
+	var sb strings.Builder
+
+	sb.WriteString("API key usage:\n")
+	for _, usage := range k.Usage() {
+		line := fmt.Sprintf("  %s: %d requests", usage.Key, usage.Requests)
+		if usage.Failures > 0 {
+			line += fmt.Sprintf(", %d failed (%s)", usage.Failures, usage.LastStatus)
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// MaskKey hides all but the last four characters of a key
+func MaskKey(key string) string {
+	if len(key) <= 4 {
+		return strings.Repeat("*", len(key))
+	}
+	return "..." + key[len(key)-4:]
+}
@@ -0,0 +1,90 @@
+package client_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kardolus/maps/client"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitKeys(t *testing.T) {
+	spec.Run(t, "Keys Unit Tests", testKeys, spec.Report(report.Terminal{}))
+}
+
+func testKeys(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	next := func(keys *client.Keys, n int) []string {
+		var result []string
+		for i := 0; i < n; i++ {
+			result = append(result, keys.Next())
+		}
+		return result
+	}
+
+	it("drops empty and repeated keys", func() {
+		keys := client.NewKeys("a", " ", "b", "a", " c ")
+		Expect(keys.Len()).To(Equal(3))
+		Expect(next(keys, 3)).To(Equal([]string{"a", "b", "c"}))
+
+		Expect(client.NewKeys("").Next()).To(BeEmpty())
+	})
+
+	when("rotating round-robin", func() {
+		it("takes turns and skips the failed keys", func() {
+			keys := client.NewKeys("a", "b", "c")
+			Expect(next(keys, 4)).To(Equal([]string{"a", "b", "c", "a"}))
+
+			keys.Fail("b", client.StatusOverQueryLimit)
+			Expect(next(keys, 3)).To(Equal([]string{"c", "a", "c"}))
+		})
+
+		it("tries every key again once they all failed", func() {
+			keys := client.NewKeys("a", "b")
+			keys.Fail("a", client.StatusOverQueryLimit)
+			keys.Fail("b", client.StatusOverQueryLimit)
+
+			Expect(next(keys, 3)).To(Equal([]string{"a", "b", "a"}))
+		})
+	})
+
+	when("failing over", func() {
+		it("sticks to the first key that works", func() {
+			keys := client.NewKeys("a", "b", "c").WithRotation(client.RotationFailover)
+			Expect(next(keys, 2)).To(Equal([]string{"a", "a"}))
+
+			keys.Fail("a", client.StatusRequestDenied)
+			Expect(next(keys, 2)).To(Equal([]string{"b", "b"}))
+		})
+	})
+
+	it("reports the usage of every key", func() {
+		keys := client.NewKeys("first-key-1111", "second-key-2222")
+		keys.Used("first-key-1111")
+		keys.Used("first-key-1111")
+		keys.Used("second-key-2222")
+		keys.Fail("second-key-2222", client.StatusOverQueryLimit)
+		keys.Used("unknown")
+
+		var buf bytes.Buffer
+		Expect(keys.WriteUsage(&buf)).To(Succeed())
+		Expect(buf.String()).To(Equal("API key usage:\n" +
+			"  ...1111: 2 requests\n" +
+			"  ...2222: 1 requests, 1 failed (OVER_QUERY_LIMIT)\n"))
+	})
+
+	it("masks the keys", func() {
+		Expect(client.MaskKey("AIzaSyD-secret-1234")).To(Equal("...1234"))
+		Expect(client.MaskKey("abc")).To(Equal("***"))
+	})
+
+	it("validates the rotation", func() {
+		_, err := client.ParseRotation("random")
+		Expect(err).To(MatchError(ContainSubstring(`invalid key rotation "random"`)))
+	})
+}
@@ -0,0 +1,411 @@
+// Package cluster groups the places of a result set by their coordinates, to find the areas where places
+// concentrate. It runs DBSCAN, which finds dense areas of any shape and leaves isolated places out, or k-means, which
+// splits every place into a fixed number of territories.
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kardolus/maps/geo"
+	"github.com/kardolus/maps/types"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	AlgorithmDBSCAN = "dbscan"
+	AlgorithmKMeans = "kmeans"
+
+	DefaultEps           = 5000.0 // meters
+	DefaultMinPoints     = 3
+	DefaultMaxIterations = 100
+
+	// Noise is the cluster of the places DBSCAN leaves out of every cluster
+	Noise = -1
+)
+
+// ParseAlgorithm validates the name of a clustering algorithm
+func ParseAlgorithm(name string) (string, error) {
+	switch name {
+	case AlgorithmDBSCAN, AlgorithmKMeans:
+		return name, nil
+	default:
+		return "", fmt.Errorf("invalid algorithm %q, use %s or %s", name, AlgorithmDBSCAN, AlgorithmKMeans)
+	}
+}
+
+// Assignment is the cluster a place belongs to
+type Assignment struct {
+	PlaceId          string    `json:"place_id"`
+	Name             string    `json:"name"`
+	FormattedAddress string    `json:"formatted_address"`
+	Location         geo.Point `json:"location"`
+	Cluster          int       `json:"cluster"`
+}
+
+// Cluster is a group of places. Radius is the distance in meters from the centroid to its farthest place and Density
+// the number of places per square kilometer within that radius, 0 for clusters of places at the same spot.
+type Cluster struct {
+	Id       int       `json:"id"`
+	Size     int       `json:"size"`
+	Centroid geo.Point `json:"centroid"`
+	Radius   float64   `json:"radius"`
+	Density  float64   `json:"density"`
+	Bounds   geo.BBox  `json:"bounds"`
+	PlaceIds []string  `json:"place_ids"`
+}
+
+type Report struct {
+	Algorithm   string       `json:"algorithm"`
+	Clusters    []Cluster    `json:"clusters"`
+	Noise       int          `json:"noise"`
+	Assignments []Assignment `json:"assignments"`
+}
+
+// WriteText renders the report for humans, one line per cluster, the largest first
+func (r Report) WriteText(w io.Writer) error {
+	clusters := make([]Cluster, len(r.Clusters))
+	copy(clusters, r.Clusters)
+	sort.SliceStable(clusters, func(a, b int) bool { return clusters[a].Size > clusters[b].Size })
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d clusters, %d places not in a cluster (%s)\n", len(r.Clusters), r.Noise, r.Algorithm))
+
+	for _, cluster := range clusters {
+		sb.WriteString(fmt.Sprintf("  #%-3d %4d places around %.5f,%.5f within %.0fm, %.2f places/km²\n",
+			cluster.Id, cluster.Size, cluster.Centroid.Lat, cluster.Centroid.Lng, cluster.Radius, cluster.Density))
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteGeoJSON renders the report as a GeoJSON feature collection: a point per place with its cluster, followed by a
+// point per cluster centroid with the size, radius and density of the cluster
+func (r Report) WriteGeoJSON(w io.Writer) error {
+	type geometry struct {
+		Type        string     `json:"type"`
+		Coordinates [2]float64 `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string         `json:"type"`
+		Geometry   geometry       `json:"geometry"`
+		Properties map[string]any `json:"properties"`
+	}
+
+	point := func(p geo.Point) geometry {
+		return geometry{Type: "Point", Coordinates: [2]float64{p.Lng, p.Lat}}
+	}
+
+	features := make([]feature, 0, len(r.Assignments)+len(r.Clusters))
+	for _, assignment := range r.Assignments {
+		features = append(features, feature{Type: "Feature", Geometry: point(assignment.Location), Properties: map[string]any{
+			"kind":              "place",
+			"place_id":          assignment.PlaceId,
+			"name":              assignment.Name,
+			"formatted_address": assignment.FormattedAddress,
+			"cluster":           assignment.Cluster,
+		}})
+	}
+	for _, cluster := range r.Clusters {
+		features = append(features, feature{Type: "Feature", Geometry: point(cluster.Centroid), Properties: map[string]any{
+			"kind":    "centroid",
+			"cluster": cluster.Id,
+			"size":    cluster.Size,
+			"radius":  cluster.Radius,
+			"density": cluster.Density,
+		}})
+	}
+
+	data, err := json.MarshalIndent(map[string]any{"type": "FeatureCollection", "features": features}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+type Clusterer struct {
+	algorithm     string
+	eps           float64
+	minPoints     int
+	k             int
+	maxIterations int
+}
+
+func New() *Clusterer {
+	return &Clusterer{
+		algorithm:     AlgorithmDBSCAN,
+		eps:           DefaultEps,
+		minPoints:     DefaultMinPoints,
+		maxIterations: DefaultMaxIterations,
+	}
+}
+
+// WithAlgorithm configures the algorithm, AlgorithmDBSCAN or AlgorithmKMeans
+func (c *Clusterer) WithAlgorithm(algorithm string) *Clusterer {
+	c.algorithm = algorithm
+	return c
+}
+
+// WithEps configures the distance in meters within which DBSCAN considers two places neighbors
+func (c *Clusterer) WithEps(eps float64) *Clusterer {
+	c.eps = eps
+	return c
+}
+
+// WithMinPoints configures the number of places, the place itself included, DBSCAN needs within eps of a place to
+// start or grow a cluster from it
+func (c *Clusterer) WithMinPoints(minPoints int) *Clusterer {
+	c.minPoints = minPoints
+	return c
+}
+
+// WithK configures the number of clusters of k-means
+func (c *Clusterer) WithK(k int) *Clusterer {
+	c.k = k
+	return c
+}
+
+// WithMaxIterations configures how many times k-means may move its centroids before it stops
+func (c *Clusterer) WithMaxIterations(maxIterations int) *Clusterer {
+	c.maxIterations = maxIterations
+	return c
+}
+
+// Cluster assigns every location to a cluster. The clusters are numbered from 0 in the order of the first location
+// that belongs to them, so the same input always gives the same report.
+func (c *Clusterer) Cluster(locations []types.Location) (Report, error) {
+	var (
+		labels []int
+		err    error
+	)
+
+	switch c.algorithm {
+	case AlgorithmDBSCAN:
+		labels, err = c.dbscan(locations)
+	case AlgorithmKMeans:
+		labels, err = c.kmeans(locations)
+	default:
+		_, err = ParseAlgorithm(c.algorithm)
+	}
+	if err != nil {
+		return Report{}, err
+	}
+
+	return report(c.algorithm, locations, renumber(labels)), nil
+}
+
+// dbscan labels the locations with at least minPoints locations within eps as core locations, joins core locations
+// within eps of each other into clusters and adds the locations within eps of a core location to its cluster
+func (c *Clusterer) dbscan(locations []types.Location) ([]int, error) {
+	if c.eps <= 0 || c.minPoints < 1 {
+		return nil, fmt.Errorf("invalid eps %v and minimum of %d places, use a positive distance and minimum", c.eps, c.minPoints)
+	}
+
+	const unvisited = -2
+
+	labels := make([]int, len(locations))
+	for i := range labels {
+		labels[i] = unvisited
+	}
+
+	index := geo.NewIndex(locations)
+	neighbors := func(i int) []geo.Neighbor {
+		return index.Within(geo.PointOf(locations[i]), c.eps)
+	}
+
+	next := 0
+	for i := range locations {
+		if labels[i] != unvisited {
+			continue
+		}
+
+		seeds := neighbors(i)
+		if len(seeds) < c.minPoints {
+			labels[i] = Noise
+			continue
+		}
+
+		id := next
+		next++
+		labels[i] = id
+
+		for len(seeds) > 0 {
+			j := seeds[0].Index
+			seeds = seeds[1:]
+
+			if labels[j] == Noise {
+				labels[j] = id // a border place, reachable but not dense enough to grow the cluster
+			}
+			if labels[j] != unvisited {
+				continue
+			}
+
+			labels[j] = id
+			if more := neighbors(j); len(more) >= c.minPoints {
+				seeds = append(seeds, more...)
+			}
+		}
+	}
+
+	return labels, nil
+}
+
+// kmeans splits the locations into k clusters. The first centroid is the first location and every next one the
+// location farthest from the centroids so far, which spreads the clusters without randomness.
+func (c *Clusterer) kmeans(locations []types.Location) ([]int, error) {
+	if c.k < 1 {
+		return nil, fmt.Errorf("invalid number of clusters %d, use a positive number", c.k)
+	}
+
+	labels := make([]int, len(locations))
+	if len(locations) == 0 {
+		return labels, nil
+	}
+
+	points := make([]geo.Point, len(locations))
+	for i, location := range locations {
+		points[i] = geo.PointOf(location)
+	}
+
+	nearest := make([]float64, len(points))
+	for i := range nearest {
+		nearest[i] = math.Inf(1)
+	}
+
+	centroids := []geo.Point{points[0]}
+	for len(centroids) < c.k && len(centroids) < len(points) {
+		farthest := -1
+		for i, p := range points {
+			nearest[i] = math.Min(nearest[i], geo.Distance(p, centroids[len(centroids)-1]))
+			if farthest < 0 || nearest[i] > nearest[farthest] {
+				farthest = i
+			}
+		}
+
+		if nearest[farthest] == 0 {
+			break // the remaining places share a spot with a centroid
+		}
+		centroids = append(centroids, points[farthest])
+	}
+
+	for iteration := 0; iteration < c.maxIterations; iteration++ {
+		changed := iteration == 0
+
+		for i, p := range points {
+			best := 0
+			for j := range centroids {
+				if geo.Distance(p, centroids[j]) < geo.Distance(p, centroids[best]) {
+					best = j
+				}
+			}
+
+			if labels[i] != best {
+				labels[i] = best
+				changed = true
+			}
+		}
+
+		if !changed {
+			break
+		}
+
+		members := make([][]geo.Point, len(centroids))
+		for i, label := range labels {
+			members[label] = append(members[label], points[i])
+		}
+		for j := range centroids {
+			if centroid, ok := geo.Centroid(members[j]); ok {
+				centroids[j] = centroid
+			}
+		}
+	}
+
+	return labels, nil
+}
+
+// renumber numbers the clusters in the order of their first location, keeping noise as is
+func renumber(labels []int) []int {
+	ids := make(map[int]int)
+	result := make([]int, len(labels))
+
+	for i, label := range labels {
+		if label == Noise {
+			result[i] = Noise
+			continue
+		}
+
+		id, ok := ids[label]
+		if !ok {
+			id = len(ids)
+			ids[label] = id
+		}
+		result[i] = id
+	}
+
+	return result
+}
+
+func report(algorithm string, locations []types.Location, labels []int) Report {
+	result := Report{Algorithm: algorithm, Clusters: []Cluster{}, Assignments: make([]Assignment, 0, len(locations))}
+
+	var members [][]types.Location
+	for i, location := range locations {
+		label := labels[i]
+		result.Assignments = append(result.Assignments, Assignment{
+			PlaceId:          location.PlaceId,
+			Name:             location.Name,
+			FormattedAddress: location.FormattedAddress,
+			Location:         geo.PointOf(location),
+			Cluster:          label,
+		})
+
+		if label == Noise {
+			result.Noise++
+			continue
+		}
+
+		for len(members) <= label {
+			members = append(members, nil)
+		}
+		members[label] = append(members[label], location)
+	}
+
+	for id, group := range members {
+		result.Clusters = append(result.Clusters, summarize(id, group))
+	}
+
+	return result
+}
+
+func summarize(id int, locations []types.Location) Cluster {
+	cluster := Cluster{Id: id, Size: len(locations), PlaceIds: make([]string, 0, len(locations))}
+
+	points := make([]geo.Point, 0, len(locations))
+	for _, location := range locations {
+		points = append(points, geo.PointOf(location))
+		cluster.PlaceIds = append(cluster.PlaceIds, location.PlaceId)
+	}
+
+	centroid, ok := geo.Centroid(points)
+	if !ok {
+		centroid = points[0]
+	}
+	cluster.Centroid = centroid
+
+	for _, p := range points {
+		cluster.Radius = math.Max(cluster.Radius, geo.Distance(centroid, p))
+	}
+
+	if cluster.Radius > 0 {
+		area := math.Pi * cluster.Radius * cluster.Radius / 1e6 // square kilometers
+		cluster.Density = float64(cluster.Size) / area
+	}
+
+	cluster.Bounds, _ = geo.Bounds(locations)
+	return cluster
+}
@@ -0,0 +1,192 @@
+package cluster_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/kardolus/maps/cluster"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitCluster(t *testing.T) {
+	spec.Run(t, "Cluster Unit Tests", testCluster, spec.Report(report.Terminal{}))
+}
+
+func testCluster(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject *cluster.Clusterer
+		place   = func(id string, lat, lng float64) types.Location {
+			l := types.Location{PlaceId: id, Name: "Whole Foods Market", FormattedAddress: id + " Main St"}
+			l.Geometry.Location.Lat = lat
+			l.Geometry.Location.Lng = lng
+			return l
+		}
+
+		// three stores around Columbus, three around Cleveland about 1km apart and a lone store in Dayton
+		locations = []types.Location{
+			place("columbus-1", 39.9612, -82.9988),
+			place("cleveland-1", 41.4993, -81.6944),
+			place("columbus-2", 39.9700, -82.9988),
+			place("dayton", 39.7589, -84.1916),
+			place("cleveland-2", 41.5083, -81.6944),
+			place("columbus-3", 39.9612, -83.0100),
+			place("cleveland-3", 41.4993, -81.7064),
+		}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		subject = cluster.New()
+	})
+
+	clusters := func(r cluster.Report) []int {
+		var result []int
+		for _, assignment := range r.Assignments {
+			result = append(result, assignment.Cluster)
+		}
+		return result
+	}
+
+	when("running DBSCAN", func() {
+		it("groups dense places and leaves isolated places out", func() {
+			r, err := subject.WithEps(2000).WithMinPoints(3).Cluster(locations)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Algorithm).To(Equal(cluster.AlgorithmDBSCAN))
+			Expect(clusters(r)).To(Equal([]int{0, 1, 0, cluster.Noise, 1, 0, 1}))
+			Expect(r.Noise).To(Equal(1))
+			Expect(r.Clusters).To(HaveLen(2))
+
+			columbus := r.Clusters[0]
+			Expect(columbus.Size).To(Equal(3))
+			Expect(columbus.PlaceIds).To(Equal([]string{"columbus-1", "columbus-2", "columbus-3"}))
+			Expect(columbus.Centroid.Lat).To(BeNumerically("~", 39.9641, 0.001))
+			Expect(columbus.Centroid.Lng).To(BeNumerically("~", -83.0025, 0.001))
+			Expect(columbus.Radius).To(BeNumerically(">", 0))
+			Expect(columbus.Density).To(BeNumerically(">", 0))
+			Expect(columbus.Bounds.South).To(Equal(39.9612))
+			Expect(columbus.Bounds.North).To(Equal(39.9700))
+		})
+
+		it("adds border places to a cluster without growing it from them", func() {
+			chain := []types.Location{
+				place("a", 0, 0),
+				place("b", 0, 0.01),
+				place("c", 0, 0.02),
+				place("d", 0, 0.03),
+			}
+
+			// a and d have a single neighbor, b and c have two: only b and c are dense enough at a minimum of 3
+			r, err := subject.WithEps(1200).WithMinPoints(3).Cluster(chain)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(clusters(r)).To(Equal([]int{0, 0, 0, 0}))
+
+			r, err = subject.WithMinPoints(4).Cluster(chain)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(clusters(r)).To(Equal([]int{cluster.Noise, cluster.Noise, cluster.Noise, cluster.Noise}))
+			Expect(r.Clusters).To(BeEmpty())
+		})
+
+		it("rejects an invalid eps or minimum", func() {
+			_, err := subject.WithEps(0).Cluster(locations)
+			Expect(err).To(MatchError(ContainSubstring("invalid eps")))
+
+			_, err = subject.WithEps(100).WithMinPoints(0).Cluster(locations)
+			Expect(err).To(MatchError(ContainSubstring("invalid eps")))
+		})
+	})
+
+	when("running k-means", func() {
+		it("splits every place into k clusters", func() {
+			r, err := subject.WithAlgorithm(cluster.AlgorithmKMeans).WithK(3).Cluster(locations)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Algorithm).To(Equal(cluster.AlgorithmKMeans))
+			Expect(clusters(r)).To(Equal([]int{0, 1, 0, 2, 1, 0, 1}))
+			Expect(r.Noise).To(BeZero())
+			Expect(r.Clusters[2].Size).To(Equal(1))
+			Expect(r.Clusters[2].Radius).To(BeZero())
+			Expect(r.Clusters[2].Density).To(BeZero())
+		})
+
+		it("makes no more clusters than there are distinct spots", func() {
+			r, err := subject.WithAlgorithm(cluster.AlgorithmKMeans).WithK(5).Cluster([]types.Location{
+				place("a", 40, -83), place("b", 40, -83), place("c", 41, -81),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(clusters(r)).To(Equal([]int{0, 0, 1}))
+		})
+
+		it("rejects an invalid k", func() {
+			_, err := subject.WithAlgorithm(cluster.AlgorithmKMeans).Cluster(locations)
+			Expect(err).To(MatchError(ContainSubstring("invalid number of clusters")))
+		})
+	})
+
+	it("rejects unknown algorithms", func() {
+		_, err := cluster.ParseAlgorithm("optics")
+		Expect(err).To(MatchError(ContainSubstring("invalid algorithm")))
+
+		_, err = subject.WithAlgorithm("optics").Cluster(locations)
+		Expect(err).To(MatchError(ContainSubstring("invalid algorithm")))
+	})
+
+	it("handles an empty result set", func() {
+		r, err := subject.Cluster(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Clusters).To(BeEmpty())
+		Expect(r.Assignments).To(BeEmpty())
+	})
+
+	when("writing the report", func() {
+		it("lists the clusters, the largest first", func() {
+			r, err := subject.WithAlgorithm(cluster.AlgorithmKMeans).WithK(3).Cluster(locations)
+			Expect(err).NotTo(HaveOccurred())
+
+			var buf bytes.Buffer
+			Expect(r.WriteText(&buf)).To(Succeed())
+
+			lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+			Expect(lines).To(HaveLen(4))
+			Expect(string(lines[0])).To(Equal("3 clusters, 0 places not in a cluster (kmeans)"))
+			Expect(string(lines[3])).To(ContainSubstring("#2      1 places around 39.75890,-84.19160 within 0m"))
+		})
+
+		it("writes GeoJSON with a feature per place and per centroid", func() {
+			r, err := subject.WithEps(2000).Cluster(locations)
+			Expect(err).NotTo(HaveOccurred())
+
+			var buf bytes.Buffer
+			Expect(r.WriteGeoJSON(&buf)).To(Succeed())
+
+			var collection struct {
+				Type     string `json:"type"`
+				Features []struct {
+					Geometry struct {
+						Type        string     `json:"type"`
+						Coordinates [2]float64 `json:"coordinates"`
+					} `json:"geometry"`
+					Properties map[string]any `json:"properties"`
+				} `json:"features"`
+			}
+			Expect(json.Unmarshal(buf.Bytes(), &collection)).To(Succeed())
+
+			Expect(collection.Type).To(Equal("FeatureCollection"))
+			Expect(collection.Features).To(HaveLen(9))
+
+			dayton := collection.Features[3]
+			Expect(dayton.Geometry.Type).To(Equal("Point"))
+			Expect(dayton.Geometry.Coordinates).To(Equal([2]float64{-84.1916, 39.7589}))
+			Expect(dayton.Properties).To(HaveKeyWithValue("place_id", "dayton"))
+			Expect(dayton.Properties).To(HaveKeyWithValue("cluster", float64(cluster.Noise)))
+
+			centroid := collection.Features[7]
+			Expect(centroid.Properties).To(HaveKeyWithValue("kind", "centroid"))
+			Expect(centroid.Properties).To(HaveKeyWithValue("size", float64(3)))
+		})
+	})
+}
@@ -1,22 +1,182 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/cache"
 	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/cluster"
+	"github.com/kardolus/maps/config"
+	"github.com/kardolus/maps/coverage"
+	"github.com/kardolus/maps/dedupe"
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/geo"
 	"github.com/kardolus/maps/http"
 	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/mcp"
+	"github.com/kardolus/maps/merge"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/server"
+	"github.com/kardolus/maps/stats"
 	"github.com/kardolus/maps/utils"
+	"github.com/kardolus/maps/watch"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"io"
+	"io/fs"
+	nethttp "net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "maps",
+	Use:   "maps [query | -]",
 	Short: "Fetch locations using Google Places API",
-	Long:  "Fetch locations using Google Places API and optionally write the JSON response to a file",
-	RunE:  run,
+	Long: "Fetch locations using Google Places API and write them to stdout or a file. The query is passed via --query, " +
+		"as arguments, or read from stdin with -. Status messages are written to stderr.",
+	Args:              cobra.ArbitraryArgs,
+	PersistentPreRunE: loadConfig,
+	RunE:              run,
+}
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "Run every query of a file",
+	Long: "Run every query of a text file (one query per line) or CSV file (query column) through the planning, fetch " +
+		"and filter pipeline and print a summary of the results, errors and requests per query",
+	Args: cobra.NoArgs,
+	RunE: runBatch,
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff old.json new.json",
+	Short: "Compare two result files",
+	Long: "Compare two result files by place id, falling back to name and proximity, and report the places that were " +
+		"added, removed or changed. Exits with 0 when the files match, 1 when they differ and 2 on errors.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		return diffError(cobra.ExactArgs(2)(cmd, args))
+	},
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return diffError(loadConfig(cmd, args))
+	},
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runDiff,
+}
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge file...",
+	Short: "Merge result files into one",
+	Long: "Merge JSON, newline delimited JSON or CSV result files into one, keeping a single record per place id. " +
+		"Every place lists the files and queries it was found in.",
+	Args: cobra.MinimumNArgs(1),
+	RunE: runMerge,
+}
+
+var nearCmd = &cobra.Command{
+	Use:   "near",
+	Short: "List the places of a result file nearest to a point",
+	Long: "List the places of a JSON, newline delimited JSON or CSV result file nearest to a point, the closest " +
+		"first. With --radius, only the places within that many meters are listed.",
+	Args: cobra.NoArgs,
+	RunE: runNear,
+}
+
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze",
+	Short: "Analyze a result file offline",
+	Long:  "Analyze a JSON, newline delimited JSON or CSV result file without calling any API",
+	Args:  cobra.NoArgs,
+}
+
+var clustersCmd = &cobra.Command{
+	Use:   "clusters file",
+	Short: "Group the places of a result file by location",
+	Long: "Group the places of a result file by location with DBSCAN or k-means and write the cluster of every place " +
+		"and the centroid, size and density of every cluster as JSON or GeoJSON. A summary is written to stderr.",
+	Args: cobra.ExactArgs(1),
+	RunE: runClusters,
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats file",
+	Short: "Profile a result file",
+	Long: "Count the places of a JSON, newline delimited JSON or CSV result file by country, state, business status " +
+		"and type, and summarize their ratings, reviews and opening status",
+	Args: cobra.ExactArgs(1),
+	RunE: runStats,
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [query | -]",
+	Short: "Re-run a search on a schedule and report the changes",
+	Long: "Re-run a search on a schedule, compare the results to the snapshot of the previous run and write an event " +
+		"for every new place, closed place and status change. The last snapshot is kept in a JSON file, so the " +
+		"schedule continues after a restart.",
+	Args: cobra.ArbitraryArgs,
+	RunE: runWatch,
+}
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve searches over HTTP",
+	Long: "Serve a REST API to submit searches as jobs, poll their status and fetch their results in any output " +
+		"format. Jobs use the API keys and flags the server was started with.",
+	Args: cobra.NoArgs,
+	RunE: runServe,
+}
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve searches as tools over the Model Context Protocol",
+	Long: "Serve the search_places, nearby_places, place_details and diff_results tools over the Model Context " +
+		"Protocol on stdin and stdout, so assistants can look up places. Searches use the API keys and flags the " +
+		"server was started with.",
+	Args: cobra.NoArgs,
+	RunE: runMCP,
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the config file",
+	Long: "Manage the config file, by default config.yaml in the maps directory of the user config directory, e.g. " +
+		"~/.config/maps/config.yaml, or the file given by --config or MAPS_CONFIG",
+	Args: cobra.NoArgs,
+	// the subcommands read the file themselves, so a broken file can still be inspected and replaced
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a config file with every setting commented out",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigInit,
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the settings in effect",
+	Long: "Show the value of every setting after applying the config file, the profile, the environment and the " +
+		"flags. API keys are masked.",
+	Args: cobra.NoArgs,
+	RunE: runConfigShow,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for unknown keys and invalid values",
+	Args:  cobra.NoArgs,
+	RunE:  runConfigValidate,
 }
 
 var validShellArgs = []string{"bash", "zsh", "fish", "powershell"}
@@ -46,93 +206,1067 @@ var completionCmd = &cobra.Command{
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringP("query", "q", "Whole Foods In USA", "Search query")
-	viper.BindPFlag("query", rootCmd.PersistentFlags().Lookup("query"))
+	rootCmd.Flags().StringP("query", "q", "", "Search query, e.g. \"Whole Foods in Ohio\"")
+	viper.BindPFlag("query", rootCmd.Flags().Lookup("query"))
+
+	rootCmd.Flags().String("stats", "", "Write a profile of the results to stderr when the search is done: text or json")
+	rootCmd.Flags().Lookup("stats").NoOptDefVal = stats.FormatText
+	viper.BindPFlag("stats", rootCmd.Flags().Lookup("stats"))
+
+	rootCmd.Flags().String("coverage", "", "Write the counts of every sub-query and the saturated and empty ones to stderr when the search is done: text or json")
+	rootCmd.Flags().Lookup("coverage").NoOptDefVal = coverage.FormatText
+	viper.BindPFlag("coverage", rootCmd.Flags().Lookup("coverage"))
+
+	rootCmd.Flags().String("coverage-expected", "", "CSV file with the expected number of places per query or region, compared by --coverage")
+	viper.BindPFlag("coverage-expected", rootCmd.Flags().Lookup("coverage-expected"))
+
+	rootCmd.PersistentFlags().String("config", "", "Config file (default: <user config dir>/maps/config.yaml)")
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	viper.BindEnv("config", "MAPS_CONFIG")
+
+	rootCmd.PersistentFlags().String("profile", "", "Profile of the config file to apply on top of its settings")
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	viper.BindEnv("profile", "MAPS_PROFILE")
 
 	rootCmd.PersistentFlags().String("api-key", "", "Google Places API key")
 	viper.BindPFlag("api-key", rootCmd.PersistentFlags().Lookup("api-key"))
 	viper.BindEnv("api-key", "GOOGLE_API_KEY")
 
-	rootCmd.PersistentFlags().StringP("output", "o", "", "Output file to write the JSON response")
+	rootCmd.PersistentFlags().StringSlice("api-keys", nil, "Google Places API keys to spread the requests over, comma-separated or repeated")
+	viper.BindPFlag("api-keys", rootCmd.PersistentFlags().Lookup("api-keys"))
+	viper.BindEnv("api-keys", "GOOGLE_API_KEYS")
+
+	rootCmd.PersistentFlags().String("key-rotation", client.RotationRoundRobin, "How searches pick an API key: round-robin or failover, which sticks to the first key that works")
+	viper.BindPFlag("key-rotation", rootCmd.PersistentFlags().Lookup("key-rotation"))
+
+	rootCmd.PersistentFlags().String("language", "", "Language of the names and addresses returned by the Places API, e.g. de")
+	viper.BindPFlag("language", rootCmd.PersistentFlags().Lookup("language"))
+
+	rootCmd.PersistentFlags().String("region", "", "Region, as a ccTLD code such as de, the Places API biases text searches towards")
+	viper.BindPFlag("region", rootCmd.PersistentFlags().Lookup("region"))
+
+	rootCmd.PersistentFlags().String("places-url", client.BaseURL, "Base URL of the Google Places API")
+	rootCmd.PersistentFlags().MarkHidden("places-url")
+	viper.BindPFlag("places-url", rootCmd.PersistentFlags().Lookup("places-url"))
+	viper.BindEnv("places-url", "MAPS_PLACES_URL")
+
+	rootCmd.PersistentFlags().Int("page-delay", client.DefaultTimeout, "Milliseconds to wait before requesting the next page of a search")
+	rootCmd.PersistentFlags().MarkHidden("page-delay")
+	viper.BindPFlag("page-delay", rootCmd.PersistentFlags().Lookup("page-delay"))
+	viper.BindEnv("page-delay", "MAPS_PAGE_DELAY")
+
+	rootCmd.PersistentFlags().StringP("output", "o", "", "Output file to write the results to, stdout when empty")
 	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
 
-	viper.SetConfigName("config")
-	viper.SetConfigType("yaml")
-	viper.AddConfigPath("bin")
+	rootCmd.PersistentFlags().String("format", "", "Output format: json, ndjson or csv (default: by file extension, json on stdout)")
+	viper.BindPFlag("format", rootCmd.PersistentFlags().Lookup("format"))
+
+	rootCmd.PersistentFlags().Bool("debug", false, "Log every Places API request to stderr")
+	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
+
+	rootCmd.PersistentFlags().String("prompt-dir", "", "Directory containing query_prompt.txt and/or filter_prompt.txt overrides")
+	viper.BindPFlag("prompt-dir", rootCmd.PersistentFlags().Lookup("prompt-dir"))
+
+	rootCmd.PersistentFlags().String("query-prompt", "", "File that replaces the built-in query breakdown prompt")
+	viper.BindPFlag("query-prompt", rootCmd.PersistentFlags().Lookup("query-prompt"))
+
+	rootCmd.PersistentFlags().String("filter-prompt", "", "File that replaces the built-in filter prompt")
+	viper.BindPFlag("filter-prompt", rootCmd.PersistentFlags().Lookup("filter-prompt"))
+
+	rootCmd.PersistentFlags().Int("max-results", llm.DefaultMaxResults, "Maximum number of results a single sub-query should return")
+	viper.BindPFlag("max-results", rootCmd.PersistentFlags().Lookup("max-results"))
+
+	rootCmd.PersistentFlags().Int("rounds", llm.DefaultRounds, "Maximum number of times saturated sub-queries are sent back to the LLM to be split")
+	viper.BindPFlag("rounds", rootCmd.PersistentFlags().Lookup("rounds"))
+
+	rootCmd.PersistentFlags().StringSlice("exclude", nil, "Drop results whose name contains any of these terms (case-insensitive)")
+	viper.BindPFlag("exclude", rootCmd.PersistentFlags().Lookup("exclude"))
+
+	rootCmd.PersistentFlags().StringSlice("name-regex", nil, "Keep only results whose name matches any of these regular expressions")
+	viper.BindPFlag("name-regex", rootCmd.PersistentFlags().Lookup("name-regex"))
+
+	rootCmd.PersistentFlags().StringSlice("exclude-regex", nil, "Drop results whose name matches any of these regular expressions")
+	viper.BindPFlag("exclude-regex", rootCmd.PersistentFlags().Lookup("exclude-regex"))
+
+	rootCmd.PersistentFlags().String("filter-file", "", "YAML file with include/exclude name rules per query")
+	viper.BindPFlag("filter-file", rootCmd.PersistentFlags().Lookup("filter-file"))
+
+	rootCmd.PersistentFlags().String("where", "", "Expression results must satisfy, e.g. 'rating >= 4.2 && \"grocery_or_supermarket\" in types'")
+	viper.BindPFlag("where", rootCmd.PersistentFlags().Lookup("where"))
+
+	rootCmd.PersistentFlags().Bool("classify", false, "Ask the LLM to classify every result as relevant or irrelevant")
+	viper.BindPFlag("classify", rootCmd.PersistentFlags().Lookup("classify"))
+
+	rootCmd.PersistentFlags().Bool("keep-irrelevant", false, "Keep results classified as irrelevant in the output")
+	viper.BindPFlag("keep-irrelevant", rootCmd.PersistentFlags().Lookup("keep-irrelevant"))
+
+	rootCmd.PersistentFlags().Int("classify-batch-size", llm.DefaultBatchSize, "Maximum number of results classified per LLM request")
+	viper.BindPFlag("classify-batch-size", rootCmd.PersistentFlags().Lookup("classify-batch-size"))
+
+	rootCmd.PersistentFlags().String("verdict-cache", defaultVerdictCache(), "File used to cache relevance verdicts")
+	viper.BindPFlag("verdict-cache", rootCmd.PersistentFlags().Lookup("verdict-cache"))
+
+	rootCmd.PersistentFlags().Bool("dedupe", false, "Merge nearby results with similar names, like a store and its pharmacy, keeping their ids as aliases")
+	viper.BindPFlag("dedupe", rootCmd.PersistentFlags().Lookup("dedupe"))
+
+	rootCmd.PersistentFlags().Float64("dedupe-radius", dedupe.DefaultRadius, "Distance in meters within which results can be merged by --dedupe")
+	viper.BindPFlag("dedupe-radius", rootCmd.PersistentFlags().Lookup("dedupe-radius"))
+
+	rootCmd.PersistentFlags().Float64("dedupe-similarity", dedupe.DefaultSimilarity, "Minimum name similarity, between 0 and 1, of results merged by --dedupe")
+	viper.BindPFlag("dedupe-similarity", rootCmd.PersistentFlags().Lookup("dedupe-similarity"))
+
+	rootCmd.PersistentFlags().String("dedupe-report", "", "File to write the JSON report of the places merged by --dedupe to")
+	viper.BindPFlag("dedupe-report", rootCmd.PersistentFlags().Lookup("dedupe-report"))
+
+	rootCmd.PersistentFlags().Bool("parse-address", false, "Split the formatted address of every result into street, city, region, postal code and country")
+	viper.BindPFlag("parse-address", rootCmd.PersistentFlags().Lookup("parse-address"))
+
+	rootCmd.PersistentFlags().Bool("with-provenance", false, "Add the sub-query, page, fetch time, provider and name rule that produced every result to the output")
+	viper.BindPFlag("with-provenance", rootCmd.PersistentFlags().Lookup("with-provenance"))
+
+	rootCmd.PersistentFlags().String("locale", "", "Locale passed to the prompts, e.g. fr-FR")
+	viper.BindPFlag("locale", rootCmd.PersistentFlags().Lookup("locale"))
+
+	rootCmd.PersistentFlags().Float64("rate-limit", 0, "Maximum number of Places API requests per second, 0 for no limit")
+	viper.BindPFlag("rate-limit", rootCmd.PersistentFlags().Lookup("rate-limit"))
+
+	batchCmd.Flags().String("input", "", "Text or CSV file with the queries to run, - for one query per line on stdin")
+	batchCmd.MarkFlagRequired("input")
+	viper.BindPFlag("input", batchCmd.Flags().Lookup("input"))
+
+	batchCmd.Flags().String("output-dir", "", "Directory to write one file per query to")
+	viper.BindPFlag("output-dir", batchCmd.Flags().Lookup("output-dir"))
+
+	batchCmd.Flags().Int("retries", app.DefaultBatchRetries, "Number of times failed queries are retried after the rest of the batch")
+	viper.BindPFlag("retries", batchCmd.Flags().Lookup("retries"))
+
+	batchCmd.Flags().String("failed", "", "File to write the queries that failed to, for a re-run with --input")
+	viper.BindPFlag("failed", batchCmd.Flags().Lookup("failed"))
+
+	diffCmd.Flags().Float64("radius", diff.DefaultRadius, "Distance in meters within which places with the same name but a different place id are matched")
+	viper.BindPFlag("diff.radius", diffCmd.Flags().Lookup("radius"))
+
+	diffCmd.Flags().Bool("json", false, "Write the report as JSON")
+	viper.BindPFlag("diff.json", diffCmd.Flags().Lookup("json"))
+	diffCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return diffError(err)
+	})
+
+	nearCmd.Flags().String("from", "", "Result file to search")
+	nearCmd.MarkFlagRequired("from")
+	viper.BindPFlag("near.from", nearCmd.Flags().Lookup("from"))
+
+	nearCmd.Flags().Float64("lat", 0, "Latitude of the point")
+	nearCmd.MarkFlagRequired("lat")
+	viper.BindPFlag("near.lat", nearCmd.Flags().Lookup("lat"))
+
+	nearCmd.Flags().Float64("lng", 0, "Longitude of the point")
+	nearCmd.MarkFlagRequired("lng")
+	viper.BindPFlag("near.lng", nearCmd.Flags().Lookup("lng"))
+
+	nearCmd.Flags().Int("k", 5, "Number of places to list, 0 for every place within --radius")
+	viper.BindPFlag("near.k", nearCmd.Flags().Lookup("k"))
+
+	nearCmd.Flags().Float64("radius", 0, "Only list the places within this many meters")
+	viper.BindPFlag("near.radius", nearCmd.Flags().Lookup("radius"))
+
+	clustersCmd.Flags().String("algorithm", cluster.AlgorithmDBSCAN, "Clustering algorithm: dbscan or kmeans")
+	viper.BindPFlag("clusters.algorithm", clustersCmd.Flags().Lookup("algorithm"))
+
+	clustersCmd.Flags().String("eps", "5km", "Distance within which DBSCAN considers two places neighbors, e.g. 500m, 5km or 3mi")
+	viper.BindPFlag("clusters.eps", clustersCmd.Flags().Lookup("eps"))
+
+	clustersCmd.Flags().Int("min", cluster.DefaultMinPoints, "Number of places, itself included, a place needs within --eps to grow a DBSCAN cluster")
+	viper.BindPFlag("clusters.min", clustersCmd.Flags().Lookup("min"))
+
+	clustersCmd.Flags().Int("k", 0, "Number of k-means clusters")
+	viper.BindPFlag("clusters.k", clustersCmd.Flags().Lookup("k"))
+
+	clustersCmd.Flags().Bool("geojson", false, "Write the clusters as a GeoJSON feature collection")
+	viper.BindPFlag("clusters.geojson", clustersCmd.Flags().Lookup("geojson"))
+
+	analyzeCmd.AddCommand(clustersCmd)
+
+	statsCmd.Flags().Bool("json", false, "Write the profile as JSON")
+	viper.BindPFlag("stats.json", statsCmd.Flags().Lookup("json"))
+
+	watchCmd.Flags().StringP("query", "q", "", "Search query to watch, e.g. \"Whole Foods in Ohio\"")
+	viper.BindPFlag("watch.query", watchCmd.Flags().Lookup("query"))
+
+	watchCmd.Flags().Duration("every", app.DefaultWatchInterval, "Time between two runs")
+	viper.BindPFlag("watch.every", watchCmd.Flags().Lookup("every"))
+
+	watchCmd.Flags().String("store", "", "JSON file that keeps the snapshot of the last run, e.g. results.json")
+	watchCmd.MarkFlagRequired("store")
+	viper.BindPFlag("watch.store", watchCmd.Flags().Lookup("store"))
+
+	watchCmd.Flags().String("events", app.Stdout, "Where to write the events: - for stdout, a file to append to or a webhook URL")
+	viper.BindPFlag("watch.events", watchCmd.Flags().Lookup("events"))
+
+	watchCmd.Flags().Duration("backoff", app.DefaultQuotaBackoff, "Time to wait after the Places API quota ran out, doubled on every quota error in a row")
+	viper.BindPFlag("watch.backoff", watchCmd.Flags().Lookup("backoff"))
+
+	watchCmd.Flags().Bool("once", false, "Run the search once and exit, e.g. when scheduled by cron")
+	viper.BindPFlag("watch.once", watchCmd.Flags().Lookup("once"))
+
+	serveCmd.Flags().String("addr", server.DefaultAddr, "Address to listen on, e.g. :8080 to accept connections from other hosts")
+	viper.BindPFlag("serve.addr", serveCmd.Flags().Lookup("addr"))
+
+	serveCmd.Flags().Int("concurrency", server.DefaultConcurrency, "Number of jobs that run at the same time")
+	viper.BindPFlag("serve.concurrency", serveCmd.Flags().Lookup("concurrency"))
+
+	serveCmd.Flags().Duration("retention", server.DefaultRetention, "How long finished jobs and their results are kept, 0 to keep them until --max-finished evicts them")
+	viper.BindPFlag("serve.retention", serveCmd.Flags().Lookup("retention"))
+
+	serveCmd.Flags().Int("max-finished", server.DefaultMaxFinished, "Maximum number of finished jobs that are kept, the oldest are evicted first, 0 for no limit")
+	viper.BindPFlag("serve.max-finished", serveCmd.Flags().Lookup("max-finished"))
+
+	mergeCmd.Flags().String("prefer", merge.PreferNewest, "Record to keep for duplicate places: newest (by file modification time) or complete")
+	viper.BindPFlag("merge.prefer", mergeCmd.Flags().Lookup("prefer"))
+
+	configInitCmd.Flags().Bool("force", false, "Overwrite an existing config file")
+
+	configCmd.AddCommand(configInitCmd)
+	configCmd.AddCommand(configShowCmd)
+	configCmd.AddCommand(configValidateCmd)
+
 	viper.AutomaticEnv()
 
 	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(batchCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(mergeCmd)
+	rootCmd.AddCommand(nearCmd)
+	rootCmd.AddCommand(analyzeCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// exitError makes the process exit with a specific code, printing the wrapped error if there is one
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
 }
 
 func main() {
 	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		var exit *exitError
+		if !errors.As(err, &exit) {
+			exit = &exitError{code: 1, err: err}
+		}
+
+		if exit.err != nil {
+			fmt.Fprintln(os.Stderr, exit.err)
+		}
+		os.Exit(exit.code)
+	}
+}
+
+func defaultVerdictCache() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "maps", "verdicts.json")
+}
+
+// loadConfig applies the settings of the config file and of the selected profile. Flags and environment variables take
+// precedence over the file.
+func loadConfig(cmd *cobra.Command, args []string) error {
+	_, err := applyConfig(cmd.Root())
+	return err
+}
+
+func applyConfig(root *cobra.Command) (*config.File, error) {
+	file, err := readConfig(configSchema(root))
+	if err != nil {
+		return nil, err
+	}
+
+	profile := viper.GetString("profile")
+	if file == nil {
+		if profile != "" {
+			return nil, fmt.Errorf("profile %q requires a config file, create one with maps config init", profile)
+		}
+		return nil, nil
+	}
+
+	settings, err := file.Settings(profile)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file.Path, err)
+	}
+
+	return file, viper.MergeConfigMap(settings)
+}
+
+// readConfig reads and validates the file given by --config or, when there is one, the file in the user config
+// directory. It returns nil without a file.
+func readConfig(schema config.Schema) (*config.File, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, nil
+	}
+
+	file, err := config.Load(path, schema)
+	if errors.Is(err, fs.ErrNotExist) && viper.GetString("config") == "" {
+		return nil, nil
+	}
+
+	return file, err
+}
+
+func configPath() (string, error) {
+	if path := viper.GetString("config"); path != "" {
+		return path, nil
+	}
+	return config.DefaultPath()
+}
+
+// configSchema lists the keys the config file accepts: the keys the flags are bound to, typed like the flags, and the
+// settings of the LLM
+func configSchema(root *cobra.Command) config.Schema {
+	types := make(map[string]string)
+
+	var visit func(cmd *cobra.Command)
+	visit = func(cmd *cobra.Command) {
+		cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+			types[flag.Name] = flag.Value.Type()
+			types[cmd.Name()+"."+flag.Name] = flag.Value.Type()
+		})
+		for _, child := range cmd.Commands() {
+			visit(child)
+		}
+	}
+	visit(root)
+
+	schema := config.Schema{
+		"llm.url":     config.TypeString,
+		"llm.model":   config.TypeString,
+		"llm.api-key": config.TypeString,
+	}
+
+	for _, key := range viper.AllKeys() {
+		if key == "config" {
+			continue
+		}
+
+		schema[key] = config.TypeString
+		if kind, ok := types[key]; ok {
+			schema[key] = kind
+		}
+	}
+
+	return schema
+}
+
+// newSearcher wires the Places client, the LLM and, when requested, the relevance classifier. The returned cache holds
+// the verdicts and must be flushed when the search is done; it is nil without classification.
+func newSearcher(opts app.Options, caller http.Caller, writer app.Writer) (*app.Searcher, *cache.Cache, error) {
+	var verdicts *cache.Cache
+	if opts.Classify {
+		var err error
+		if verdicts, err = cache.NewFile(opts.VerdictCache); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	searcher, err := buildSearcher(opts, caller, writer, verdicts, os.Stderr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return searcher, verdicts, nil
+}
+
+// buildSearcher wires the Places client and the LLM into a searcher. Classification is only available with verdicts.
+func buildSearcher(opts app.Options, caller http.Caller, writer app.Writer, verdicts *cache.Cache, log io.Writer) (*app.Searcher, error) {
+	places := buildPlaces(opts, caller)
+
+	gpt, err := llm.NewChatGPTClientFor(opts.LLM)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := utils.New().
+		WithPromptDir(opts.PromptDir).
+		WithOverride(llm.QueryPromptFile, opts.QueryPrompt).
+		WithOverride(llm.FilterPromptFile, opts.FilterPrompt)
+
+	ai := llm.New(gpt, reader).
+		WithMaxResults(opts.MaxResults).
+		WithLocale(opts.Locale)
+
+	searcher := app.New(places, writer).
+		WithPlanner(llm.NewPlanner(ai, places).WithRounds(opts.Rounds)).
+		WithFilterGenerator(ai).
+		WithLog(log)
+
+	if verdicts != nil {
+		searcher.WithClassifier(llm.NewClassifier(ai, verdicts).WithBatchSize(opts.ClassifyBatchSize))
+	}
+
+	return searcher, nil
+}
+
+// buildPlaces configures the Places client for the options
+func buildPlaces(opts app.Options, caller http.Caller) *client.Client {
+	return client.New(caller, opts.APIKey).
+		WithKeys(opts.Keys).
+		WithTimeout(opts.PageDelay).
+		WithBaseURL(opts.PlacesURL).
+		WithLanguage(opts.Language).
+		WithRegion(opts.Region).
+		WithProvenance(opts.WithProvenance)
+}
+
+// newCaller returns the HTTP caller for the Places API, limited to the configured number of requests per second
+func newCaller() *http.Counter {
+	rest := http.New().WithRetries(3)
+	if viper.GetBool("debug") {
+		rest.WithDebug(os.Stderr)
+	}
+
+	return http.NewCounter(http.NewRateLimiter(rest, viper.GetFloat64("rate-limit")))
+}
+
+// writeKeyUsage reports the requests sent with every API key to stderr, when the requests were spread over several keys
+func writeKeyUsage(opts app.Options) error {
+	if opts.Keys.Len() < 2 {
+		return nil
 	}
+	return opts.Keys.WriteUsage(os.Stderr)
+}
+
+// flush keeps the verdicts of the batches that completed, even when the search failed
+func flush(verdicts *cache.Cache, err error) error {
+	if verdicts == nil {
+		return err
+	}
+
+	if flushErr := verdicts.Flush(); err == nil {
+		err = flushErr
+	}
+
+	return err
 }
 
-// TODO bootstrap this for testing
 func run(cmd *cobra.Command, args []string) error {
-	apiKey := viper.GetString("api-key")
-	if apiKey == "" {
-		return fmt.Errorf("missing Google Places API key, set it via --api-key flag or GOOGLE_API_KEY environment variable")
+	query, err := app.ResolveQuery(viper.GetString("query"), args, os.Stdin)
+	if err != nil {
+		return err
 	}
 
-	c := client.New(http.New().WithRetries(3), apiKey).WithTimeout(5000)
+	opts, err := app.NewOptions(viper.GetViper())
+	if err != nil {
+		return err
+	}
 
-	query := viper.GetString("query")
-	fmt.Printf("Fetching locations for query: %s\n", query)
+	if opts, err = opts.ForQuery(query); err != nil {
+		return err
+	}
+
+	statsFormat := viper.GetString("stats")
+	if statsFormat != "" {
+		if statsFormat, err = stats.ParseFormat(statsFormat); err != nil {
+			return err
+		}
+	}
+
+	coverageFormat := viper.GetString("coverage")
+	if coverageFormat != "" {
+		if coverageFormat, err = coverage.ParseFormat(coverageFormat); err != nil {
+			return err
+		}
+	}
 
-	gpt, err := llm.NewChatGPTClient()
+	var expected []coverage.Expected
+	if path := viper.GetString("coverage-expected"); path != "" {
+		if coverageFormat == "" {
+			return errors.New("--coverage-expected requires --coverage")
+		}
+		if expected, err = coverage.LoadExpected(path); err != nil {
+			return err
+		}
+	}
+
+	searcher, verdicts, err := newSearcher(opts, newCaller(), output.Select(opts.Output, opts.Format, os.Stdout))
 	if err != nil {
 		return err
 	}
 
-	ai := llm.New(gpt, &utils.Utils{})
+	locations, summary, err := searcher.Search(opts)
+	if err := flush(verdicts, err); err != nil {
+		return err
+	}
 
-	if err := ai.ClearHistory(); err != nil {
+	if opts.Output != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d results to file: %s\n", summary.Written, opts.Output)
+	}
+
+	if statsFormat != "" {
+		if err := stats.Profile(locations).Write(os.Stderr, statsFormat); err != nil {
+			return err
+		}
+	}
+
+	if coverageFormat != "" {
+		if err := coverage.Analyze(summary.Plan, locations, expected).Write(os.Stderr, coverageFormat); err != nil {
+			return err
+		}
+	}
+
+	if err := writeKeyUsage(opts); err != nil {
 		return err
 	}
 
-	queries, err := ai.GenerateSubQueries(query)
+	if opts.DedupeReport != "" && summary.Dedupe != nil {
+		data, err := json.MarshalIndent(summary.Dedupe, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(opts.DedupeReport, append(data, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runBatch(cmd *cobra.Command, args []string) error {
+	opts, err := app.NewOptions(viper.GetViper())
 	if err != nil {
 		return err
 	}
 
-	if err := ai.ClearHistory(); err != nil {
+	queries, err := app.ReadQueries(viper.GetString("input"), os.Stdin)
+	if err != nil {
 		return err
 	}
 
-	contains, matches, err := ai.GenerateFilter(query)
+	caller := newCaller()
+
+	searcher, verdicts, err := newSearcher(opts, caller, nil)
 	if err != nil {
 		return err
 	}
 
-	locations, err := c.FetchAllLocations(queries, contains, matches)
+	batch := app.NewBatch(searcher, caller).
+		WithRetries(viper.GetInt("retries")).
+		WithLog(os.Stderr)
+
+	dir := viper.GetString("output-dir")
+	if dir != "" {
+		format := opts.Format
+		if format == "" {
+			format = output.FormatJSON
+		}
+
+		outputDir := output.NewDir(dir, format)
+		if err := outputDir.Create(); err != nil {
+			return err
+		}
+		batch.WithDir(outputDir)
+	}
+
+	switch {
+	case opts.Output != "":
+		batch.WithCombined(output.NewFile(opts.Output).WithFormat(opts.Format))
+	case dir != "":
+		// the files in the directory are the only output
+	case opts.Format == output.FormatNDJSON:
+		batch.WithStream(output.NewStream(os.Stdout, output.FormatNDJSON))
+	default:
+		batch.WithCombined(output.NewStream(os.Stdout, opts.Format))
+	}
+
+	results, err := batch.Run(opts, queries)
+	if err := flush(verdicts, err); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr)
+	if err := app.WriteBatchSummary(os.Stderr, results); err != nil {
+		return err
+	}
+
+	if err := writeKeyUsage(opts); err != nil {
+		return err
+	}
+
+	failed := app.Failed(results)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	if path := viper.GetString("failed"); path != "" {
+		if err := os.WriteFile(path, []byte(strings.Join(failed, "\n")+"\n"), 0644); err != nil {
+			return err
+		}
+		return fmt.Errorf("%d of %d queries failed, re-run them with --input %s", len(failed), len(queries), path)
+	}
+
+	return fmt.Errorf("%d of %d queries failed", len(failed), len(queries))
+}
+
+// diffError makes every failure of diff, including usage errors, exit with 2 so that 1 always means the files differ
+func diffError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &exitError{code: 2, err: err}
+}
+
+func runDiff(cmd *cobra.Command, args []string) error {
+	report, err := compareFiles(args[0], args[1])
+	if err != nil {
+		return diffError(err)
+	}
+
+	if report.Empty() {
+		return nil
+	}
+
+	return &exitError{code: 1}
+}
+
+func compareFiles(oldPath, newPath string) (diff.Report, error) {
+	previous, err := output.ReadFile(oldPath)
+	if err != nil {
+		return diff.Report{}, err
+	}
+
+	current, err := output.ReadFile(newPath)
+	if err != nil {
+		return diff.Report{}, err
+	}
+
+	report := diff.New().
+		WithRadius(viper.GetFloat64("diff.radius")).
+		Compare(output.Locations(previous), output.Locations(current))
+
+	out := os.Stdout
+	if path := viper.GetString("output"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return diff.Report{}, err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if !viper.GetBool("diff.json") {
+		return report, report.WriteText(out)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return diff.Report{}, err
+	}
+
+	_, err = fmt.Fprintln(out, string(data))
+	return report, err
+}
+
+func runMerge(cmd *cobra.Command, args []string) error {
+	prefer, err := merge.ParsePreference(viper.GetString("merge.prefer"))
+	if err != nil {
+		return err
+	}
+
+	format, err := output.ParseFormat(viper.GetString("format"))
 	if err != nil {
 		return err
 	}
 
-	outputFile := viper.GetString("output")
-	if outputFile != "" {
-		fmt.Printf("Writing results to file: %s\n", outputFile)
+	var inputs []merge.Input
+	records := 0
 
-		data, err := json.MarshalIndent(locations, "", "  ")
+	for _, path := range args {
+		input, err := merge.ReadInput(path)
 		if err != nil {
-			return fmt.Errorf("failed to marshal locations: %w", err)
+			return err
 		}
 
-		// Write the JSON to the file
-		if err := os.WriteFile(outputFile, data, 0644); err != nil {
-			return fmt.Errorf("failed to write to file: %w", err)
+		inputs = append(inputs, input)
+		records += len(input.Rows)
+	}
+
+	rows := merge.New().WithPreference(prefer).Merge(inputs)
+
+	var writer app.RowWriter = output.NewStream(os.Stdout, format)
+	if path := viper.GetString("output"); path != "" {
+		writer = output.NewFile(path).WithFormat(format)
+	}
+
+	if err := writer.WriteRows(rows); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Merged %d records from %d files into %d places\n", records, len(inputs), len(rows))
+	return nil
+}
+
+func runNear(cmd *cobra.Command, args []string) error {
+	point := geo.Point{Lat: viper.GetFloat64("near.lat"), Lng: viper.GetFloat64("near.lng")}
+	if point.Lat < -90 || point.Lat > 90 || point.Lng < -180 || point.Lng > 180 {
+		return fmt.Errorf("invalid point %s, use a latitude between -90 and 90 and a longitude between -180 and 180", point)
+	}
+
+	k, radius := viper.GetInt("near.k"), viper.GetFloat64("near.radius")
+	if k < 0 || radius < 0 || (k == 0 && radius == 0) {
+		return fmt.Errorf("invalid --k %d and --radius %v, use a positive number of places, a positive radius or both", k, radius)
+	}
+
+	format, err := output.ParseFormat(viper.GetString("format"))
+	if err != nil {
+		return err
+	}
+
+	rows, err := output.ReadFile(viper.GetString("near.from"))
+	if err != nil {
+		return err
+	}
+
+	index := geo.NewIndex(output.Locations(rows))
+
+	var neighbors []geo.Neighbor
+	if radius > 0 {
+		neighbors = index.Within(point, radius)
+		if k > 0 && len(neighbors) > k {
+			neighbors = neighbors[:k]
 		}
-	} else { // If no output file is specified, print the results to stdout
-		data, err := json.MarshalIndent(locations, "", "  ")
+	} else {
+		neighbors = index.Nearest(point, k)
+	}
+
+	result := make([]output.Row, 0, len(neighbors))
+	for _, neighbor := range neighbors {
+		result = append(result, rows[neighbor.Index])
+		fmt.Fprintf(os.Stderr, "%8.0f m  %s, %s\n", neighbor.Distance, neighbor.Location.Name, neighbor.Location.FormattedAddress)
+	}
+
+	var writer app.RowWriter = output.NewStream(os.Stdout, format)
+	if path := viper.GetString("output"); path != "" {
+		writer = output.NewFile(path).WithFormat(format)
+	}
+
+	return writer.WriteRows(result)
+}
+
+func runClusters(cmd *cobra.Command, args []string) error {
+	algorithm, err := cluster.ParseAlgorithm(viper.GetString("clusters.algorithm"))
+	if err != nil {
+		return err
+	}
+
+	eps, err := geo.ParseDistance(viper.GetString("clusters.eps"))
+	if err != nil {
+		return err
+	}
+
+	rows, err := output.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	report, err := cluster.New().
+		WithAlgorithm(algorithm).
+		WithEps(eps).
+		WithMinPoints(viper.GetInt("clusters.min")).
+		WithK(viper.GetInt("clusters.k")).
+		Cluster(output.Locations(rows))
+	if err != nil {
+		return err
+	}
+
+	if err := report.WriteText(os.Stderr); err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if path := viper.GetString("output"); path != "" {
+		file, err := os.Create(path)
 		if err != nil {
-			return fmt.Errorf("failed to marshal locations: %w", err)
+			return err
 		}
-		fmt.Println(string(data))
+		defer file.Close()
+		out = file
+	}
+
+	if viper.GetBool("clusters.geojson") {
+		return report.WriteGeoJSON(out)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	rows, err := output.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	format := stats.FormatText
+	if viper.GetBool("stats.json") {
+		format = stats.FormatJSON
+	}
+
+	out := os.Stdout
+	if path := viper.GetString("output"); path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	return stats.Profile(output.Locations(rows)).Write(out, format)
+}
+
+func runWatch(cmd *cobra.Command, args []string) error {
+	query, err := app.ResolveQuery(viper.GetString("watch.query"), args, os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	opts, err := app.NewOptions(viper.GetViper())
+	if err != nil {
+		return err
+	}
+
+	if opts, err = opts.ForQuery(query); err != nil {
+		return err
+	}
+
+	store, err := watch.OpenStore(viper.GetString("watch.store"))
+	if err != nil {
+		return err
+	}
+
+	sink, closeSink, err := newEventSink(viper.GetString("watch.events"))
+	if err != nil {
+		return err
+	}
+	defer closeSink()
+
+	searcher, verdicts, err := newSearcher(opts, newCaller(), nil)
+	if err != nil {
+		return err
+	}
+
+	watcher := app.NewWatcher(searcher, store, sink).
+		WithEvery(viper.GetDuration("watch.every")).
+		WithBackoff(viper.GetDuration("watch.backoff")).
+		WithLog(os.Stderr)
+
+	if viper.GetBool("watch.once") {
+		_, err := watcher.Scan(opts)
+		return flush(verdicts, err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return flush(verdicts, watcher.Run(ctx, opts))
+}
+
+// newEventSink picks the sink for the --events flag: stdout, a webhook for URLs or a file that is appended to
+func newEventSink(target string) (app.EventSink, func(), error) {
+	switch {
+	case target == "" || target == app.Stdout:
+		return watch.NewStream(os.Stdout), func() {}, nil
+	case strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://"):
+		return watch.NewWebhook(target, http.New().WithRetries(3)), func() {}, nil
+	}
+
+	file, err := os.OpenFile(target, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return watch.NewStream(file), func() { file.Close() }, nil
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	opts, err := app.NewOptions(viper.GetViper())
+	if err != nil {
+		return err
+	}
+
+	// jobs share the verdicts and the rate limit. Every job builds its own LLM client, whose conversation stays in
+	// memory and starts over with every prompt.
+	verdicts, err := cache.NewFile(opts.VerdictCache)
+	if err != nil {
+		return err
+	}
+
+	caller := newCaller()
+
+	jobs := server.New(opts, func(ctx context.Context, jobOpts app.Options, log io.Writer) (app.Finder, error) {
+		return buildSearcher(jobOpts, http.NewCancelable(ctx, caller), nil, verdicts, log)
+	}).
+		WithConcurrency(viper.GetInt("serve.concurrency")).
+		WithRetention(viper.GetDuration("serve.retention")).
+		WithMaxFinished(viper.GetInt("serve.max-finished")).
+		WithLog(os.Stderr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	jobs.Start(ctx)
+
+	srv := &nethttp.Server{Addr: viper.GetString("serve.addr"), Handler: jobs.Handler()}
+	go func() {
+		<-ctx.Done()
+
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdown)
+	}()
+
+	fmt.Fprintf(os.Stderr, "Listening on %s\n", srv.Addr)
+
+	if err := srv.ListenAndServe(); !errors.Is(err, nethttp.ErrServerClosed) {
+		return flush(verdicts, err)
+	}
+
+	return verdicts.Flush()
+}
+
+func runMCP(cmd *cobra.Command, args []string) error {
+	opts, err := app.NewOptions(viper.GetViper())
+	if err != nil {
+		return err
+	}
+
+	verdicts, err := cache.NewFile(opts.VerdictCache)
+	if err != nil {
+		return err
+	}
+
+	caller := newCaller()
+
+	// stdout carries the protocol, so progress messages go to stderr
+	tools := mcp.New(opts, func(callOpts app.Options, log io.Writer) (app.Finder, error) {
+		return buildSearcher(callOpts, caller, nil, verdicts, log)
+	}, buildPlaces(opts, caller)).WithLog(os.Stderr)
+
+	return flush(verdicts, tools.Serve(os.Stdin, os.Stdout))
+}
+
+func runConfigInit(cmd *cobra.Command, args []string) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	force, _ := cmd.Flags().GetBool("force")
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// the file holds API keys
+	if err := os.WriteFile(path, []byte(config.Template), 0600); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return nil
+}
+
+func runConfigShow(cmd *cobra.Command, args []string) error {
+	schema := configSchema(cmd.Root())
+
+	file, err := applyConfig(cmd.Root())
+	if err != nil {
+		return err
+	}
+
+	settings := make(map[string]any)
+	for key, kind := range schema {
+		switch {
+		case key == "api-keys":
+			var masked []string
+			for _, value := range viper.GetStringSlice(key) {
+				masked = append(masked, client.MaskKey(value))
+			}
+			settings[key] = masked
+		case key == "api-key" || key == "llm.api-key":
+			if value := viper.GetString(key); value != "" {
+				settings[key] = client.MaskKey(value)
+			} else {
+				settings[key] = ""
+			}
+		case kind == config.TypeDuration:
+			settings[key] = viper.GetDuration(key).String()
+		case kind == config.TypeStringSlice:
+			settings[key] = viper.GetStringSlice(key)
+		default:
+			settings[key] = viper.Get(key)
+			if settings[key] == nil {
+				settings[key] = ""
+			}
+		}
+	}
+
+	data, err := config.Show(settings)
+	if err != nil {
+		return err
+	}
+
+	if file == nil {
+		fmt.Println("# no config file")
+	} else {
+		fmt.Printf("# config file: %s\n", file.Path)
+	}
+	fmt.Print(string(data))
+
+	return nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	file, err := readConfig(configSchema(cmd.Root()))
+	if err != nil {
+		return err
+	}
+
+	if file == nil {
+		path, _ := configPath()
+		return fmt.Errorf("no config file at %s, create one with maps config init", path)
+	}
+
+	// the selected profile must exist, whether it comes from --profile or from the file
+	if _, err := file.Settings(viper.GetString("profile")); err != nil {
+		return fmt.Errorf("%s: %w", file.Path, err)
+	}
+
+	message := fmt.Sprintf("%s is valid", file.Path)
+	if profiles := file.Profiles(); len(profiles) > 0 {
+		message += fmt.Sprintf(", profiles: %s", strings.Join(profiles, ", "))
 	}
+	fmt.Println(message)
 
 	return nil
 }
@@ -0,0 +1,441 @@
+// Package config finds, validates and reads the config file of the CLI. The file sets the same keys as the flags, with
+// the keys of a subcommand nested under its name, e.g. watch.every, and can bundle settings into named profiles.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	DirName     = "maps"
+	FileName    = "config.yaml"
+	ProfileKey  = "profile"
+	ProfilesKey = "profiles"
+)
+
+// The types of the values of a schema, named after the types of the flags
+const (
+	TypeString      = "string"
+	TypeInt         = "int"
+	TypeFloat       = "float64"
+	TypeBool        = "bool"
+	TypeDuration    = "duration"
+	TypeStringSlice = "stringSlice"
+)
+
+// DefaultPath returns the config file in the user config directory, $XDG_CONFIG_HOME/maps/config.yaml or
+// ~/.config/maps/config.yaml on Linux
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, DirName, FileName), nil
+}
+
+// Schema maps every key the file accepts to the type of its value. Nested keys are joined with dots.
+type Schema map[string]string
+
+// section reports whether name groups nested keys, e.g. watch for watch.every
+func (s Schema) section(name string) bool {
+	for key := range s {
+		if strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns the key or section closest to the misspelled name, an empty string when none is close
+func (s Schema) suggest(name string) string {
+	best, bestDistance := "", 3
+
+	for key := range s {
+		parts := strings.Split(key, ".")
+
+		// the key and the sections it is nested in
+		for i := 1; i <= len(parts); i++ {
+			candidate := strings.Join(parts[:i], ".")
+			distance := levenshtein(name, candidate)
+			if distance < bestDistance || (distance == bestDistance && candidate < best) {
+				best, bestDistance = candidate, distance
+			}
+		}
+	}
+
+	return best
+}
+
+// Problem is a mistake in the config file
+type Problem struct {
+	Line    int
+	Key     string
+	Message string
+}
+
+func (p Problem) String() string {
+	var sb strings.Builder
+	if p.Line > 0 {
+		sb.WriteString(fmt.Sprintf("line %d: ", p.Line))
+	}
+	if p.Key != "" {
+		sb.WriteString(p.Key + ": ")
+	}
+	sb.WriteString(p.Message)
+	return sb.String()
+}
+
+// Error lists every problem of a config file
+type Error struct {
+	Path     string
+	Problems []Problem
+}
+
+func (e *Error) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString("invalid config file")
+	if e.Path != "" {
+		sb.WriteString(" " + e.Path)
+	}
+	sb.WriteString(":")
+
+	for _, problem := range e.Problems {
+		sb.WriteString("\n  " + problem.String())
+	}
+
+	return sb.String()
+}
+
+// File holds the settings of a config file and of its profiles
+type File struct {
+	Path     string
+	settings map[string]any
+	profiles map[string]map[string]any
+}
+
+// Load reads and validates the config file at path
+func Load(path string, schema Schema) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := Decode(data, schema)
+	if err != nil {
+		var configErr *Error
+		if errors.As(err, &configErr) {
+			configErr.Path = path
+		}
+		return nil, err
+	}
+
+	file.Path = path
+	return file, nil
+}
+
+// Decode parses a config file and checks every key and value against the schema. All problems are reported at once.
+func Decode(data []byte, schema Schema) (*File, error) {
+	file := &File{settings: map[string]any{}, profiles: map[string]map[string]any{}}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &Error{Problems: []Problem{{Message: strings.TrimPrefix(err.Error(), "yaml: ")}}}
+	}
+
+	// a file without settings, e.g. only comments
+	if len(root.Content) == 0 || root.Content[0].Tag == "!!null" {
+		return file, nil
+	}
+
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		return nil, &Error{Problems: []Problem{{Line: document.Line, Message: "expected a mapping of keys to values"}}}
+	}
+
+	if problems := validate(document, schema, "", true); len(problems) > 0 {
+		return nil, &Error{Problems: problems}
+	}
+
+	if err := document.Decode(&file.settings); err != nil {
+		return nil, err
+	}
+
+	if profiles, ok := file.settings[ProfilesKey].(map[string]any); ok {
+		for name, settings := range profiles {
+			file.profiles[name], _ = settings.(map[string]any)
+			if file.profiles[name] == nil {
+				file.profiles[name] = map[string]any{}
+			}
+		}
+	}
+	delete(file.settings, ProfilesKey)
+
+	return file, nil
+}
+
+// Profiles returns the names of the profiles, sorted
+func (f *File) Profiles() []string {
+	var result []string
+	for name := range f.profiles {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Settings returns the settings of the file with the settings of the profile on top. Without a profile, the profile
+// named by the profile key of the file is used, if any.
+func (f *File) Settings(profile string) (map[string]any, error) {
+	if profile == "" {
+		profile, _ = f.settings[ProfileKey].(string)
+	}
+
+	result := merge(map[string]any{}, f.settings)
+	delete(result, ProfileKey)
+
+	if profile == "" {
+		return result, nil
+	}
+
+	settings, ok := f.profiles[profile]
+	if !ok {
+		if len(f.profiles) == 0 {
+			return nil, fmt.Errorf("unknown profile %q, the config file does not define any profiles", profile)
+		}
+		return nil, fmt.Errorf("unknown profile %q, use one of %s", profile, strings.Join(f.Profiles(), ", "))
+	}
+
+	return merge(result, settings), nil
+}
+
+// merge copies the settings of src into dst, merging nested sections
+func merge(dst, src map[string]any) map[string]any {
+	for key, value := range src {
+		if section, ok := value.(map[string]any); ok {
+			existing, _ := dst[key].(map[string]any)
+			dst[key] = merge(merge(map[string]any{}, existing), section)
+			continue
+		}
+		dst[key] = value
+	}
+	return dst
+}
+
+// validate checks the keys of a mapping. Profiles are only accepted at the top level of the file.
+func validate(node *yaml.Node, schema Schema, prefix string, top bool) []Problem {
+	var problems []Problem
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, value := node.Content[i], node.Content[i+1]
+		name := prefix + keyNode.Value
+
+		switch {
+		case top && keyNode.Value == ProfilesKey:
+			problems = append(problems, validateProfiles(value, schema)...)
+		case !top && prefix == "" && keyNode.Value == ProfileKey:
+			problems = append(problems, Problem{Line: keyNode.Line, Key: name, Message: "a profile cannot select another profile"})
+		case schema[name] != "":
+			if message := check(value, schema[name]); message != "" {
+				problems = append(problems, Problem{Line: value.Line, Key: name, Message: message})
+			}
+		case schema.section(name):
+			if value.Kind != yaml.MappingNode {
+				problems = append(problems, Problem{Line: value.Line, Key: name, Message: "expected a mapping of keys to values"})
+				continue
+			}
+			problems = append(problems, validate(value, schema, name+".", false)...)
+		default:
+			message := "unknown key"
+			if suggestion := schema.suggest(name); suggestion != "" {
+				message += fmt.Sprintf(", did you mean %s?", suggestion)
+			}
+			problems = append(problems, Problem{Line: keyNode.Line, Key: name, Message: message})
+		}
+	}
+
+	return problems
+}
+
+func validateProfiles(node *yaml.Node, schema Schema) []Problem {
+	if node.Kind != yaml.MappingNode {
+		return []Problem{{Line: node.Line, Key: ProfilesKey, Message: "expected a mapping of profile names to settings"}}
+	}
+
+	var problems []Problem
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name, value := node.Content[i], node.Content[i+1]
+
+		if value.Kind != yaml.MappingNode {
+			problems = append(problems, Problem{Line: value.Line, Key: ProfilesKey + "." + name.Value, Message: "expected a mapping of keys to values"})
+			continue
+		}
+		for _, problem := range validate(value, schema, "", false) {
+			problem.Key = ProfilesKey + "." + name.Value + "." + problem.Key
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems
+}
+
+// check returns why the value does not fit the type, an empty string when it does
+func check(node *yaml.Node, kind string) string {
+	if node.Tag == "!!null" {
+		return "missing value"
+	}
+
+	if kind == TypeStringSlice {
+		if node.Kind == yaml.ScalarNode {
+			return ""
+		}
+		if node.Kind == yaml.SequenceNode {
+			for _, item := range node.Content {
+				if item.Kind != yaml.ScalarNode {
+					return "expected a list of strings"
+				}
+			}
+			return ""
+		}
+		return "expected a list of strings"
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Sprintf("expected %s", describe(kind))
+	}
+
+	var err error
+	switch kind {
+	case TypeInt:
+		var value int
+		err = node.Decode(&value)
+	case TypeFloat:
+		var value float64
+		err = node.Decode(&value)
+	case TypeBool:
+		var value bool
+		err = node.Decode(&value)
+	case TypeDuration:
+		_, err = time.ParseDuration(node.Value)
+	}
+
+	if err != nil {
+		return fmt.Sprintf("expected %s, got %q", describe(kind), node.Value)
+	}
+	return ""
+}
+
+func describe(kind string) string {
+	switch kind {
+	case TypeInt:
+		return "an integer"
+	case TypeFloat:
+		return "a number"
+	case TypeBool:
+		return "true or false"
+	case TypeDuration:
+		return "a duration, e.g. 90s or 24h"
+	case TypeStringSlice:
+		return "a list of strings"
+	default:
+		return "a string"
+	}
+}
+
+// levenshtein counts the single character edits between two strings
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+// Show renders the settings as YAML, nesting the keys that contain dots
+func Show(settings map[string]any) ([]byte, error) {
+	nested := map[string]any{}
+
+	for key, value := range settings {
+		parts := strings.Split(key, ".")
+
+		section := nested
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := section[part].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				section[part] = child
+			}
+			section = child
+		}
+		section[parts[len(parts)-1]] = value
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(nested); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Template is the file written by maps config init. Every setting is commented out.
+const Template = `# Settings of maps. The keys are the names of the flags; the flags of a subcommand are nested under
+# its name. Flags and environment variables take precedence over this file.
+#
+# api-key: YOUR_GOOGLE_PLACES_API_KEY
+# api-keys: [KEY_OF_PROJECT_A, KEY_OF_PROJECT_B]
+# language: en
+# region: us
+# locale: en-US
+# rate-limit: 10
+# max-results: 50
+# prompt-dir: /home/me/.maps/prompts
+#
+# The LLM that breaks the query down and generates the name rules. The OPENAI_* environment variables take
+# precedence.
+# llm:
+#   url: https://api.openai.com
+#   model: gpt-4o
+#   api-key: YOUR_OPENAI_API_KEY
+#
+# watch:
+#   every: 24h
+#
+# Profiles bundle settings, selected with --profile, MAPS_PROFILE or the profile key.
+# profile: eu
+# profiles:
+#   eu:
+#     api-keys: [KEY_OF_THE_EU_PROJECT]
+#     language: de
+#     region: de
+#     locale: de-DE
+#     rate-limit: 5
+#     llm:
+#       model: gpt-4o-mini
+`
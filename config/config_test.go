@@ -0,0 +1,183 @@
+package config_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kardolus/maps/config"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitConfig(t *testing.T) {
+	spec.Run(t, "Config Unit Tests", testConfig, spec.Report(report.Terminal{}))
+}
+
+func testConfig(t *testing.T, when spec.G, it spec.S) {
+	schema := config.Schema{
+		"api-key":     config.TypeString,
+		"api-keys":    config.TypeStringSlice,
+		"locale":      config.TypeString,
+		"max-results": config.TypeInt,
+		"rate-limit":  config.TypeFloat,
+		"dedupe":      config.TypeBool,
+		"profile":     config.TypeString,
+		"watch.every": config.TypeDuration,
+		"watch.once":  config.TypeBool,
+		"llm.model":   config.TypeString,
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	when("validating a file", func() {
+		it("accepts the keys of the schema", func() {
+			file, err := config.Decode([]byte(`
+api-key: key
+api-keys: [key-1, key-2]
+max-results: 50
+rate-limit: 2.5
+dedupe: true
+watch:
+  every: 24h
+profiles:
+  eu:
+    locale: de-DE
+    llm:
+      model: gpt-4o-mini
+`), schema)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.Profiles()).To(Equal([]string{"eu"}))
+		})
+
+		it("accepts an empty file", func() {
+			file, err := config.Decode([]byte(config.Template), schema)
+			Expect(err).NotTo(HaveOccurred())
+
+			settings, err := file.Settings("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings).To(BeEmpty())
+		})
+
+		it("reports every unknown key and invalid value with its line", func() {
+			_, err := config.Decode([]byte(`max-result: 50
+rate-limit: fast
+watch:
+  evry: 1h
+  once: sometimes
+profiles:
+  eu:
+    local: de-DE
+    profile: us
+api-keys: {a: b}
+`), schema)
+
+			var configErr *config.Error
+			Expect(errors.As(err, &configErr)).To(BeTrue())
+			Expect(err.Error()).To(Equal("invalid config file:\n" +
+				"  line 1: max-result: unknown key, did you mean max-results?\n" +
+				"  line 2: rate-limit: expected a number, got \"fast\"\n" +
+				"  line 4: watch.evry: unknown key, did you mean watch.every?\n" +
+				"  line 5: watch.once: expected true or false, got \"sometimes\"\n" +
+				"  line 8: profiles.eu.local: unknown key, did you mean locale?\n" +
+				"  line 9: profiles.eu.profile: a profile cannot select another profile\n" +
+				"  line 10: api-keys: expected a list of strings"))
+		})
+
+		it("does not suggest keys that are far off", func() {
+			_, err := config.Decode([]byte("colour: blue\n"), schema)
+			Expect(err).To(MatchError("invalid config file:\n  line 1: colour: unknown key"))
+		})
+
+		it("reports malformed YAML", func() {
+			_, err := config.Decode([]byte("api-key: [\n"), schema)
+			Expect(err).To(MatchError(ContainSubstring("invalid config file:\n  line")))
+
+			_, err = config.Decode([]byte("- a\n- b\n"), schema)
+			Expect(err).To(MatchError(ContainSubstring("expected a mapping of keys to values")))
+		})
+	})
+
+	when("applying a profile", func() {
+		file, err := config.Decode([]byte(`
+locale: en-US
+max-results: 50
+profile: us
+llm:
+  model: gpt-4o
+profiles:
+  us:
+    locale: en-US
+  eu:
+    locale: de-DE
+    llm:
+      model: gpt-4o-mini
+`), schema)
+
+		it.Before(func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		it("puts the settings of the profile on top", func() {
+			settings, err := file.Settings("eu")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings).To(Equal(map[string]any{
+				"locale":      "de-DE",
+				"max-results": 50,
+				"llm":         map[string]any{"model": "gpt-4o-mini"},
+			}))
+		})
+
+		it("uses the profile of the file by default", func() {
+			settings, err := file.Settings("")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(settings["locale"]).To(Equal("en-US"))
+			Expect(settings).NotTo(HaveKey("profile"))
+		})
+
+		it("rejects an unknown profile", func() {
+			_, err := file.Settings("asia")
+			Expect(err).To(MatchError(`unknown profile "asia", use one of eu, us`))
+
+			empty, err := config.Decode(nil, schema)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = empty.Settings("eu")
+			Expect(err).To(MatchError(ContainSubstring("does not define any profiles")))
+		})
+	})
+
+	when("loading a file", func() {
+		it("names the file in the errors", func() {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			Expect(os.WriteFile(path, []byte("max-result: 50\n"), 0600)).To(Succeed())
+
+			_, err := config.Load(path, schema)
+			Expect(err).To(MatchError(HavePrefix("invalid config file " + path + ":\n")))
+		})
+
+		it("returns a missing file as is", func() {
+			_, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml"), schema)
+			Expect(errors.Is(err, fs.ErrNotExist)).To(BeTrue())
+		})
+
+		it("finds the file in the user config directory", func() {
+			t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+			t.Setenv("HOME", "/tmp/home")
+
+			path, err := config.DefaultPath()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Or(Equal("/tmp/xdg/maps/config.yaml"), HaveSuffix(filepath.Join("maps", "config.yaml"))))
+		})
+	})
+
+	it("shows the settings nested by section", func() {
+		data, err := config.Show(map[string]any{"locale": "en-US", "watch.every": "24h", "watch.once": false})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("locale: en-US\nwatch:\n  every: 24h\n  once: false\n"))
+	})
+}
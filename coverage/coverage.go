@@ -0,0 +1,289 @@
+// Package coverage reports how well a search covered its area: how many results and pages every sub-query of the plan
+// returned, which sub-queries hit the Places API result cap and were likely cut off, which returned nothing and, given
+// the expected number of places per query or region, where places are missing.
+package coverage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/address"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/stats"
+	"github.com/kardolus/maps/types"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseFormat validates the format of a report
+func ParseFormat(format string) (string, error) {
+	switch format {
+	case FormatText, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid coverage format %q, use %s or %s", format, FormatText, FormatJSON)
+	}
+}
+
+// Expected is the number of places a query or a region should have. Exactly one of Query and Region is set.
+type Expected struct {
+	Query  string
+	Region string
+	Count  int
+}
+
+// LoadExpected reads the expected counts from a CSV file with an expected column and a query or region column.
+// Queries are matched against the sub-queries of the plan, regions against the state and country of the results;
+// US states and Canadian provinces can be given by name or code.
+func LoadExpected(path string) ([]Expected, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := DecodeExpected(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expected counts from %s: %w", path, err)
+	}
+
+	return result, nil
+}
+
+// DecodeExpected parses the CSV accepted by LoadExpected
+func DecodeExpected(data []byte) ([]Expected, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("the file is empty")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	_, hasQuery := columns["query"]
+	_, hasRegion := columns["region"]
+	if _, ok := columns["expected"]; !ok || hasQuery == hasRegion {
+		return nil, errors.New("the CSV header needs an expected column and either a query or a region column")
+	}
+
+	var result []Expected
+	for n, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		count, err := strconv.Atoi(field("expected"))
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("line %d: invalid expected count %q", n+2, field("expected"))
+		}
+
+		expected := Expected{Query: field("query"), Region: field("region"), Count: count}
+		if expected.Query == "" && expected.Region == "" {
+			continue
+		}
+
+		result = append(result, expected)
+	}
+
+	return result, nil
+}
+
+// Query is a sub-query of the plan with its counts. Kept counts the results that passed the name rules, Found the kept
+// results of the query and the queries it was split into.
+type Query struct {
+	Query     string `json:"query"`
+	Depth     int    `json:"depth"`
+	Results   int    `json:"results"`
+	Pages     int    `json:"pages"`
+	Kept      int    `json:"kept"`
+	Found     int    `json:"found"`
+	Saturated bool   `json:"saturated"`
+	Split     bool   `json:"split"`
+	Empty     bool   `json:"empty"`
+}
+
+// Gap compares the expected number of places of a query or region with the number that was found
+type Gap struct {
+	Query    string `json:"query,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Expected int    `json:"expected"`
+	Found    int    `json:"found"`
+	Missing  int    `json:"missing"`
+}
+
+// Report lists every sub-query of the plan in the order it was planned. Undersearched are the saturated queries that
+// were not split further, so their results were likely cut off.
+type Report struct {
+	Query         string   `json:"query"`
+	Queries       []Query  `json:"queries"`
+	Saturated     int      `json:"saturated"`
+	Undersearched []string `json:"undersearched"`
+	Empty         []string `json:"empty"`
+	Gaps          []Gap    `json:"gaps,omitempty"`
+}
+
+// Analyze builds the report of the plan. The locations are the results of the search, used to count the places per
+// expected region.
+func Analyze(plan *llm.PlanNode, locations []types.Location, expected []Expected) Report {
+	report := Report{Queries: []Query{}, Undersearched: []string{}, Empty: []string{}}
+	if plan == nil {
+		return report
+	}
+
+	report.Query = plan.Query
+
+	var visit func(node *llm.PlanNode, depth int) int
+	visit = func(node *llm.PlanNode, depth int) int {
+		at := len(report.Queries)
+		report.Queries = append(report.Queries, Query{
+			Query:     node.Query,
+			Depth:     depth,
+			Results:   node.Stats.Results,
+			Pages:     node.Stats.Pages,
+			Kept:      node.Stats.Kept,
+			Saturated: node.Stats.Saturated(),
+			Split:     len(node.Children) > 0,
+			Empty:     node.Stats.Results == 0,
+		})
+
+		found := node.Stats.Kept
+		for _, child := range node.Children {
+			found += visit(child, depth+1)
+		}
+		report.Queries[at].Found = found
+
+		return found
+	}
+
+	for _, child := range plan.Children {
+		visit(child, 0)
+	}
+
+	// a search without a planner sends the query as is
+	if len(plan.Children) == 0 {
+		visit(plan, 0)
+	}
+
+	for _, query := range report.Queries {
+		if query.Saturated {
+			report.Saturated++
+			if !query.Split {
+				report.Undersearched = append(report.Undersearched, query.Query)
+			}
+		}
+		if query.Empty {
+			report.Empty = append(report.Empty, query.Query)
+		}
+	}
+
+	for _, e := range expected {
+		gap := Gap{Query: e.Query, Region: e.Region, Expected: e.Count}
+
+		if e.Query != "" {
+			for _, query := range report.Queries {
+				if strings.EqualFold(query.Query, e.Query) {
+					gap.Found = query.Found
+					break
+				}
+			}
+		} else {
+			gap.Found = countRegion(locations, e.Region)
+		}
+
+		gap.Missing = max(0, gap.Expected-gap.Found)
+		report.Gaps = append(report.Gaps, gap)
+	}
+
+	return report
+}
+
+// countRegion counts the locations whose state or country is the region
+func countRegion(locations []types.Location, region string) int {
+	code := address.RegionCode(region)
+
+	count := 0
+	for _, location := range locations {
+		state, country := stats.Region(location)
+		if strings.EqualFold(address.RegionCode(state), code) || strings.EqualFold(country, region) {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteText renders the report for humans: the plan with the counts of every sub-query, followed by the gaps
+func (r Report) WriteText(w io.Writer) error {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Coverage of %s: %d sub-queries, %d saturated, %d undersearched, %d without results\n",
+		r.Query, len(r.Queries), r.Saturated, len(r.Undersearched), len(r.Empty)))
+
+	for _, query := range r.Queries {
+		line := fmt.Sprintf("%s%s (%d results, %d pages, %d kept)", strings.Repeat("  ", query.Depth+1), query.Query,
+			query.Results, query.Pages, query.Kept)
+
+		switch {
+		case query.Saturated && !query.Split:
+			line += " [saturated, undersearched]"
+		case query.Saturated:
+			line += " [saturated, split]"
+		case query.Empty:
+			line += " [no results]"
+		}
+
+		sb.WriteString(line + "\n")
+	}
+
+	if len(r.Gaps) > 0 {
+		sb.WriteString("Expected counts:\n")
+	}
+	for _, gap := range r.Gaps {
+		key := gap.Query
+		if key == "" {
+			key = gap.Region
+		}
+
+		line := fmt.Sprintf("  %s: %d of %d found", key, gap.Found, gap.Expected)
+		if gap.Missing > 0 {
+			line += fmt.Sprintf(", %d missing", gap.Missing)
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Write renders the report in the format, FormatText or FormatJSON
+func (r Report) Write(w io.Writer, format string) error {
+	if format != FormatJSON {
+		return r.WriteText(w)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
@@ -0,0 +1,165 @@
+package coverage_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kardolus/maps/coverage"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitCoverage(t *testing.T) {
+	spec.Run(t, "Coverage Unit Tests", testCoverage, spec.Report(report.Terminal{}))
+}
+
+func testCoverage(t *testing.T, when spec.G, it spec.S) {
+	var (
+		node = func(query string, results, pages, kept int, children ...*llm.PlanNode) *llm.PlanNode {
+			return &llm.PlanNode{Query: query, Stats: types.QueryStats{Query: query, Results: results, Pages: pages, Kept: kept}, Children: children}
+		}
+		plan = node("Whole Foods in USA", 0, 0, 0,
+			node("Whole Foods in Ohio", 60, 3, 30,
+				node("Whole Foods in Columbus", 25, 2, 12),
+				node("Whole Foods in Cleveland", 60, 3, 20),
+			),
+			node("Whole Foods in Iowa", 2, 1, 2),
+			node("Whole Foods in Wyoming", 0, 1, 0),
+		)
+		at = func(address string) types.Location {
+			return types.Location{FormattedAddress: address}
+		}
+		locations = []types.Location{
+			at("1555 W Lane Ave, Columbus, OH 43221, United States"),
+			at("13998 Cedar Rd, South Euclid, OH 44118, United States"),
+			at("4721 University Ave, Des Moines, IA 50311, United States"),
+		}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	when("analyzing the plan", func() {
+		it("lists every sub-query with its counts", func() {
+			r := coverage.Analyze(plan, locations, nil)
+
+			Expect(r.Query).To(Equal("Whole Foods in USA"))
+			Expect(r.Queries).To(Equal([]coverage.Query{
+				{Query: "Whole Foods in Ohio", Depth: 0, Results: 60, Pages: 3, Kept: 30, Found: 62, Saturated: true, Split: true},
+				{Query: "Whole Foods in Columbus", Depth: 1, Results: 25, Pages: 2, Kept: 12, Found: 12},
+				{Query: "Whole Foods in Cleveland", Depth: 1, Results: 60, Pages: 3, Kept: 20, Found: 20, Saturated: true},
+				{Query: "Whole Foods in Iowa", Depth: 0, Results: 2, Pages: 1, Kept: 2, Found: 2},
+				{Query: "Whole Foods in Wyoming", Depth: 0, Results: 0, Pages: 1, Kept: 0, Found: 0, Empty: true},
+			}))
+		})
+
+		it("flags saturated and empty sub-queries", func() {
+			r := coverage.Analyze(plan, locations, nil)
+
+			Expect(r.Saturated).To(Equal(2))
+			Expect(r.Undersearched).To(Equal([]string{"Whole Foods in Cleveland"}))
+			Expect(r.Empty).To(Equal([]string{"Whole Foods in Wyoming"}))
+			Expect(r.Gaps).To(BeEmpty())
+		})
+
+		it("reports a query that was not broken down", func() {
+			r := coverage.Analyze(node("Whole Foods in Ohio", 60, 3, 40), nil, nil)
+
+			Expect(r.Queries).To(HaveLen(1))
+			Expect(r.Undersearched).To(Equal([]string{"Whole Foods in Ohio"}))
+		})
+
+		it("handles a missing plan", func() {
+			r := coverage.Analyze(nil, nil, nil)
+			Expect(r.Queries).To(BeEmpty())
+		})
+	})
+
+	when("comparing against expected counts", func() {
+		it("finds the missing places per query and region", func() {
+			r := coverage.Analyze(plan, locations, []coverage.Expected{
+				{Query: "whole foods in ohio", Count: 70},
+				{Query: "Whole Foods in Iowa", Count: 1},
+				{Query: "Whole Foods in Texas", Count: 40},
+				{Region: "Ohio", Count: 5},
+				{Region: "IA", Count: 1},
+				{Region: "United States", Count: 4},
+			})
+
+			Expect(r.Gaps).To(Equal([]coverage.Gap{
+				{Query: "whole foods in ohio", Expected: 70, Found: 62, Missing: 8},
+				{Query: "Whole Foods in Iowa", Expected: 1, Found: 2, Missing: 0},
+				{Query: "Whole Foods in Texas", Expected: 40, Found: 0, Missing: 40},
+				{Region: "Ohio", Expected: 5, Found: 2, Missing: 3},
+				{Region: "IA", Expected: 1, Found: 1, Missing: 0},
+				{Region: "United States", Expected: 4, Found: 3, Missing: 1},
+			}))
+		})
+
+		it("reads the expected counts from CSV", func() {
+			path := filepath.Join(t.TempDir(), "expected.csv")
+			Expect(os.WriteFile(path, []byte("Region,Expected\nOhio,5\n,3\nIowa, 2\n"), 0644)).To(Succeed())
+
+			expected, err := coverage.LoadExpected(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expected).To(Equal([]coverage.Expected{{Region: "Ohio", Count: 5}, {Region: "Iowa", Count: 2}}))
+
+			expected, err = coverage.DecodeExpected([]byte("query,expected\nWhole Foods in Ohio,70\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expected).To(Equal([]coverage.Expected{{Query: "Whole Foods in Ohio", Count: 70}}))
+		})
+
+		it("rejects invalid CSV", func() {
+			for data, message := range map[string]string{
+				"":                               "empty",
+				"region\nOhio\n":                 "expected column",
+				"query,region,expected\na,b,1\n": "either a query or a region",
+				"region,expected\nOhio,many\n":   `line 2: invalid expected count "many"`,
+				"region,expected\nOhio,-1\n":     "invalid expected count",
+			} {
+				_, err := coverage.DecodeExpected([]byte(data))
+				Expect(err).To(MatchError(ContainSubstring(message)), data)
+			}
+		})
+	})
+
+	when("writing the report", func() {
+		it("renders text", func() {
+			var buf bytes.Buffer
+			r := coverage.Analyze(plan, locations, []coverage.Expected{{Region: "Ohio", Count: 5}, {Region: "Iowa", Count: 1}})
+			Expect(r.Write(&buf, coverage.FormatText)).To(Succeed())
+
+			Expect(buf.String()).To(Equal("Coverage of Whole Foods in USA: 5 sub-queries, 2 saturated, 1 undersearched, 1 without results\n" +
+				"  Whole Foods in Ohio (60 results, 3 pages, 30 kept) [saturated, split]\n" +
+				"    Whole Foods in Columbus (25 results, 2 pages, 12 kept)\n" +
+				"    Whole Foods in Cleveland (60 results, 3 pages, 20 kept) [saturated, undersearched]\n" +
+				"  Whole Foods in Iowa (2 results, 1 pages, 2 kept)\n" +
+				"  Whole Foods in Wyoming (0 results, 1 pages, 0 kept) [no results]\n" +
+				"Expected counts:\n" +
+				"  Ohio: 2 of 5 found, 3 missing\n" +
+				"  Iowa: 1 of 1 found\n"))
+		})
+
+		it("renders JSON", func() {
+			var buf bytes.Buffer
+			r := coverage.Analyze(plan, locations, nil)
+			Expect(r.Write(&buf, coverage.FormatJSON)).To(Succeed())
+
+			var decoded coverage.Report
+			Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded).To(Equal(r))
+		})
+
+		it("validates the format", func() {
+			_, err := coverage.ParseFormat("html")
+			Expect(err).To(MatchError(ContainSubstring("invalid coverage format")))
+		})
+	})
+}
@@ -0,0 +1,231 @@
+// Package dedupe merges places that the Places API returns under different place ids, like a store and its pharmacy
+// department, by clustering nearby results with similar names.
+package dedupe
+
+import (
+	"fmt"
+	"github.com/kardolus/maps/geo"
+	"github.com/kardolus/maps/types"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultRadius     = 50.0 // meters
+	DefaultSimilarity = 0.8
+)
+
+var (
+	nonAlphanumeric = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	apostrophes     = strings.NewReplacer("'", "", "’", "")
+)
+
+// Alias is a place that was merged into a canonical place
+type Alias struct {
+	PlaceId          string  `json:"place_id"`
+	Name             string  `json:"name"`
+	FormattedAddress string  `json:"formatted_address"`
+	Distance         float64 `json:"distance"`
+	Similarity       float64 `json:"similarity"`
+}
+
+// Group is a canonical place together with the places merged into it
+type Group struct {
+	PlaceId          string  `json:"place_id"`
+	Name             string  `json:"name"`
+	FormattedAddress string  `json:"formatted_address"`
+	Merged           []Alias `json:"merged"`
+}
+
+type Report struct {
+	Groups []Group `json:"groups"`
+}
+
+// Duplicates returns the number of places that were merged into another one
+func (r Report) Duplicates() int {
+	count := 0
+	for _, group := range r.Groups {
+		count += len(group.Merged)
+	}
+	return count
+}
+
+// WriteText renders the report for humans, one line per canonical place and one indented line per merged place
+func (r Report) WriteText(w io.Writer) error {
+	var sb strings.Builder
+
+	for _, group := range r.Groups {
+		sb.WriteString(fmt.Sprintf("%s, %s (%s)\n", group.Name, group.FormattedAddress, group.PlaceId))
+		for _, alias := range group.Merged {
+			sb.WriteString(fmt.Sprintf("    + %s, %s (%s) %.0fm, %.0f%% similar\n",
+				alias.Name, alias.FormattedAddress, alias.PlaceId, alias.Distance, alias.Similarity*100))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type Deduper struct {
+	radius     float64
+	similarity float64
+}
+
+func New() *Deduper {
+	return &Deduper{radius: DefaultRadius, similarity: DefaultSimilarity}
+}
+
+// WithRadius configures the distance in meters within which two places can be the same place
+func (d *Deduper) WithRadius(radius float64) *Deduper {
+	d.radius = radius
+	return d
+}
+
+// WithSimilarity configures the minimum similarity, between 0 and 1, of the names of two places that are the same
+// place. See Similarity.
+func (d *Deduper) WithSimilarity(similarity float64) *Deduper {
+	d.similarity = similarity
+	return d
+}
+
+// Dedupe merges every place into the most reviewed place within the radius whose name is similar enough. The most
+// reviewed place of a cluster is kept as the canonical record, since departments and secondary listings tend to
+// have fewer reviews, and the ids of the merged places are added to its aliases. Places keep their order.
+func (d *Deduper) Dedupe(locations []types.Location) ([]types.Location, Report) {
+	report := Report{Groups: []Group{}}
+
+	order := make([]int, len(locations))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return types.Value(locations[order[a]].UserRatingsTotal) > types.Value(locations[order[b]].UserRatingsTotal)
+	})
+
+	rank := make([]int, len(locations))
+	for position, i := range order {
+		rank[i] = position
+	}
+
+	index := geo.NewIndex(locations)
+
+	tokens := make([][]string, len(locations))
+	for i, location := range locations {
+		tokens[i] = Tokenize(location.Name)
+	}
+
+	merged := make([]bool, len(locations))
+	aliased := make([]bool, len(locations))
+	result := make([]types.Location, len(locations))
+	copy(result, locations)
+
+	for _, i := range order {
+		if merged[i] {
+			continue
+		}
+
+		canonical := &result[i]
+		group := Group{PlaceId: canonical.PlaceId, Name: canonical.Name, FormattedAddress: canonical.FormattedAddress}
+
+		nearby := index.Within(geo.PointOf(locations[i]), d.radius)
+		sort.SliceStable(nearby, func(a, b int) bool { return rank[nearby[a].Index] < rank[nearby[b].Index] })
+
+		for _, neighbor := range nearby {
+			j, distance := neighbor.Index, neighbor.Distance
+			if j == i || merged[j] {
+				continue
+			}
+
+			score := similarity(tokens[i], tokens[j])
+			if score < d.similarity {
+				continue
+			}
+
+			merged[j] = true
+			aliased[j] = true
+			canonical.Aliases = appendUnique(canonical.Aliases, append([]string{locations[j].PlaceId}, locations[j].Aliases...)...)
+			group.Merged = append(group.Merged, Alias{
+				PlaceId:          locations[j].PlaceId,
+				Name:             locations[j].Name,
+				FormattedAddress: locations[j].FormattedAddress,
+				Distance:         math.Round(distance*10) / 10,
+				Similarity:       math.Round(score*100) / 100,
+			})
+		}
+
+		if len(group.Merged) > 0 {
+			report.Groups = append(report.Groups, group)
+		}
+
+		// a canonical place is never merged into a later one
+		merged[i] = true
+	}
+
+	kept := make([]types.Location, 0, len(locations))
+	for i, location := range result {
+		if !aliased[i] {
+			kept = append(kept, location)
+		}
+	}
+
+	return kept, report
+}
+
+// Tokenize splits a name into lower case words without punctuation, e.g. "Trader Joe's" into "trader" and "joes"
+func Tokenize(name string) []string {
+	name = apostrophes.Replace(strings.ToLower(name))
+	return strings.Fields(nonAlphanumeric.ReplaceAllString(name, " "))
+}
+
+// Similarity compares two names by the share of the words of the shorter name found in the longer one, so
+// "CVS" and "CVS Pharmacy" are identical while "Whole Foods Market" and "Fresh Market" are 50% similar
+func Similarity(a, b string) float64 {
+	return similarity(Tokenize(a), Tokenize(b))
+}
+
+func similarity(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	words := make(map[string]struct{}, len(a))
+	for _, word := range a {
+		words[word] = struct{}{}
+	}
+
+	shared := make(map[string]struct{})
+	for _, word := range b {
+		if _, ok := words[word]; ok {
+			shared[word] = struct{}{}
+		}
+	}
+
+	return float64(len(shared)) / float64(min(distinct(a), distinct(b)))
+}
+
+func distinct(words []string) int {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+	}
+	return len(set)
+}
+
+func appendUnique(values []string, more ...string) []string {
+	for _, value := range more {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
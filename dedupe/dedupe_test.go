@@ -0,0 +1,104 @@
+package dedupe_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kardolus/maps/dedupe"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitDedupe(t *testing.T) {
+	spec.Run(t, "Dedupe Unit Tests", testDedupe, spec.Report(report.Terminal{}))
+}
+
+func testDedupe(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject *dedupe.Deduper
+		place   = func(id, name string, reviews int, lat, lng float64) types.Location {
+			l := types.Location{PlaceId: id, Name: name, FormattedAddress: "1555 W Lane Ave, Columbus, OH", UserRatingsTotal: types.Ptr(reviews)}
+			l.Geometry.Location.Lat = lat
+			l.Geometry.Location.Lng = lng
+			return l
+		}
+		pharmacy = place("a", "CVS Pharmacy", 40, 40.00630, -83.04050)
+		store    = place("b", "CVS", 900, 40.00640, -83.04060)
+		market   = place("c", "Whole Foods Market", 1200, 40.00635, -83.04055)
+		faraway  = place("d", "CVS", 300, 41.50030, -81.53830)
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		subject = dedupe.New()
+	})
+
+	it("merges nearby places with similar names into the most reviewed one", func() {
+		result, report := subject.Dedupe([]types.Location{pharmacy, market, store, faraway})
+
+		canonical := store
+		canonical.Aliases = []string{"a"}
+
+		Expect(result).To(Equal([]types.Location{market, canonical, faraway}))
+		Expect(report.Duplicates()).To(Equal(1))
+		Expect(report.Groups).To(HaveLen(1))
+		Expect(report.Groups[0].PlaceId).To(Equal("b"))
+		Expect(report.Groups[0].Merged[0].PlaceId).To(Equal("a"))
+		Expect(report.Groups[0].Merged[0].Similarity).To(Equal(1.0))
+		Expect(report.Groups[0].Merged[0].Distance).To(BeNumerically("~", 13.9, 0.5))
+	})
+
+	it("keeps the aliases of merged places", func() {
+		merged := pharmacy
+		merged.Aliases = []string{"e"}
+
+		result, _ := subject.Dedupe([]types.Location{store, merged})
+
+		Expect(result).To(HaveLen(1))
+		Expect(result[0].Aliases).To(Equal([]string{"a", "e"}))
+	})
+
+	it("does not merge places outside of the radius", func() {
+		result, report := subject.WithRadius(5).Dedupe([]types.Location{pharmacy, store})
+
+		Expect(result).To(Equal([]types.Location{pharmacy, store}))
+		Expect(report.Groups).To(BeEmpty())
+	})
+
+	it("does not merge places with names below the similarity", func() {
+		fresh := place("e", "Fresh Market", 100, 40.00635, -83.04055)
+
+		result, _ := subject.Dedupe([]types.Location{market, fresh})
+		Expect(result).To(HaveLen(2))
+
+		result, _ = subject.WithSimilarity(0.5).Dedupe([]types.Location{market, fresh})
+		Expect(result).To(HaveLen(1))
+	})
+
+	it("compares names by their words", func() {
+		for _, test := range []struct {
+			a, b     string
+			expected float64
+		}{
+			{"CVS", "CVS Pharmacy", 1},
+			{"Trader Joe's", "TRADER JOES", 1},
+			{"Whole Foods Market", "Whole Foods Market - Pharmacy", 1},
+			{"Whole Foods Market", "The Fresh Market", 1.0 / 3},
+			{"Starbucks", "Target", 0},
+			{"", "Target", 0},
+		} {
+			Expect(dedupe.Similarity(test.a, test.b)).To(BeNumerically("~", test.expected, 0.001), test.a+" vs "+test.b)
+		}
+	})
+
+	it("renders the report as text", func() {
+		_, report := subject.Dedupe([]types.Location{pharmacy, store})
+
+		var buf bytes.Buffer
+		Expect(report.WriteText(&buf)).To(Succeed())
+		Expect(buf.String()).To(Equal("CVS, 1555 W Lane Ave, Columbus, OH (b)\n" +
+			"    + CVS Pharmacy, 1555 W Lane Ave, Columbus, OH (a) 14m, 100% similar\n"))
+	})
+}
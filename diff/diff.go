@@ -0,0 +1,192 @@
+// Package diff compares two result sets of the same search to find the places that opened, closed or changed.
+package diff
+
+import (
+	"fmt"
+	"github.com/kardolus/maps/geo"
+	"github.com/kardolus/maps/types"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultRadius = 100.0 // meters
+	MatchPlaceId  = "place_id"
+	MatchNearby   = "name_and_proximity"
+)
+
+// Change is a single field that differs between the old and the new record
+type Change struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// Modified is a place present in both result sets with at least one changed field
+type Modified struct {
+	MatchedBy string         `json:"matched_by"`
+	Old       types.Location `json:"old"`
+	New       types.Location `json:"new"`
+	Changes   []Change       `json:"changes"`
+}
+
+type Report struct {
+	Added   []types.Location `json:"added"`
+	Removed []types.Location `json:"removed"`
+	Changed []Modified       `json:"changed"`
+}
+
+// Empty reports whether the result sets are the same
+func (r Report) Empty() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+type Differ struct {
+	radius float64
+}
+
+func New() *Differ {
+	return &Differ{radius: DefaultRadius}
+}
+
+// WithRadius configures how close, in meters, two places with the same name must be to be considered the same place
+// when their place ids differ. A radius of zero disables the fallback.
+func (d *Differ) WithRadius(radius float64) *Differ {
+	d.radius = radius
+	return d
+}
+
+// Compare matches the places by place id, then matches the remaining ones by name and proximity. Places without a
+// match are added or removed.
+func (d *Differ) Compare(previous, current []types.Location) Report {
+	report := Report{
+		Added:   []types.Location{},
+		Removed: []types.Location{},
+		Changed: []Modified{},
+	}
+
+	byId := make(map[string]int)
+	for i, location := range current {
+		byId[location.PlaceId] = i
+	}
+
+	matched := make([]bool, len(current))
+	var unmatched []types.Location
+
+	for _, before := range previous {
+		i, ok := byId[before.PlaceId]
+		if !ok || matched[i] {
+			unmatched = append(unmatched, before)
+			continue
+		}
+
+		matched[i] = true
+		report.add(MatchPlaceId, before, current[i])
+	}
+
+	for _, before := range unmatched {
+		best, bestDistance := -1, math.MaxFloat64
+
+		for i, after := range current {
+			if matched[i] || normalize(after.Name) != normalize(before.Name) {
+				continue
+			}
+
+			distance := geo.Between(before, after)
+			if distance <= d.radius && distance < bestDistance {
+				best, bestDistance = i, distance
+			}
+		}
+
+		if best < 0 {
+			report.Removed = append(report.Removed, before)
+			continue
+		}
+
+		matched[best] = true
+		report.add(MatchNearby, before, current[best])
+	}
+
+	for i, after := range current {
+		if !matched[i] {
+			report.Added = append(report.Added, after)
+		}
+	}
+
+	return report
+}
+
+func (r *Report) add(matchedBy string, before, after types.Location) {
+	changes := compare(before, after)
+	if len(changes) == 0 {
+		return
+	}
+
+	r.Changed = append(r.Changed, Modified{MatchedBy: matchedBy, Old: before, New: after, Changes: changes})
+}
+
+func compare(before, after types.Location) []Change {
+	var result []Change
+
+	check := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			result = append(result, Change{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	check("place_id", before.PlaceId, after.PlaceId)
+	check("business_status", string(before.BusinessStatus), string(after.BusinessStatus))
+	check("rating", formatRating(before.Rating), formatRating(after.Rating))
+	check("formatted_address", before.FormattedAddress, after.FormattedAddress)
+
+	return result
+}
+
+// WriteText renders the report for humans, one line per place and one indented line per changed field
+func (r Report) WriteText(w io.Writer) error {
+	var sb strings.Builder
+
+	for _, location := range r.Added {
+		sb.WriteString(fmt.Sprintf("+ %s\n", describe(location)))
+	}
+
+	for _, location := range r.Removed {
+		sb.WriteString(fmt.Sprintf("- %s\n", describe(location)))
+	}
+
+	for _, modified := range r.Changed {
+		sb.WriteString(fmt.Sprintf("~ %s\n", describe(modified.New)))
+		for _, change := range modified.Changes {
+			sb.WriteString(fmt.Sprintf("    %s: %s -> %s\n", change.Field, orNone(change.Old), orNone(change.New)))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("%d added, %d removed, %d changed\n", len(r.Added), len(r.Removed), len(r.Changed)))
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func describe(location types.Location) string {
+	return fmt.Sprintf("%s, %s (%s)", location.Name, location.FormattedAddress, location.PlaceId)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+func formatRating(rating *float64) string {
+	if rating == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*rating, 'f', -1, 64)
+}
+
+func normalize(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
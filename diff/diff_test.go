@@ -0,0 +1,108 @@
+package diff_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitDiff(t *testing.T) {
+	spec.Run(t, "Diff Unit Tests", testDiff, spec.Report(report.Terminal{}))
+}
+
+func testDiff(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject *diff.Differ
+		place   = func(id, name, address string, lat, lng float64) types.Location {
+			l := types.Location{PlaceId: id, Name: name, FormattedAddress: address, BusinessStatus: "OPERATIONAL", Rating: types.Ptr(4.5)}
+			l.Geometry.Location.Lat = lat
+			l.Geometry.Location.Lng = lng
+			return l
+		}
+		columbus  = place("a", "Whole Foods Market", "1555 W Lane Ave, Columbus, OH", 40.0063, -83.0405)
+		cleveland = place("b", "Whole Foods Market", "13998 Cedar Rd, Cleveland, OH", 41.5003, -81.5383)
+		dayton    = place("c", "Whole Foods Market", "1060 Miamisburg Centerville Rd, Dayton, OH", 39.6359, -84.2213)
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		subject = diff.New()
+	})
+
+	it("reports nothing for identical result sets", func() {
+		result := subject.Compare([]types.Location{columbus, cleveland}, []types.Location{cleveland, columbus})
+
+		Expect(result.Empty()).To(BeTrue())
+	})
+
+	it("reports added and removed places", func() {
+		result := subject.Compare([]types.Location{columbus, cleveland}, []types.Location{columbus, dayton})
+
+		Expect(result.Added).To(Equal([]types.Location{dayton}))
+		Expect(result.Removed).To(Equal([]types.Location{cleveland}))
+		Expect(result.Changed).To(BeEmpty())
+	})
+
+	it("reports changed fields of places with the same id", func() {
+		closed := columbus
+		closed.BusinessStatus = "CLOSED_PERMANENTLY"
+		closed.Rating = types.Ptr(4.2)
+
+		result := subject.Compare([]types.Location{columbus}, []types.Location{closed})
+
+		Expect(result.Changed).To(HaveLen(1))
+		Expect(result.Changed[0].MatchedBy).To(Equal(diff.MatchPlaceId))
+		Expect(result.Changed[0].Changes).To(Equal([]diff.Change{
+			{Field: "business_status", Old: "OPERATIONAL", New: "CLOSED_PERMANENTLY"},
+			{Field: "rating", Old: "4.5", New: "4.2"},
+		}))
+	})
+
+	it("matches places by name and proximity when the id changed", func() {
+		moved := place("a2", "Whole  foods market", "1555 West Lane Avenue, Columbus, OH", 40.0065, -83.0407)
+
+		result := subject.Compare([]types.Location{columbus}, []types.Location{moved})
+
+		Expect(result.Added).To(BeEmpty())
+		Expect(result.Removed).To(BeEmpty())
+		Expect(result.Changed).To(HaveLen(1))
+		Expect(result.Changed[0].MatchedBy).To(Equal(diff.MatchNearby))
+		Expect(result.Changed[0].Changes).To(Equal([]diff.Change{
+			{Field: "place_id", Old: "a", New: "a2"},
+			{Field: "formatted_address", Old: "1555 W Lane Ave, Columbus, OH", New: "1555 West Lane Avenue, Columbus, OH"},
+		}))
+	})
+
+	it("does not match places that are too far apart or differently named", func() {
+		far := place("a2", "Whole Foods Market", columbus.FormattedAddress, 40.0163, -83.0405)
+		renamed := place("a3", "Whole Foods Market Cafe", columbus.FormattedAddress, 40.0063, -83.0405)
+
+		result := subject.Compare([]types.Location{columbus}, []types.Location{far, renamed})
+
+		Expect(result.Removed).To(HaveLen(1))
+		Expect(result.Added).To(HaveLen(2))
+
+		result = subject.WithRadius(2000).Compare([]types.Location{columbus}, []types.Location{far})
+		Expect(result.Changed).To(HaveLen(1))
+	})
+
+	it("renders the report for humans", func() {
+		closed := columbus
+		closed.BusinessStatus = "CLOSED_PERMANENTLY"
+
+		var buf bytes.Buffer
+		Expect(subject.Compare([]types.Location{columbus, cleveland}, []types.Location{closed, dayton}).WriteText(&buf)).To(Succeed())
+
+		Expect(buf.String()).To(Equal("" +
+			"+ Whole Foods Market, 1060 Miamisburg Centerville Rd, Dayton, OH (c)\n" +
+			"- Whole Foods Market, 13998 Cedar Rd, Cleveland, OH (b)\n" +
+			"~ Whole Foods Market, 1555 W Lane Ave, Columbus, OH (a)\n" +
+			"    business_status: OPERATIONAL -> CLOSED_PERMANENTLY\n" +
+			"1 added, 1 removed, 1 changed\n"))
+	})
+}
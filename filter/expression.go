@@ -0,0 +1,213 @@
+package filter
+
+import (
+	"fmt"
+	"github.com/kardolus/maps/types"
+	"strings"
+)
+
+// SyntaxError describes a problem with an expression and the position it occurred at
+type SyntaxError struct {
+	Expr string
+	Pos  int
+	Msg  string
+}
+
+func newSyntaxError(expr string, pos int, msg string) *SyntaxError {
+	return &SyntaxError{Expr: expr, Pos: pos, Msg: msg}
+}
+
+// Error renders the message followed by the expression with a caret under the offending token
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d\n  %s\n  %s^", e.Msg, e.Pos+1, e.Expr, strings.Repeat(" ", e.Pos))
+}
+
+// Expression is a compiled, type checked filter expression
+type Expression struct {
+	source string
+	root   node
+}
+
+// Compile parses and type checks an expression such as
+// `rating >= 4.2 && user_ratings_total > 50 && "grocery_or_supermarket" in types`
+func Compile(expr string) (*Expression, error) {
+	tokens, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{expr: expr, tokens: tokens}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, newSyntaxError(expr, tok.pos, fmt.Sprintf("unexpected token %s", tok))
+	}
+
+	if root.kind() != typeBool {
+		return nil, newSyntaxError(expr, root.pos(), fmt.Sprintf("expression must be a boolean, got %s", root.kind()))
+	}
+
+	return &Expression{source: expr, root: root}, nil
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Match reports whether the location satisfies the expression. A field the location lacks is unknown, and so is every
+// comparison or negation of it, so `rating < 3` and `!(rating < 3)` both leave out a place without a rating.
+func (e *Expression) Match(location types.Location) bool {
+	return e.root.eval(location) == true
+}
+
+// Filter returns the locations that satisfy the expression
+func (e *Expression) Filter(locations []types.Location) []types.Location {
+	var result []types.Location
+
+	for _, location := range locations {
+		if e.Match(location) {
+			result = append(result, location)
+		}
+	}
+
+	return result
+}
+
+// node evaluates to a value of its kind, or to nil when it depends on a field the location lacks
+type node interface {
+	kind() valueType
+	pos() int
+	eval(location types.Location) interface{}
+}
+
+type literal struct {
+	typ   valueType
+	at    int
+	value interface{}
+}
+
+func (l *literal) kind() valueType                 { return l.typ }
+func (l *literal) pos() int                        { return l.at }
+func (l *literal) eval(types.Location) interface{} { return l.value }
+
+type fieldRef struct {
+	at    int
+	field field
+}
+
+func (f *fieldRef) kind() valueType                          { return f.field.kind }
+func (f *fieldRef) pos() int                                 { return f.at }
+func (f *fieldRef) eval(location types.Location) interface{} { return f.field.get(location) }
+
+type unary struct {
+	at      int
+	operand node
+}
+
+func (u *unary) kind() valueType { return typeBool }
+func (u *unary) pos() int        { return u.at }
+func (u *unary) eval(location types.Location) interface{} {
+	value := u.operand.eval(location)
+	if value == nil {
+		return nil
+	}
+	return !value.(bool)
+}
+
+type binary struct {
+	op          tokenKind
+	left, right node
+}
+
+func (b *binary) kind() valueType { return typeBool }
+func (b *binary) pos() int        { return b.left.pos() }
+
+func (b *binary) eval(location types.Location) interface{} {
+	switch b.op {
+	case tokenAnd:
+		return logical(b.left, b.right, location, false)
+	case tokenOr:
+		return logical(b.left, b.right, location, true)
+	case tokenIn:
+		return contains(b.right.eval(location), b.left.eval(location).(string))
+	}
+
+	left, right := b.left.eval(location), b.right.eval(location)
+	if left == nil || right == nil {
+		return nil
+	}
+
+	switch b.op {
+	case tokenEq:
+		return left == right
+	case tokenNeq:
+		return left != right
+	}
+
+	cmp := compare(left, right)
+
+	switch b.op {
+	case tokenLt:
+		return cmp < 0
+	case tokenLte:
+		return cmp <= 0
+	case tokenGt:
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// logical evaluates && when decisive is false and || when it is true: either side being decisive settles the result,
+// otherwise an unknown side makes it unknown
+func logical(left, right node, location types.Location, decisive bool) interface{} {
+	l := left.eval(location)
+	if l == decisive {
+		return decisive
+	}
+
+	r := right.eval(location)
+	if r == decisive {
+		return decisive
+	}
+
+	if l == nil || r == nil {
+		return nil
+	}
+	return !decisive
+}
+
+func compare(left, right interface{}) int {
+	if l, ok := left.(float64); ok {
+		r := right.(float64)
+		switch {
+		case l < r:
+			return -1
+		case l > r:
+			return 1
+		}
+		return 0
+	}
+
+	return strings.Compare(left.(string), right.(string))
+}
+
+// contains checks list membership, or substring containment when the haystack is a string
+func contains(haystack interface{}, needle string) bool {
+	switch h := haystack.(type) {
+	case string:
+		return strings.Contains(h, needle)
+	case []string:
+		for _, item := range h {
+			if item == needle {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package filter
+
+import (
+	"github.com/kardolus/maps/types"
+	"sort"
+)
+
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeNumber
+	typeString
+	typeList
+)
+
+func (v valueType) String() string {
+	switch v {
+	case typeBool:
+		return "boolean"
+	case typeNumber:
+		return "number"
+	case typeString:
+		return "string"
+	default:
+		return "list"
+	}
+}
+
+type field struct {
+	kind valueType
+	get  func(types.Location) interface{}
+}
+
+// fields maps the names that can be used in an expression to the location attribute they read. Names follow the
+// JSON field names of types.Location.
+var fields = map[string]field{
+	"name":               {typeString, func(l types.Location) interface{} { return l.Name }},
+	"formatted_address":  {typeString, func(l types.Location) interface{} { return l.FormattedAddress }},
+	"business_status":    {typeString, func(l types.Location) interface{} { return string(l.BusinessStatus) }},
+	"place_id":           {typeString, func(l types.Location) interface{} { return l.PlaceId }},
+	"rating":             {typeNumber, func(l types.Location) interface{} { return number(l.Rating) }},
+	"user_ratings_total": {typeNumber, func(l types.Location) interface{} { return number(l.UserRatingsTotal) }},
+	"price_level":        {typeNumber, func(l types.Location) interface{} { return number(l.PriceLevel) }},
+	"types":              {typeList, func(l types.Location) interface{} { return l.Types.Strings() }},
+	"open_now":           {typeBool, func(l types.Location) interface{} { return boolean(l.OpeningHours.OpenNow) }},
+	"lat":                {typeNumber, func(l types.Location) interface{} { return l.Geometry.Location.Lat }},
+	"lng":                {typeNumber, func(l types.Location) interface{} { return l.Geometry.Location.Lng }},
+}
+
+// number reads an optional number, nil when the Places API did not return it
+func number[T int | float64](value *T) interface{} {
+	if value == nil {
+		return nil
+	}
+	return float64(*value)
+}
+
+// boolean reads an optional boolean, nil when the Places API did not return it
+func boolean(value *bool) interface{} {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// Fields returns the names that can be used in an expression
+func Fields() []string {
+	var result []string
+	for name := range fields {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,142 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitFilter(t *testing.T) {
+	spec.Run(t, "Filter Package Unit Tests", testFilter, spec.Report(report.Terminal{}))
+}
+
+func testFilter(t *testing.T, when spec.G, it spec.S) {
+	var grocery, cafe types.Location
+
+	it.Before(func() {
+		RegisterTestingT(t)
+
+		grocery = types.Location{
+			Name:             "Whole Foods Market",
+			BusinessStatus:   "OPERATIONAL",
+			Rating:           types.Ptr(4.5),
+			UserRatingsTotal: types.Ptr(1200),
+			PriceLevel:       types.Ptr(0),
+			Types:            types.PlaceTypes{"grocery_or_supermarket", "store"},
+		}
+		grocery.OpeningHours.OpenNow = types.Ptr(true)
+
+		cafe = types.Location{
+			Name:             "Whole Foods Cafe",
+			BusinessStatus:   "CLOSED_PERMANENTLY",
+			Rating:           types.Ptr(3.9),
+			UserRatingsTotal: types.Ptr(12),
+			Types:            types.PlaceTypes{"cafe"},
+		}
+		cafe.OpeningHours.OpenNow = types.Ptr(false)
+	})
+
+	when("Compile()", func() {
+		it("evaluates comparisons, membership and boolean logic", func() {
+			tests := []struct {
+				expr    string
+				grocery bool
+				cafe    bool
+			}{
+				{`rating >= 4.2 && user_ratings_total > 50 && business_status == "OPERATIONAL" && "grocery_or_supermarket" in types`, true, false},
+				{`rating < 4`, false, true},
+				{`rating <= 4.5 and rating != 4.5`, false, true},
+				{`"Cafe" in name`, false, true},
+				{`business_status in ["OPERATIONAL", 'CLOSED_TEMPORARILY']`, true, false},
+				{`!open_now`, false, true},
+				{`not (open_now or "cafe" in types)`, false, false},
+				{`open_now == true || user_ratings_total >= 12`, true, true},
+				{`name > "Whole Foods D"`, true, false},
+				{`price_level == 0 && lat == 0 && rating > -1`, true, false},
+			}
+
+			for _, tt := range tests {
+				expression, err := filter.Compile(tt.expr)
+				Expect(err).NotTo(HaveOccurred(), tt.expr)
+
+				Expect(expression.Match(grocery)).To(Equal(tt.grocery), tt.expr)
+				Expect(expression.Match(cafe)).To(Equal(tt.cafe), tt.expr)
+			}
+		})
+
+		it("does not match places that lack a compared field", func() {
+			unknown := types.Location{Name: "Whole Foods Market"}
+
+			for _, expr := range []string{
+				`rating < 3`, `!(rating >= 3)`, `rating != 4`,
+				`user_ratings_total < 10`, `!(user_ratings_total >= 10)`,
+				`price_level == 0`, `price_level != 2`, `!(price_level > 0)`,
+				`open_now`, `!open_now`, `open_now == false`, `open_now && name == "Whole Foods Market"`,
+			} {
+				expression, err := filter.Compile(expr)
+				Expect(err).NotTo(HaveOccurred(), expr)
+				Expect(expression.Match(unknown)).To(BeFalse(), expr)
+			}
+
+			for _, expr := range []string{
+				`rating < 3 || name == "Whole Foods Market"`,
+				`!(open_now && name == "Whole Foods Cafe")`,
+			} {
+				expression, err := filter.Compile(expr)
+				Expect(err).NotTo(HaveOccurred(), expr)
+				Expect(expression.Match(unknown)).To(BeTrue(), expr)
+			}
+		})
+
+		it("filters a list of locations", func() {
+			expression, err := filter.Compile(`rating > 4`)
+			Expect(err).NotTo(HaveOccurred())
+
+			result := expression.Filter([]types.Location{grocery, cafe})
+			Expect(result).To(HaveLen(1))
+			Expect(result[0].Name).To(Equal("Whole Foods Market"))
+		})
+
+		it("points at the offending token", func() {
+			tests := []struct {
+				expr     string
+				message  string
+				position int
+			}{
+				{`rating >> 4`, `unexpected token ">"`, 8},
+				{`ratting > 4`, `unknown field "ratting"`, 0},
+				{`rating > "four"`, `cannot order number and string`, 7},
+				{`business_status == 1`, `cannot compare string with number`, 16},
+				{`types == "cafe"`, `cannot use "==" on a list`, 6},
+				{`4 in types`, `left side of "in" must be a string`, 2},
+				{`rating && open_now`, `operand of "&&" must be a boolean, got number`, 0},
+				{`rating`, `expression must be a boolean, got number`, 0},
+				{`(open_now`, `expected ")" but found end of expression`, 9},
+				{`name == "Whole`, `unterminated string`, 8},
+				{`rating > 4 #`, `unexpected character '#'`, 11},
+				{`rating >`, `unexpected end of expression`, 8},
+				{`name in ["a" "b"]`, `expected "," or "]" but found "\"b\""`, 13},
+				{`open_now open_now`, `unexpected token "open_now"`, 9},
+			}
+
+			for _, tt := range tests {
+				_, err := filter.Compile(tt.expr)
+				Expect(err).To(HaveOccurred(), tt.expr)
+
+				syntaxError, ok := err.(*filter.SyntaxError)
+				Expect(ok).To(BeTrue(), tt.expr)
+				Expect(syntaxError.Msg).To(ContainSubstring(tt.message), tt.expr)
+				Expect(syntaxError.Pos).To(Equal(tt.position), tt.expr)
+			}
+		})
+
+		it("renders a caret under the offending token", func() {
+			_, err := filter.Compile(`rating >> 4`)
+			Expect(err).To(MatchError("unexpected token \">\" at position 9\n  rating >> 4\n          ^"))
+		})
+	})
+}
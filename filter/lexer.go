@@ -0,0 +1,178 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenTrue
+	tokenFalse
+	tokenIn
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenEq
+	tokenNeq
+	tokenLt
+	tokenLte
+	tokenGt
+	tokenGte
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+	tokenComma
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	pos   int
+	value interface{}
+}
+
+// String returns the token the way it appears in the expression
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var operators = []struct {
+	text string
+	kind tokenKind
+}{
+	{"&&", tokenAnd},
+	{"||", tokenOr},
+	{"==", tokenEq},
+	{"!=", tokenNeq},
+	{"<=", tokenLte},
+	{">=", tokenGte},
+	{"<", tokenLt},
+	{">", tokenGt},
+	{"!", tokenNot},
+	{"(", tokenLParen},
+	{")", tokenRParen},
+	{"[", tokenLBracket},
+	{"]", tokenRBracket},
+	{",", tokenComma},
+}
+
+var keywords = map[string]tokenKind{
+	"true":  tokenTrue,
+	"false": tokenFalse,
+	"in":    tokenIn,
+	"and":   tokenAnd,
+	"or":    tokenOr,
+	"not":   tokenNot,
+}
+
+// lex splits the expression into tokens
+func lex(expr string) ([]token, error) {
+	var result []token
+
+	for pos := 0; pos < len(expr); {
+		c := rune(expr[pos])
+
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '"' || c == '\'':
+			tok, err := lexString(expr, pos)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, tok)
+			pos += len(tok.text)
+		case unicode.IsDigit(c) || (c == '-' && pos+1 < len(expr) && unicode.IsDigit(rune(expr[pos+1]))):
+			tok, err := lexNumber(expr, pos)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, tok)
+			pos += len(tok.text)
+		case unicode.IsLetter(c) || c == '_':
+			end := pos
+			for end < len(expr) && (unicode.IsLetter(rune(expr[end])) || unicode.IsDigit(rune(expr[end])) || expr[end] == '_' || expr[end] == '.') {
+				end++
+			}
+			text := expr[pos:end]
+			kind, ok := keywords[strings.ToLower(text)]
+			if !ok {
+				kind = tokenIdent
+			}
+			result = append(result, token{kind: kind, text: text, pos: pos})
+			pos = end
+		default:
+			tok, ok := lexOperator(expr, pos)
+			if !ok {
+				return nil, newSyntaxError(expr, pos, fmt.Sprintf("unexpected character %q", c))
+			}
+			result = append(result, tok)
+			pos += len(tok.text)
+		}
+	}
+
+	return append(result, token{kind: tokenEOF, pos: len(expr)}), nil
+}
+
+func lexOperator(expr string, pos int) (token, bool) {
+	for _, op := range operators {
+		if strings.HasPrefix(expr[pos:], op.text) {
+			return token{kind: op.kind, text: op.text, pos: pos}, true
+		}
+	}
+	return token{}, false
+}
+
+func lexString(expr string, pos int) (token, error) {
+	quote := expr[pos]
+
+	for end := pos + 1; end < len(expr); end++ {
+		switch expr[end] {
+		case '\\':
+			end++
+		case quote:
+			text := expr[pos : end+1]
+
+			body := text
+			if quote == '\'' {
+				body = `"` + strings.ReplaceAll(text[1:len(text)-1], `"`, `\"`) + `"`
+			}
+
+			value, err := strconv.Unquote(body)
+			if err != nil {
+				return token{}, newSyntaxError(expr, pos, fmt.Sprintf("invalid string %s", text))
+			}
+
+			return token{kind: tokenString, text: text, pos: pos, value: value}, nil
+		}
+	}
+
+	return token{}, newSyntaxError(expr, pos, "unterminated string")
+}
+
+func lexNumber(expr string, pos int) (token, error) {
+	end := pos + 1
+	for end < len(expr) && (unicode.IsDigit(rune(expr[end])) || expr[end] == '.') {
+		end++
+	}
+
+	text := expr[pos:end]
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return token{}, newSyntaxError(expr, pos, fmt.Sprintf("invalid number %q", text))
+	}
+
+	return token{kind: tokenNumber, text: text, pos: pos, value: value}, nil
+}
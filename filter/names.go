@@ -0,0 +1,215 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// The rules reported by Names.Match
+const (
+	RuleAll       = "all"
+	RuleContains  = "contains"
+	RuleMatches   = "matches"
+	RuleNameRegex = "name_regex"
+)
+
+// Rules describes which place names are kept. A name is kept when it satisfies any of the include rules (contains,
+// matches, name_regex) and none of the exclude rules (exclude, exclude_regex). Without include rules every name that is
+// not excluded is kept. Contains, matches and exclude are case-insensitive.
+type Rules struct {
+	Contains     []string `yaml:"contains,omitempty"`
+	Matches      []string `yaml:"matches,omitempty"`
+	Exclude      []string `yaml:"exclude,omitempty"`
+	NameRegex    []string `yaml:"name_regex,omitempty"`
+	ExcludeRegex []string `yaml:"exclude_regex,omitempty"`
+}
+
+// HasIncludes reports whether any include rules are configured
+func (r Rules) HasIncludes() bool {
+	return len(r.Contains) > 0 || len(r.Matches) > 0 || len(r.NameRegex) > 0
+}
+
+// Merge returns the union of both rule sets
+func (r Rules) Merge(other Rules) Rules {
+	return Rules{
+		Contains:     append(append([]string{}, r.Contains...), other.Contains...),
+		Matches:      append(append([]string{}, r.Matches...), other.Matches...),
+		Exclude:      append(append([]string{}, r.Exclude...), other.Exclude...),
+		NameRegex:    append(append([]string{}, r.NameRegex...), other.NameRegex...),
+		ExcludeRegex: append(append([]string{}, r.ExcludeRegex...), other.ExcludeRegex...),
+	}
+}
+
+// Compile validates the regular expressions and returns a matcher for the rules
+func (r Rules) Compile() (*Names, error) {
+	includes, err := compileAll(r.NameRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	excludes, err := compileAll(r.ExcludeRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Names{
+		contains:     normalize(r.Contains),
+		matches:      normalize(r.Matches),
+		exclude:      normalize(r.Exclude),
+		nameRegex:    includes,
+		excludeRegex: excludes,
+	}, nil
+}
+
+// Names decides whether a place name is kept. A nil *Names keeps every name.
+type Names struct {
+	contains     []string
+	matches      []string
+	exclude      []string
+	nameRegex    []*regexp.Regexp
+	excludeRegex []*regexp.Regexp
+}
+
+// NewNames creates a matcher for the contains and matches lists generated by the LLM
+func NewNames(contains, matches []string) *Names {
+	return &Names{
+		contains: normalize(contains),
+		matches:  normalize(matches),
+	}
+}
+
+// Keep reports whether the name passes the rules
+func (n *Names) Keep(name string) bool {
+	_, ok := n.Match(name)
+	return ok
+}
+
+// Match reports whether the name passes the rules and which rule kept it: RuleContains, RuleMatches or RuleNameRegex,
+// or RuleAll when there are no include rules
+func (n *Names) Match(name string) (string, bool) {
+	if n == nil {
+		return RuleAll, true
+	}
+
+	lower := strings.ToLower(name)
+
+	for _, item := range n.exclude {
+		if strings.Contains(lower, item) {
+			return "", false
+		}
+	}
+
+	for _, re := range n.excludeRegex {
+		if re.MatchString(name) {
+			return "", false
+		}
+	}
+
+	if len(n.contains) == 0 && len(n.matches) == 0 && len(n.nameRegex) == 0 {
+		return RuleAll, true
+	}
+
+	for _, item := range n.contains {
+		if strings.Contains(lower, item) {
+			return RuleContains, true
+		}
+	}
+
+	for _, item := range n.matches {
+		if lower == item {
+			return RuleMatches, true
+		}
+	}
+
+	for _, re := range n.nameRegex {
+		if re.MatchString(name) {
+			return RuleNameRegex, true
+		}
+	}
+
+	return "", false
+}
+
+// RuleFile holds name rules that apply to every query, plus rules for specific queries
+type RuleFile struct {
+	Default Rules            `yaml:"default"`
+	Queries map[string]Rules `yaml:"queries"`
+}
+
+// LoadRules reads a YAML rule file. Unknown keys are rejected so typos do not silently disable a rule.
+func LoadRules(path string) (*RuleFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result RuleFile
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&result); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid filter file %s: %w", path, err)
+	}
+
+	for query, rules := range result.Queries {
+		if _, err := rules.Compile(); err != nil {
+			return nil, fmt.Errorf("invalid filter file %s, query %q: %w", path, query, err)
+		}
+	}
+
+	if _, err := result.Default.Compile(); err != nil {
+		return nil, fmt.Errorf("invalid filter file %s, default rules: %w", path, err)
+	}
+
+	return &result, nil
+}
+
+// For returns the default rules merged with the rules of the query. Queries are matched case-insensitively.
+func (f *RuleFile) For(query string) Rules {
+	if f == nil {
+		return Rules{}
+	}
+
+	result := f.Default
+	for key, rules := range f.Queries {
+		if strings.EqualFold(strings.TrimSpace(key), strings.TrimSpace(query)) {
+			result = result.Merge(rules)
+		}
+	}
+
+	return result
+}
+
+func compileAll(patterns []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %w", pattern, err)
+		}
+		result = append(result, re)
+	}
+
+	return result, nil
+}
+
+func normalize(list []string) []string {
+	var result []string
+
+	for _, item := range list {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,171 @@
+package filter_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kardolus/maps/filter"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitNames(t *testing.T) {
+	spec.Run(t, "Name Rules Unit Tests", testNames, spec.Report(report.Terminal{}))
+}
+
+func testNames(t *testing.T, when spec.G, it spec.S) {
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	when("Keep()", func() {
+		it("keeps everything when there are no rules", func() {
+			var names *filter.Names
+			Expect(names.Keep("anything")).To(BeTrue())
+			Expect(filter.NewNames(nil, nil).Keep("anything")).To(BeTrue())
+		})
+
+		it("applies includes and excludes", func() {
+			names, err := filter.Rules{
+				Contains:     []string{"Starbucks"},
+				Matches:      []string{"sbux"},
+				Exclude:      []string{"target", " AIRPORT "},
+				NameRegex:    []string{`^Reserve Roastery`},
+				ExcludeRegex: []string{`(?i)terminal \d+`},
+			}.Compile()
+			Expect(err).NotTo(HaveOccurred())
+
+			tests := []struct {
+				name string
+				keep bool
+			}{
+				{"Starbucks", true},
+				{"STARBUCKS Coffee", true},
+				{"SBUX", true},
+				{"SBUX Express", false},
+				{"Reserve Roastery Chicago", true},
+				{"Starbucks inside Target", false},
+				{"Starbucks Airport Mall", false},
+				{"Starbucks Terminal 3", false},
+				{"Dunkin'", false},
+			}
+
+			for _, tt := range tests {
+				Expect(names.Keep(tt.name)).To(Equal(tt.keep), tt.name)
+			}
+		})
+
+		it("keeps everything that is not excluded when there are no includes", func() {
+			names, err := filter.Rules{Exclude: []string{"target"}}.Compile()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(names.Keep("Starbucks")).To(BeTrue())
+			Expect(names.Keep("Target Cafe")).To(BeFalse())
+		})
+
+		it("reports the rule that kept a name", func() {
+			names, err := filter.Rules{
+				Contains:  []string{"Starbucks"},
+				Matches:   []string{"sbux"},
+				Exclude:   []string{"target"},
+				NameRegex: []string{`^Reserve Roastery`},
+			}.Compile()
+			Expect(err).NotTo(HaveOccurred())
+
+			for name, rule := range map[string]string{
+				"Starbucks Coffee":         filter.RuleContains,
+				"SBUX":                     filter.RuleMatches,
+				"Reserve Roastery Chicago": filter.RuleNameRegex,
+				"Starbucks inside Target":  "",
+				"Dunkin'":                  "",
+			} {
+				matched, ok := names.Match(name)
+				Expect(matched).To(Equal(rule), name)
+				Expect(ok).To(Equal(rule != ""), name)
+			}
+
+			var all *filter.Names
+			matched, ok := all.Match("anything")
+			Expect(matched).To(Equal(filter.RuleAll))
+			Expect(ok).To(BeTrue())
+		})
+
+		it("rejects invalid regular expressions", func() {
+			_, err := filter.Rules{NameRegex: []string{"("}}.Compile()
+			Expect(err).To(MatchError(ContainSubstring(`invalid regular expression "("`)))
+		})
+	})
+
+	when("Merge()", func() {
+		it("combines both rule sets without modifying them", func() {
+			left := filter.Rules{Contains: []string{"a"}, Exclude: []string{"x"}}
+			right := filter.Rules{Contains: []string{"b"}, ExcludeRegex: []string{"y"}}
+
+			result := left.Merge(right)
+
+			Expect(result.Contains).To(Equal([]string{"a", "b"}))
+			Expect(result.Exclude).To(Equal([]string{"x"}))
+			Expect(result.ExcludeRegex).To(Equal([]string{"y"}))
+			Expect(left.Contains).To(Equal([]string{"a"}))
+			Expect(result.HasIncludes()).To(BeTrue())
+			Expect(filter.Rules{Exclude: []string{"x"}}.HasIncludes()).To(BeFalse())
+		})
+	})
+
+	when("LoadRules()", func() {
+		var path string
+
+		it.Before(func() {
+			path = filepath.Join(t.TempDir(), "filters.yaml")
+		})
+
+		it("merges the default rules with the rules of the query", func() {
+			content := `
+default:
+  exclude: [airport]
+queries:
+  Starbucks in USA:
+    contains: [starbucks]
+    exclude: [target]
+  Dunkin in USA:
+    contains: [dunkin]
+`
+			Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+
+			file, err := filter.LoadRules(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			rules := file.For("starbucks in usa")
+			Expect(rules.Contains).To(Equal([]string{"starbucks"}))
+			Expect(rules.Exclude).To(Equal([]string{"airport", "target"}))
+
+			rules = file.For("Peet's in USA")
+			Expect(rules.Contains).To(BeEmpty())
+			Expect(rules.Exclude).To(Equal([]string{"airport"}))
+		})
+
+		it("accepts an empty file", func() {
+			Expect(os.WriteFile(path, []byte(""), 0644)).To(Succeed())
+
+			file, err := filter.LoadRules(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file.For("anything").HasIncludes()).To(BeFalse())
+		})
+
+		it("rejects unknown keys", func() {
+			Expect(os.WriteFile(path, []byte("default:\n  exclud: [airport]\n"), 0644)).To(Succeed())
+
+			_, err := filter.LoadRules(path)
+			Expect(err).To(MatchError(ContainSubstring("field exclud not found")))
+		})
+
+		it("rejects invalid regular expressions", func() {
+			Expect(os.WriteFile(path, []byte("queries:\n  Starbucks:\n    name_regex: ['(']\n"), 0644)).To(Succeed())
+
+			_, err := filter.LoadRules(path)
+			Expect(err).To(MatchError(ContainSubstring(`query "Starbucks"`)))
+		})
+	})
+}
@@ -0,0 +1,200 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parser is a recursive descent parser with the following grammar:
+//
+//	or      = and { "||" and }
+//	and     = not { "&&" not }
+//	not     = "!" not | compare
+//	compare = primary [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" ) primary ]
+//	primary = number | string | "true" | "false" | field | list | "(" or ")"
+//	list    = "[" [ string { "," string } ] "]"
+type parser struct {
+	expr   string
+	tokens []token
+	index  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.index]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.index]
+	if tok.kind != tokenEOF {
+		p.index++
+	}
+	return tok
+}
+
+func (p *parser) errorf(tok token, format string, args ...interface{}) error {
+	return newSyntaxError(p.expr, tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (node, error) {
+	return p.parseLogical(tokenOr, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (node, error) {
+	return p.parseLogical(tokenAnd, p.parseNot)
+}
+
+func (p *parser) parseLogical(op tokenKind, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == op {
+		tok := p.next()
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, side := range []node{left, right} {
+			if side.kind() != typeBool {
+				return nil, newSyntaxError(p.expr, side.pos(), fmt.Sprintf("operand of %s must be a boolean, got %s", tok, side.kind()))
+			}
+		}
+
+		left = &binary{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.peek().kind != tokenNot {
+		return p.parseCompare()
+	}
+
+	tok := p.next()
+
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	if operand.kind() != typeBool {
+		return nil, newSyntaxError(p.expr, operand.pos(), fmt.Sprintf("operand of %s must be a boolean, got %s", tok, operand.kind()))
+	}
+
+	return &unary{at: tok.pos, operand: operand}, nil
+}
+
+func (p *parser) parseCompare() (node, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	switch tok.kind {
+	case tokenEq, tokenNeq, tokenLt, tokenLte, tokenGt, tokenGte, tokenIn:
+		p.next()
+	default:
+		return left, nil
+	}
+
+	right, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.check(tok, left, right); err != nil {
+		return nil, err
+	}
+
+	return &binary{op: tok.kind, left: left, right: right}, nil
+}
+
+// check makes sure the operand types are valid for the operator
+func (p *parser) check(op token, left, right node) error {
+	switch op.kind {
+	case tokenIn:
+		if left.kind() != typeString {
+			return p.errorf(op, "left side of %s must be a string, got %s", op, left.kind())
+		}
+		if right.kind() != typeList && right.kind() != typeString {
+			return p.errorf(op, "right side of %s must be a list or a string, got %s", op, right.kind())
+		}
+	case tokenEq, tokenNeq:
+		if left.kind() == typeList || right.kind() == typeList {
+			return p.errorf(op, "cannot use %s on a list, use in instead", op)
+		}
+		if left.kind() != right.kind() {
+			return p.errorf(op, "cannot compare %s with %s", left.kind(), right.kind())
+		}
+	default:
+		if left.kind() != right.kind() || (left.kind() != typeNumber && left.kind() != typeString) {
+			return p.errorf(op, "cannot order %s and %s", left.kind(), right.kind())
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber:
+		return &literal{typ: typeNumber, at: tok.pos, value: tok.value}, nil
+	case tokenString:
+		return &literal{typ: typeString, at: tok.pos, value: tok.value}, nil
+	case tokenTrue, tokenFalse:
+		return &literal{typ: typeBool, at: tok.pos, value: tok.kind == tokenTrue}, nil
+	case tokenIdent:
+		f, ok := fields[strings.ToLower(tok.text)]
+		if !ok {
+			return nil, p.errorf(tok, "unknown field %s, expected one of %s", tok, strings.Join(Fields(), ", "))
+		}
+		return &fieldRef{at: tok.pos, field: f}, nil
+	case tokenLBracket:
+		return p.parseList(tok)
+	case tokenLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.errorf(closing, "expected \")\" but found %s", closing)
+		}
+		return inner, nil
+	case tokenEOF:
+		return nil, p.errorf(tok, "unexpected end of expression")
+	default:
+		return nil, p.errorf(tok, "unexpected token %s", tok)
+	}
+}
+
+func (p *parser) parseList(open token) (node, error) {
+	var items []string
+
+	if p.peek().kind == tokenRBracket {
+		p.next()
+		return &literal{typ: typeList, at: open.pos, value: items}, nil
+	}
+
+	for {
+		tok := p.next()
+		if tok.kind != tokenString {
+			return nil, p.errorf(tok, "list items must be strings, found %s", tok)
+		}
+		items = append(items, tok.value.(string))
+
+		sep := p.next()
+		if sep.kind == tokenRBracket {
+			return &literal{typ: typeList, at: open.pos, value: items}, nil
+		}
+		if sep.kind != tokenComma {
+			return nil, p.errorf(sep, "expected \",\" or \"]\" but found %s", sep)
+		}
+	}
+}
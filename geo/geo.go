@@ -0,0 +1,227 @@
+// Package geo measures distances between places and answers spatial questions about result sets: which bounding box
+// covers them, whether a place lies within a box and which places are nearest to a point.
+package geo
+
+import (
+	"fmt"
+	"github.com/kardolus/maps/types"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const EarthRadius = 6371000.0 // meters
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func (p Point) String() string {
+	return fmt.Sprintf("%g,%g", p.Lat, p.Lng)
+}
+
+// PointOf returns the coordinates of the location
+func PointOf(l types.Location) Point {
+	return Point{Lat: l.Geometry.Location.Lat, Lng: l.Geometry.Location.Lng}
+}
+
+// Distance returns the great-circle distance between the points in meters
+func Distance(a, b Point) float64 {
+	dLat := toRad(b.Lat - a.Lat)
+	dLng := toRad(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * EarthRadius * math.Asin(math.Sqrt(h))
+}
+
+// Between returns the great-circle distance between the locations in meters
+func Between(a, b types.Location) float64 {
+	return Distance(PointOf(a), PointOf(b))
+}
+
+// Centroid returns the center of the points on the sphere, which stays correct for points on both sides of the
+// antimeridian. It returns false when there are no points or when they cancel out, like two antipodes.
+func Centroid(points []Point) (Point, bool) {
+	if len(points) == 1 {
+		return points[0], true
+	}
+
+	var sum vector
+	for _, p := range points {
+		v := toVector(p)
+		sum[0], sum[1], sum[2] = sum[0]+v[0], sum[1]+v[1], sum[2]+v[2]
+	}
+
+	if math.Sqrt(squaredDistance(sum, vector{})) < 1e-9 {
+		return Point{}, false
+	}
+
+	return Point{
+		Lat: toDeg(math.Atan2(sum[2], math.Hypot(sum[0], sum[1]))),
+		Lng: toDeg(math.Atan2(sum[1], sum[0])),
+	}, true
+}
+
+// ParseDistance parses a distance like 500m, 5km or 3mi into meters. A plain number is in meters.
+func ParseDistance(value string) (float64, error) {
+	s := strings.ToLower(strings.TrimSpace(value))
+
+	unit := 1.0
+	for _, suffix := range []struct {
+		name   string
+		meters float64
+	}{{"km", 1000}, {"mi", 1609.344}, {"m", 1}} {
+		if strings.HasSuffix(s, suffix.name) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, suffix.name)), suffix.meters
+			break
+		}
+	}
+
+	number, err := strconv.ParseFloat(s, 64)
+	if err != nil || number < 0 || math.IsInf(number, 0) || math.IsNaN(number) {
+		return 0, fmt.Errorf("invalid distance %q, use a positive number of meters or a number followed by m, km or mi", value)
+	}
+
+	return number * unit, nil
+}
+
+func toRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func toDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+// BBox is a bounding box in degrees. A box whose west edge is east of its east edge crosses the antimeridian.
+type BBox struct {
+	South float64 `json:"south"`
+	West  float64 `json:"west"`
+	North float64 `json:"north"`
+	East  float64 `json:"east"`
+}
+
+// ViewportOf returns the viewport of the location, the box the Places API recommends to display it in
+func ViewportOf(l types.Location) BBox {
+	viewport := l.Geometry.Viewport
+	return BBox{
+		South: viewport.Southwest.Lat,
+		West:  viewport.Southwest.Lng,
+		North: viewport.Northeast.Lat,
+		East:  viewport.Northeast.Lng,
+	}
+}
+
+// Bounds returns the smallest box around the coordinates of the locations, or false when there are none
+func Bounds(locations []types.Location) (BBox, bool) {
+	if len(locations) == 0 {
+		return BBox{}, false
+	}
+
+	result := BBox{South: 90, West: 180, North: -90, East: -180}
+	for _, location := range locations {
+		p := PointOf(location)
+		result.South = math.Min(result.South, p.Lat)
+		result.North = math.Max(result.North, p.Lat)
+		result.West = math.Min(result.West, p.Lng)
+		result.East = math.Max(result.East, p.Lng)
+	}
+
+	return result, true
+}
+
+// Contains reports whether the point lies within the box, edges included
+func (b BBox) Contains(p Point) bool {
+	if p.Lat < b.South || p.Lat > b.North {
+		return false
+	}
+
+	if b.crosses() {
+		return p.Lng >= b.West || p.Lng <= b.East
+	}
+	return p.Lng >= b.West && p.Lng <= b.East
+}
+
+// Union returns the smallest box that covers both boxes
+func (b BBox) Union(other BBox) BBox {
+	result := BBox{South: math.Min(b.South, other.South), North: math.Max(b.North, other.North)}
+
+	west, east := b.unwrap()
+	best := math.Inf(1)
+
+	for _, shift := range []float64{-360, 0, 360} {
+		otherWest, otherEast := other.unwrap()
+		w, e := math.Min(west, otherWest+shift), math.Max(east, otherEast+shift)
+
+		if e-w < best {
+			best = e - w
+			result.West, result.East = w, e
+		}
+	}
+
+	if best >= 360 {
+		result.West, result.East = -180, 180
+		return result
+	}
+
+	result.West, result.East = wrap(result.West), wrap(result.East)
+	return result
+}
+
+// Intersect returns the box both boxes cover, or false when they do not overlap. When the boxes overlap on both sides
+// of the antimeridian, the larger of the two overlaps is returned.
+func (b BBox) Intersect(other BBox) (BBox, bool) {
+	result := BBox{South: math.Max(b.South, other.South), North: math.Min(b.North, other.North)}
+	if result.South > result.North {
+		return BBox{}, false
+	}
+
+	west, east := b.unwrap()
+	best := -1.0
+
+	for _, shift := range []float64{-360, 0, 360} {
+		otherWest, otherEast := other.unwrap()
+		w, e := math.Max(west, otherWest+shift), math.Min(east, otherEast+shift)
+
+		if e >= w && e-w > best {
+			best = e - w
+			result.West, result.East = w, e
+		}
+	}
+
+	if best < 0 {
+		return BBox{}, false
+	}
+
+	if best >= 360 {
+		result.West, result.East = -180, 180
+		return result, true
+	}
+
+	result.West, result.East = wrap(result.West), wrap(result.East)
+	return result, true
+}
+
+func (b BBox) crosses() bool {
+	return b.West > b.East
+}
+
+// unwrap returns the longitudes of the box on a line, with the east edge past 180 for boxes crossing the antimeridian
+func (b BBox) unwrap() (float64, float64) {
+	if b.crosses() {
+		return b.West, b.East + 360
+	}
+	return b.West, b.East
+}
+
+// wrap maps a longitude back to [-180, 180]
+func wrap(lng float64) float64 {
+	for lng > 180 {
+		lng -= 360
+	}
+	for lng < -180 {
+		lng += 360
+	}
+	return lng
+}
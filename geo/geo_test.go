@@ -0,0 +1,226 @@
+package geo_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/kardolus/maps/geo"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitGeo(t *testing.T) {
+	spec.Run(t, "Geo Unit Tests", testGeo, spec.Report(report.Terminal{}))
+}
+
+func at(name string, lat, lng float64) types.Location {
+	location := types.Location{PlaceId: name, Name: name}
+	location.Geometry.Location.Lat = lat
+	location.Geometry.Location.Lng = lng
+	return location
+}
+
+func testGeo(t *testing.T, when spec.G, it spec.S) {
+	var (
+		columbus  = geo.Point{Lat: 39.9612, Lng: -82.9988}
+		cleveland = geo.Point{Lat: 41.4993, Lng: -81.6944}
+		ohio      = geo.BBox{South: 38.40, West: -84.82, North: 41.98, East: -80.52}
+		fiji      = geo.BBox{South: -21, West: 176, North: -12, East: -178}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	when("measuring distances", func() {
+		it("returns the great-circle distance in meters", func() {
+			Expect(geo.Distance(columbus, cleveland)).To(BeNumerically("~", 203_300, 100))
+			Expect(geo.Distance(columbus, columbus)).To(BeZero())
+		})
+
+		it("measures across the antimeridian", func() {
+			Expect(geo.Distance(geo.Point{Lat: 0, Lng: 179.9}, geo.Point{Lat: 0, Lng: -179.9})).To(BeNumerically("~", 22_239, 1))
+		})
+
+		it("measures between locations", func() {
+			Expect(geo.Between(at("a", 39.9612, -82.9988), at("b", 41.4993, -81.6944))).To(Equal(geo.Distance(columbus, cleveland)))
+		})
+	})
+
+	when("averaging points", func() {
+		it("returns the center of the points", func() {
+			center, ok := geo.Centroid([]geo.Point{{Lat: 0, Lng: 10}, {Lat: 0, Lng: 20}})
+			Expect(ok).To(BeTrue())
+			Expect(center.Lat).To(BeNumerically("~", 0, 1e-9))
+			Expect(center.Lng).To(BeNumerically("~", 15, 1e-9))
+
+			center, ok = geo.Centroid([]geo.Point{columbus})
+			Expect(ok).To(BeTrue())
+			Expect(center.Lat).To(BeNumerically("~", columbus.Lat, 1e-9))
+			Expect(center.Lng).To(BeNumerically("~", columbus.Lng, 1e-9))
+		})
+
+		it("averages across the antimeridian", func() {
+			center, ok := geo.Centroid([]geo.Point{{Lat: -18, Lng: 179}, {Lat: -18, Lng: -179}})
+			Expect(ok).To(BeTrue())
+			Expect(center.Lat).To(BeNumerically("~", -18, 0.01))
+			Expect(math.Abs(center.Lng)).To(BeNumerically("~", 180, 1e-9))
+		})
+
+		it("has no center for no points or points that cancel out", func() {
+			_, ok := geo.Centroid(nil)
+			Expect(ok).To(BeFalse())
+
+			_, ok = geo.Centroid([]geo.Point{{Lat: 0, Lng: 0}, {Lat: 0, Lng: 180}})
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	when("parsing distances", func() {
+		it("converts the units to meters", func() {
+			for value, meters := range map[string]float64{"5km": 5000, "500m": 500, "250": 250, "1.5 KM": 1500, "2mi": 3218.688} {
+				Expect(geo.ParseDistance(value)).To(Equal(meters), value)
+			}
+		})
+
+		it("rejects invalid distances", func() {
+			for _, value := range []string{"", "km", "-5km", "five km", "5ft"} {
+				_, err := geo.ParseDistance(value)
+				Expect(err).To(MatchError(ContainSubstring("invalid distance")), value)
+			}
+		})
+	})
+
+	when("working with bounding boxes", func() {
+		it("contains the points within its edges", func() {
+			Expect(ohio.Contains(columbus)).To(BeTrue())
+			Expect(ohio.Contains(geo.Point{Lat: 41.98, Lng: -80.52})).To(BeTrue())
+			Expect(ohio.Contains(geo.Point{Lat: 40.7128, Lng: -74.0060})).To(BeFalse())
+		})
+
+		it("contains points on both sides of the antimeridian", func() {
+			Expect(fiji.Contains(geo.Point{Lat: -18, Lng: 178})).To(BeTrue())
+			Expect(fiji.Contains(geo.Point{Lat: -18, Lng: -179})).To(BeTrue())
+			Expect(fiji.Contains(geo.Point{Lat: -18, Lng: 0})).To(BeFalse())
+		})
+
+		it("unites boxes", func() {
+			indiana := geo.BBox{South: 37.77, West: -88.10, North: 41.76, East: -84.78}
+			Expect(ohio.Union(indiana)).To(Equal(geo.BBox{South: 37.77, West: -88.10, North: 41.98, East: -80.52}))
+
+			samoa := geo.BBox{South: -14.5, West: -172.8, North: -13.4, East: -171.4}
+			Expect(fiji.Union(samoa)).To(Equal(geo.BBox{South: -21, West: 176, North: -12, East: -171.4}))
+		})
+
+		it("intersects boxes", func() {
+			columbusArea := geo.BBox{South: 39.8, West: -83.2, North: 40.2, East: -82.8}
+			overlap, ok := ohio.Intersect(columbusArea)
+			Expect(ok).To(BeTrue())
+			Expect(overlap).To(Equal(columbusArea))
+
+			overlap, ok = ohio.Intersect(geo.BBox{South: 41, West: -81, North: 43, East: -79})
+			Expect(ok).To(BeTrue())
+			Expect(overlap).To(Equal(geo.BBox{South: 41, West: -81, North: 41.98, East: -80.52}))
+
+			_, ok = ohio.Intersect(fiji)
+			Expect(ok).To(BeFalse())
+
+			overlap, ok = fiji.Intersect(geo.BBox{South: -20, West: -179, North: -15, East: -170})
+			Expect(ok).To(BeTrue())
+			Expect(overlap).To(Equal(geo.BBox{South: -20, West: -179, North: -15, East: -178}))
+		})
+
+		it("bounds the locations", func() {
+			_, ok := geo.Bounds(nil)
+			Expect(ok).To(BeFalse())
+
+			bounds, ok := geo.Bounds([]types.Location{at("a", 39.9612, -82.9988), at("b", 41.4993, -81.6944)})
+			Expect(ok).To(BeTrue())
+			Expect(bounds).To(Equal(geo.BBox{South: 39.9612, West: -82.9988, North: 41.4993, East: -81.6944}))
+		})
+
+		it("reads the viewport", func() {
+			location := at("a", 39.9612, -82.9988)
+			location.Geometry.Viewport.Northeast.Lat = 40
+			location.Geometry.Viewport.Northeast.Lng = -82.9
+			location.Geometry.Viewport.Southwest.Lat = 39.9
+			location.Geometry.Viewport.Southwest.Lng = -83.1
+
+			Expect(geo.ViewportOf(location)).To(Equal(geo.BBox{South: 39.9, West: -83.1, North: 40, East: -82.9}))
+		})
+	})
+
+	when("querying the index", func() {
+		var (
+			locations []types.Location
+			subject   *geo.Index
+		)
+
+		// bruteForce sorts every location by its distance to the point, which the index must agree with
+		bruteForce := func(p geo.Point) []int {
+			order := make([]int, len(locations))
+			for i := range order {
+				order[i] = i
+			}
+			sort.SliceStable(order, func(a, b int) bool {
+				return geo.Distance(p, geo.PointOf(locations[order[a]])) < geo.Distance(p, geo.PointOf(locations[order[b]]))
+			})
+			return order
+		}
+
+		indexes := func(neighbors []geo.Neighbor) []int {
+			var result []int
+			for _, neighbor := range neighbors {
+				result = append(result, neighbor.Index)
+			}
+			return result
+		}
+
+		it.Before(func() {
+			random := rand.New(rand.NewSource(42))
+
+			locations = nil
+			for i := 0; i < 500; i++ {
+				locations = append(locations, at("p", random.Float64()*180-90, random.Float64()*360-180))
+			}
+			subject = geo.NewIndex(locations)
+		})
+
+		it("finds the nearest locations", func() {
+			for _, p := range []geo.Point{columbus, {Lat: 89.9, Lng: 10}, {Lat: -5, Lng: 179.99}} {
+				Expect(indexes(subject.Nearest(p, 5))).To(Equal(bruteForce(p)[:5]))
+			}
+
+			neighbors := subject.Nearest(columbus, 1)
+			Expect(neighbors[0].Distance).To(Equal(geo.Distance(columbus, geo.PointOf(neighbors[0].Location))))
+		})
+
+		it("finds the locations within a radius", func() {
+			for _, radius := range []float64{0, 500_000, 2_000_000} {
+				var expected []int
+				for _, i := range bruteForce(columbus) {
+					if geo.Distance(columbus, geo.PointOf(locations[i])) <= radius {
+						expected = append(expected, i)
+					}
+				}
+
+				Expect(indexes(subject.Within(columbus, radius))).To(Equal(expected))
+			}
+
+			Expect(subject.Within(columbus, 30_000_000)).To(HaveLen(500))
+		})
+
+		it("handles small and empty indexes", func() {
+			Expect(geo.NewIndex(nil).Nearest(columbus, 3)).To(BeEmpty())
+
+			two := geo.NewIndex([]types.Location{at("a", 41.4993, -81.6944), at("b", 39.9612, -82.9988)})
+			neighbors := two.Nearest(columbus, 3)
+			Expect(neighbors).To(HaveLen(2))
+			Expect(neighbors[0].Location.PlaceId).To(Equal("b"))
+		})
+	})
+}
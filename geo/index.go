@@ -0,0 +1,197 @@
+package geo
+
+import (
+	"github.com/kardolus/maps/types"
+	"math"
+	"sort"
+)
+
+// Neighbor is a location found by a spatial query, with its position in the indexed slice
+type Neighbor struct {
+	Index    int            `json:"-"`
+	Location types.Location `json:"location"`
+	Distance float64        `json:"distance"` // meters
+}
+
+// Index answers nearest-neighbor and radius queries over a set of locations. It is a k-d tree over the positions of
+// the locations on the unit sphere, where the straight-line distance grows with the great-circle distance, so queries
+// work the same near the poles and across the antimeridian.
+type Index struct {
+	locations []types.Location
+	vectors   []vector
+	root      *node
+}
+
+type vector [3]float64
+
+type node struct {
+	item        int
+	axis        int
+	left, right *node
+}
+
+// candidate is an item with its squared straight-line distance to the query point
+type candidate struct {
+	item int
+	d2   float64
+}
+
+func less(a, b candidate) bool {
+	if a.d2 != b.d2 {
+		return a.d2 < b.d2
+	}
+	return a.item < b.item
+}
+
+// NewIndex indexes the locations. The index keeps the slice, so it must not be modified afterwards.
+func NewIndex(locations []types.Location) *Index {
+	index := &Index{locations: locations, vectors: make([]vector, len(locations))}
+
+	items := make([]int, len(locations))
+	for i, location := range locations {
+		items[i] = i
+		index.vectors[i] = toVector(PointOf(location))
+	}
+
+	index.root = index.build(items, 0)
+	return index
+}
+
+// Len returns the number of indexed locations
+func (x *Index) Len() int {
+	return len(x.locations)
+}
+
+// Nearest returns the k locations closest to the point, the closest first. Locations at the same distance are
+// returned in their indexed order.
+func (x *Index) Nearest(p Point, k int) []Neighbor {
+	if k <= 0 || x.root == nil {
+		return nil
+	}
+
+	target := toVector(p)
+	var best []candidate
+
+	var visit func(n *node)
+	visit = func(n *node) {
+		if n == nil {
+			return
+		}
+
+		c := candidate{item: n.item, d2: squaredDistance(target, x.vectors[n.item])}
+		if len(best) < k || less(c, best[len(best)-1]) {
+			at := sort.Search(len(best), func(i int) bool { return less(c, best[i]) })
+			best = append(best, candidate{})
+			copy(best[at+1:], best[at:])
+			best[at] = c
+			if len(best) > k {
+				best = best[:k]
+			}
+		}
+
+		diff := target[n.axis] - x.vectors[n.item][n.axis]
+		near, far := n.left, n.right
+		if diff > 0 {
+			near, far = far, near
+		}
+
+		visit(near)
+		if len(best) < k || diff*diff <= best[len(best)-1].d2 {
+			visit(far)
+		}
+	}
+	visit(x.root)
+
+	return x.neighbors(p, best)
+}
+
+// Within returns the locations at most radius meters from the point, the closest first
+func (x *Index) Within(p Point, radius float64) []Neighbor {
+	if radius < 0 || x.root == nil {
+		return nil
+	}
+
+	target := toVector(p)
+	limit := chord(radius)
+	limit = limit * limit * (1 + 1e-9) // the exact distances are checked below
+
+	var found []candidate
+
+	var visit func(n *node)
+	visit = func(n *node) {
+		if n == nil {
+			return
+		}
+
+		if d2 := squaredDistance(target, x.vectors[n.item]); d2 <= limit {
+			found = append(found, candidate{item: n.item, d2: d2})
+		}
+
+		diff := target[n.axis] - x.vectors[n.item][n.axis]
+		near, far := n.left, n.right
+		if diff > 0 {
+			near, far = far, near
+		}
+
+		visit(near)
+		if diff*diff <= limit {
+			visit(far)
+		}
+	}
+	visit(x.root)
+
+	sort.Slice(found, func(a, b int) bool { return less(found[a], found[b]) })
+
+	result := make([]Neighbor, 0, len(found))
+	for _, neighbor := range x.neighbors(p, found) {
+		if neighbor.Distance <= radius {
+			result = append(result, neighbor)
+		}
+	}
+	return result
+}
+
+func (x *Index) neighbors(p Point, candidates []candidate) []Neighbor {
+	result := make([]Neighbor, 0, len(candidates))
+	for _, c := range candidates {
+		location := x.locations[c.item]
+		result = append(result, Neighbor{Index: c.item, Location: location, Distance: Distance(p, PointOf(location))})
+	}
+	return result
+}
+
+func (x *Index) build(items []int, depth int) *node {
+	if len(items) == 0 {
+		return nil
+	}
+
+	axis := depth % 3
+	sort.Slice(items, func(a, b int) bool { return x.vectors[items[a]][axis] < x.vectors[items[b]][axis] })
+
+	mid := len(items) / 2
+	return &node{
+		item:  items[mid],
+		axis:  axis,
+		left:  x.build(items[:mid], depth+1),
+		right: x.build(items[mid+1:], depth+1),
+	}
+}
+
+func toVector(p Point) vector {
+	lat, lng := toRad(p.Lat), toRad(p.Lng)
+	return vector{math.Cos(lat) * math.Cos(lng), math.Cos(lat) * math.Sin(lng), math.Sin(lat)}
+}
+
+func squaredDistance(a, b vector) float64 {
+	dx, dy, dz := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dx*dx + dy*dy + dz*dz
+}
+
+// chord returns the straight-line distance on the unit sphere between two points radius meters apart
+func chord(radius float64) float64 {
+	angle := radius / EarthRadius
+	if angle >= math.Pi {
+		return 2
+	}
+	return 2 * math.Sin(angle/2)
+}
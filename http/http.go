@@ -25,6 +25,7 @@ type Caller interface {
 type RestCaller struct {
 	client  *http.Client
 	retries int
+	debug   io.Writer
 }
 
 // Ensure RestCaller implements Caller interface
@@ -43,15 +44,32 @@ func (r *RestCaller) WithRetries(retries int) *RestCaller {
 	return r
 }
 
+// WithDebug logs every requested URL to w
+func (r *RestCaller) WithDebug(w io.Writer) *RestCaller {
+	r.debug = w
+	return r
+}
+
 // Get performs a GET request with retry logic
 func (r *RestCaller) Get(url string) ([]byte, error) {
-	fmt.Printf("%s\n\n\n", url) // TODO create a debug setting (maybe create a "config" struct)
+	return r.call(http.MethodGet, url, nil)
+}
+
+// Post performs a POST request with a JSON body and the same retry logic as Get
+func (r *RestCaller) Post(url string, body []byte) ([]byte, error) {
+	return r.call(http.MethodPost, url, body)
+}
+
+func (r *RestCaller) call(method, url string, body []byte) ([]byte, error) {
+	if r.debug != nil {
+		fmt.Fprintf(r.debug, "%s %s\n", method, url)
+	}
 
 	var result []byte
 	var err error
 
 	for attempt := 0; attempt <= r.retries; attempt++ {
-		result, err = r.doRequest(http.MethodGet, url, nil)
+		result, err = r.doRequest(method, url, body)
 		if err == nil {
 			return result, nil // successful request, return response
 		}
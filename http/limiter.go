@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimiter spaces out the calls made through it so they are at least interval apart. It is safe to share between
+// clients.
+type RateLimiter struct {
+	next     Caller
+	interval time.Duration
+	mu       sync.Mutex
+	last     time.Time
+}
+
+// Ensure RateLimiter implements Caller interface
+var _ Caller = &RateLimiter{}
+
+// NewRateLimiter allows up to perSecond calls per second. A rate of zero or less disables the limit.
+func NewRateLimiter(next Caller, perSecond float64) *RateLimiter {
+	var interval time.Duration
+	if perSecond > 0 {
+		interval = time.Duration(float64(time.Second) / perSecond)
+	}
+
+	return &RateLimiter{next: next, interval: interval}
+}
+
+func (r *RateLimiter) Get(url string) ([]byte, error) {
+	r.wait()
+	return r.next.Get(url)
+}
+
+func (r *RateLimiter) wait() {
+	if r.interval == 0 {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if wait := r.interval - time.Since(r.last); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	r.last = time.Now()
+}
+
+// Counter counts the calls made through it
+type Counter struct {
+	next  Caller
+	count int64
+}
+
+// Ensure Counter implements Caller interface
+var _ Caller = &Counter{}
+
+func NewCounter(next Caller) *Counter {
+	return &Counter{next: next}
+}
+
+func (c *Counter) Get(url string) ([]byte, error) {
+	atomic.AddInt64(&c.count, 1)
+	return c.next.Get(url)
+}
+
+// Requests returns the number of calls made so far
+func (c *Counter) Requests() int {
+	return int(atomic.LoadInt64(&c.count))
+}
+
+// Cancelable stops making calls once its context is done, so a search can be abandoned at its next request
+type Cancelable struct {
+	ctx  context.Context
+	next Caller
+}
+
+// Ensure Cancelable implements Caller interface
+var _ Caller = &Cancelable{}
+
+func NewCancelable(ctx context.Context, next Caller) *Cancelable {
+	return &Cancelable{ctx: ctx, next: next}
+}
+
+func (c *Cancelable) Get(url string) ([]byte, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.next.Get(url)
+}
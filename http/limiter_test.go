@@ -0,0 +1,81 @@
+package http_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kardolus/maps/http"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+type stubCaller struct {
+	calls []time.Time
+}
+
+func (s *stubCaller) Get(url string) ([]byte, error) {
+	s.calls = append(s.calls, time.Now())
+	return []byte(url), nil
+}
+
+func TestUnitLimiter(t *testing.T) {
+	spec.Run(t, "Limiter Unit Tests", testLimiter, spec.Report(report.Terminal{}))
+}
+
+func testLimiter(t *testing.T, when spec.G, it spec.S) {
+	var stub *stubCaller
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		stub = &stubCaller{}
+	})
+
+	it("spaces out calls", func() {
+		subject := http.NewRateLimiter(stub, 50)
+
+		for i := 0; i < 3; i++ {
+			_, err := subject.Get("url")
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(stub.calls[2].Sub(stub.calls[0])).To(BeNumerically(">=", 40*time.Millisecond))
+	})
+
+	it("does not wait without a limit", func() {
+		subject := http.NewRateLimiter(stub, 0)
+
+		start := time.Now()
+		for i := 0; i < 10; i++ {
+			_, _ = subject.Get("url")
+		}
+
+		Expect(time.Since(start)).To(BeNumerically("<", 10*time.Millisecond))
+	})
+
+	it("counts calls", func() {
+		subject := http.NewCounter(stub)
+
+		response, err := subject.Get("url")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(response)).To(Equal("url"))
+
+		_, _ = subject.Get("url")
+		Expect(subject.Requests()).To(Equal(2))
+	})
+
+	it("stops calling once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		subject := http.NewCancelable(ctx, stub)
+
+		_, err := subject.Get("url")
+		Expect(err).NotTo(HaveOccurred())
+
+		cancel()
+
+		_, err = subject.Get("url")
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(stub.calls).To(HaveLen(1))
+	})
+}
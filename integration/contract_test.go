@@ -0,0 +1,143 @@
+package integration_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kardolus/chatgpt-cli/http"
+	"github.com/kardolus/maps/cassette"
+	"github.com/kardolus/maps/client"
+	mapshttp "github.com/kardolus/maps/http"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/types"
+	"github.com/kardolus/maps/utils"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+// The contract tests replay the cassettes in testdata/cassettes by default. Set MAPS_RECORD=1 together with
+// GOOGLE_API_KEY and OPENAI_API_KEY to run them against the real services; the cassettes are then re-recorded and the
+// test fails when the response schema drifted from the committed version.
+const (
+	recordEnv    = "MAPS_RECORD"
+	cassetteDir  = "testdata/cassettes"
+	contractArea = "Whole Foods in Iowa"
+)
+
+var businessStatuses = append([]types.BusinessStatus{""}, types.BusinessStatuses...)
+
+func TestContract(t *testing.T) {
+	spec.Run(t, "Contract Tests", testContract, spec.Report(report.Terminal{}))
+}
+
+func testContract(t *testing.T, when spec.G, it spec.S) {
+	var (
+		mode      cassette.Mode
+		placesKey string
+		recorded  *cassette.Cassette
+	)
+
+	open := func(name string) *cassette.Cassette {
+		path := filepath.Join(cassetteDir, name+".json")
+
+		subject, err := cassette.Load(path, mode)
+		Expect(err).NotTo(HaveOccurred())
+
+		recorded = subject.WithSecret(placesKey).WithSecret(os.Getenv("OPENAI_API_KEY"))
+		return recorded
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+
+		mode = cassette.ModeReplay
+		placesKey = "replay-key"
+		recorded = nil
+
+		if os.Getenv(recordEnv) != "" {
+			mode = cassette.ModeRecord
+			placesKey = os.Getenv("GOOGLE_API_KEY")
+
+			Expect(placesKey).NotTo(BeEmpty(), "GOOGLE_API_KEY is required to record")
+			Expect(os.Getenv("OPENAI_API_KEY")).NotTo(BeEmpty(), "OPENAI_API_KEY is required to record")
+		} else {
+			t.Setenv("OPENAI_API_KEY", "replay-key")
+			t.Setenv("OPENAI_URL", "https://api.openai.com")
+		}
+
+		t.Setenv("HOME", t.TempDir()) // keep the chat history away from the user's threads
+	})
+
+	it.After(func() {
+		if mode != cassette.ModeRecord || recorded == nil || t.Failed() {
+			return
+		}
+
+		committed, err := cassette.Load(filepath.Join(cassetteDir, recorded.Name+".json"), cassette.ModeReplay)
+		Expect(recorded.Save()).To(Succeed())
+
+		if err != nil {
+			return // a brand new cassette has nothing to drift from
+		}
+
+		drift, err := cassette.Drift(committed, recorded)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift).To(BeEmpty(), "the response schema drifted from the committed cassette:\n"+strings.Join(drift, "\n"))
+	})
+
+	when("searching the Places API", func() {
+		it("returns the fields the client relies on", func() {
+			c := open("places_textsearch")
+
+			timeout := 0
+			if mode == cassette.ModeRecord {
+				timeout = 2000
+			}
+
+			subject := client.New(c.Places(mapshttp.New()), placesKey).WithTimeout(timeout)
+
+			result, stats, err := subject.FetchFiltered(contractArea, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).NotTo(BeEmpty())
+			Expect(stats.Results).To(Equal(len(result)))
+
+			for _, location := range result {
+				Expect(location.PlaceId).NotTo(BeEmpty())
+				Expect(location.Name).NotTo(BeEmpty())
+				Expect(location.FormattedAddress).NotTo(BeEmpty())
+				Expect(location.Geometry.Location.Lat).NotTo(BeZero())
+				Expect(location.Geometry.Location.Lng).NotTo(BeZero())
+				Expect(location.Types).NotTo(BeEmpty())
+				Expect(businessStatuses).To(ContainElement(location.BusinessStatus))
+			}
+		})
+	})
+
+	when("prompting the LLM", func() {
+		it("breaks a query down and generates a name filter", func() {
+			c := open("llm_prompts")
+
+			chatClient, err := llm.NewChatGPTClientWithCaller(c.LLM(http.RealCallerFactory))
+			Expect(err).NotTo(HaveOccurred())
+
+			subject := llm.New(chatClient, utils.New())
+
+			queries, err := subject.GenerateSubQueries(contractArea)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(queries).NotTo(BeEmpty())
+			for _, query := range queries {
+				Expect(strings.ToLower(query)).To(ContainSubstring("whole foods"))
+			}
+
+			Expect(subject.ClearHistory()).To(Succeed())
+
+			contains, _, err := subject.GenerateFilter(contractArea)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contains).NotTo(BeEmpty())
+		})
+	})
+}
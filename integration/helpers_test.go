@@ -0,0 +1,207 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	promptMarker     = "PROMPT:"
+	inputMarker      = "input query: "
+	queryPlaceholder = "{query}"
+)
+
+// fakeLLM serves the OpenAI chat completions endpoint. The reply is chosen by the most recent prompt marker in the
+// conversation, so tests can use prompt overrides such as "PROMPT:breakdown" to drive the CLI. A "{query}" in a reply
+// is replaced by the most recent input query.
+type fakeLLM struct {
+	mu       sync.Mutex
+	server   *httptest.Server
+	replies  map[string]string
+	requests int
+	inputs   [][]string
+}
+
+func newFakeLLM(replies map[string]string) *fakeLLM {
+	f := &fakeLLM{replies: replies}
+	f.server = httptest.NewServer(http.HandlerFunc(f.handle))
+	return f
+}
+
+func (f *fakeLLM) URL() string {
+	return f.server.URL
+}
+
+func (f *fakeLLM) Close() {
+	f.server.Close()
+}
+
+func (f *fakeLLM) Requests() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests
+}
+
+// Inputs returns the input queries of every request, in the order they were received
+func (f *fakeLLM) Inputs() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string{}, f.inputs...)
+}
+
+func (f *fakeLLM) handle(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Messages []struct {
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		prompt, query string
+		inputs        []string
+	)
+	for _, message := range request.Messages {
+		if i := strings.LastIndex(message.Content, promptMarker); i >= 0 {
+			prompt = strings.Fields(message.Content[i+len(promptMarker):])[0]
+		}
+		if i := strings.LastIndex(message.Content, inputMarker); i >= 0 {
+			query = strings.SplitN(message.Content[i+len(inputMarker):], "\n", 2)[0]
+			inputs = append(inputs, query)
+		}
+	}
+
+	f.mu.Lock()
+	f.requests++
+	f.inputs = append(f.inputs, inputs)
+	f.mu.Unlock()
+
+	reply, ok := f.replies[prompt]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no reply configured for prompt %q", prompt), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"choices": []map[string]interface{}{
+			{"message": map[string]string{"role": "assistant", "content": strings.ReplaceAll(reply, queryPlaceholder, query)}},
+		},
+		"usage": map[string]int{"total_tokens": 1},
+	})
+}
+
+var (
+	buildOnce sync.Once
+	binary    string
+	buildErr  error
+)
+
+// buildCLI compiles cmd/maps once per test run
+func buildCLI() (string, error) {
+	buildOnce.Do(func() {
+		dir, err := os.MkdirTemp("", "maps-integration")
+		if err != nil {
+			buildErr = err
+			return
+		}
+
+		binary = filepath.Join(dir, "maps")
+
+		cmd := exec.Command("go", "build", "-mod=vendor", "-o", binary, "../cmd/maps")
+		cmd.Env = append(os.Environ(), "GOFLAGS=")
+
+		if output, err := cmd.CombinedOutput(); err != nil {
+			buildErr = fmt.Errorf("failed to build cli: %w\n%s", err, output)
+		}
+	})
+
+	return binary, buildErr
+}
+
+// runCLI runs the binary in an isolated home directory
+func runCLI(home string, env []string, args ...string) (string, error) {
+	cmd, err := command(home, env, args...)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// pipeCLI runs the CLI with the given stdin and keeps stdout and stderr apart
+func pipeCLI(home string, env []string, stdin string, args ...string) (string, string, error) {
+	cmd, err := command(home, env, args...)
+	if err != nil {
+		return "", "", err
+	}
+
+	var stdout, stderr strings.Builder
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func command(home string, env []string, args ...string) (*exec.Cmd, error) {
+	path, err := buildCLI()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(path, args...)
+	cmd.Dir = home
+	cmd.Env = append([]string{"HOME=" + home, "PATH=" + os.Getenv("PATH")}, env...)
+
+	return cmd, nil
+}
+
+// startCLI starts the CLI in the background, e.g. a server, and stops it when the test ends
+func startCLI(t *testing.T, home string, env []string, args ...string) (*strings.Builder, error) {
+	cmd, err := command(home, env, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var output strings.Builder
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	t.Cleanup(func() {
+		_ = cmd.Process.Signal(os.Interrupt)
+		_ = cmd.Wait()
+	})
+
+	return &output, nil
+}
+
+// freeAddr returns a local address no one listens on
+func freeAddr() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+
+	return listener.Addr().String(), nil
+}
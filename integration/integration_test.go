@@ -0,0 +1,750 @@
+package integration_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	nethttp "net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/cluster"
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/http"
+	"github.com/kardolus/maps/mcp"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/placesfake"
+	"github.com/kardolus/maps/server"
+	"github.com/kardolus/maps/stats"
+	"github.com/kardolus/maps/types"
+	"github.com/kardolus/maps/watch"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+const apiKey = "integration-key"
+
+func TestIntegration(t *testing.T) {
+	spec.Run(t, "Integration Tests", testIntegration, spec.Report(report.Terminal{}))
+}
+
+func testIntegration(t *testing.T, when spec.G, it spec.S) {
+	var fake *placesfake.Server
+
+	it.Before(func() {
+		RegisterTestingT(t)
+
+		fake = placesfake.New().WithAPIKey(apiKey)
+		Expect(fake.LoadFixtures("testdata/places")).To(Succeed())
+		fake.Start()
+	})
+
+	it.After(func() {
+		fake.Close()
+	})
+
+	when("the client talks to the fake Places API", func() {
+		var subject *client.Client
+
+		it.Before(func() {
+			subject = client.New(http.New(), apiKey).WithTimeout(20).WithBaseURL(fake.URL())
+		})
+
+		it("paginates through every page and waits for the token to become valid", func() {
+			result, stats, err := subject.FetchFiltered("Whole Foods in Ohio", nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(46))
+			Expect(stats.Pages).To(Equal(3))
+			Expect(stats.Results).To(Equal(46))
+
+			var invalid int
+			for _, request := range fake.Requests() {
+				if strings.Contains(request, "pagetoken") {
+					invalid++
+				}
+			}
+			Expect(invalid).To(BeNumerically(">", 2)) // at least one token was used before it was ready
+		})
+
+		it("applies name rules to every page", func() {
+			names, err := filter.Rules{Contains: []string{"whole foods"}, Exclude: []string{"cafe"}}.Compile()
+			Expect(err).NotTo(HaveOccurred())
+
+			result, stats, err := subject.FetchFiltered("whole foods in  ohio", names)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(HaveLen(36))
+			Expect(stats.Kept).To(Equal(36))
+		})
+
+		it("returns nothing for an unknown query", func() {
+			result, err := subject.FetchLocations("Whole Foods in Alaska", nil, nil)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(BeEmpty())
+		})
+
+		it("surfaces error statuses", func() {
+			_, err := subject.FetchLocations("Whole Foods in Texas", nil, nil)
+
+			Expect(err).To(HaveOccurred())
+			Expect(client.IsQuotaError(err)).To(BeTrue())
+		})
+
+		it("reports a rejected API key", func() {
+			_, err := client.New(http.New(), "wrong").WithBaseURL(fake.URL()).FetchLocations("Whole Foods in Iowa", nil, nil)
+
+			Expect(err).To(MatchError(ContainSubstring("REQUEST_DENIED")))
+		})
+
+		it("finds places nearby and looks them up by id", func() {
+			ohio, _, err := subject.FetchFiltered("Whole Foods in Ohio", nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			center := ohio[0]
+			query := client.NearbyQuery{Lat: center.Geometry.Location.Lat, Lng: center.Geometry.Location.Lng, Radius: 1000}
+
+			result, _, err := subject.Nearby(query, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(ContainElement(HaveField("PlaceId", center.PlaceId)))
+
+			details, err := subject.Details(center.PlaceId)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(details.Name).To(Equal(center.Name))
+
+			_, err = subject.Details("unknown")
+			Expect(err).To(MatchError(ContainSubstring("NOT_FOUND")))
+		})
+
+		it("runs out of quota", func() {
+			fake.WithQuota(apiKey, 1)
+
+			_, err := subject.FetchLocations("Whole Foods in Iowa", nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = subject.FetchLocations("Whole Foods in Iowa", nil, nil)
+			Expect(client.IsQuotaError(err)).To(BeTrue())
+		})
+	})
+
+	when("the CLI runs end-to-end", func() {
+		var (
+			llm     *fakeLLM
+			home    string
+			prompts string
+			env     []string
+		)
+
+		it.Before(func() {
+			llm = newFakeLLM(map[string]string{
+				"breakdown": "search [1]: Whole Foods in Ohio\nsearch [2]: Whole Foods in Iowa",
+				"filter":    "contains: whole foods market\nmatches: whole foods",
+			})
+
+			home = t.TempDir()
+
+			fake.WithTokenDelay(0)
+
+			var err error
+			prompts, err = filepath.Abs("testdata/prompts")
+			Expect(err).NotTo(HaveOccurred())
+
+			env = []string{
+				"GOOGLE_API_KEY=" + apiKey,
+				"MAPS_PLACES_URL=" + fake.URL(),
+				"OPENAI_API_KEY=fake",
+				"OPENAI_URL=" + llm.URL(),
+				"MAPS_PAGE_DELAY=0",
+			}
+		})
+
+		it.After(func() {
+			llm.Close()
+		})
+
+		it("plans, fetches, filters and writes the results", func() {
+			output := filepath.Join(home, "results.json")
+
+			stdout, err := runCLI(home, env, "--query", "Whole Foods in USA", "--prompt-dir", prompts, "--where", "rating >= 4", "--output", output)
+			Expect(err).NotTo(HaveOccurred(), stdout)
+			Expect(stdout).To(ContainSubstring("Whole Foods in Ohio (46 results, 3 pages, 36 kept)"))
+
+			data, err := os.ReadFile(output)
+			Expect(err).NotTo(HaveOccurred())
+
+			var locations []types.Location
+			Expect(json.Unmarshal(data, &locations)).To(Succeed())
+			Expect(locations).To(HaveLen(37))
+
+			for _, location := range locations {
+				Expect(location.Name).To(Equal("Whole Foods Market"))
+				Expect(types.Value(location.Rating)).To(BeNumerically(">=", 4))
+			}
+
+			Expect(llm.Requests()).To(Equal(2))
+		})
+
+		it("reads the query from stdin and streams ndjson to stdout", func() {
+			stdout, stderr, err := pipeCLI(home, env, "Whole Foods in USA\n", "-", "--prompt-dir", prompts, "--format", "ndjson")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stderr).To(ContainSubstring("Fetching locations for query: Whole Foods in USA"))
+			Expect(stderr).To(ContainSubstring("Planning tree:"))
+
+			lines := strings.Split(strings.TrimSpace(stdout), "\n")
+			Expect(len(lines)).To(BeNumerically(">", 36))
+
+			for _, line := range lines {
+				var location types.Location
+				Expect(json.Unmarshal([]byte(line), &location)).To(Succeed(), line)
+				Expect(location.PlaceId).NotTo(BeEmpty())
+			}
+		})
+
+		it("profiles the results at the end of the run and from the file", func() {
+			output := filepath.Join(home, "results.json")
+
+			_, stderr, err := pipeCLI(home, env, "", "Whole Foods in USA", "--prompt-dir", prompts, "--output", output, "--stats")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stderr).To(ContainSubstring("Places: 38\n"))
+			Expect(stderr).To(ContainSubstring("Open now: "))
+
+			stdout, stderr, err := pipeCLI(home, nil, "", "stats", output, "--json")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			var report stats.Report
+			Expect(json.Unmarshal([]byte(stdout), &report)).To(Succeed())
+			Expect(report.Places).To(Equal(38))
+			Expect(report.Ratings.Rated + report.Ratings.Unrated).To(Equal(38))
+
+			_, stderr, err = pipeCLI(home, env, "", "Whole Foods in USA", "--prompt-dir", prompts, "--stats=yaml")
+			Expect(err).To(HaveOccurred())
+			Expect(stderr).To(ContainSubstring("invalid stats format"))
+		})
+
+		it("reports the coverage of the sub-queries", func() {
+			expected := filepath.Join(home, "expected.csv")
+			Expect(os.WriteFile(expected, []byte("region,expected\nOhio,40\nIowa,2\n"), 0644)).To(Succeed())
+
+			_, stderr, err := pipeCLI(home, env, "", "Whole Foods in USA", "--prompt-dir", prompts, "--format", "ndjson",
+				"--coverage", "--coverage-expected", expected)
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stderr).To(ContainSubstring("Coverage of Whole Foods in USA: 2 sub-queries"))
+			Expect(stderr).To(ContainSubstring("  Whole Foods in Ohio (46 results, 3 pages, 36 kept)\n"))
+			Expect(stderr).To(ContainSubstring("  Ohio: 36 of 40 found, 4 missing\n"))
+			Expect(stderr).To(ContainSubstring("  Iowa: 2 of 2 found\n"))
+
+			_, stderr, err = pipeCLI(home, env, "", "Whole Foods in USA", "--prompt-dir", prompts, "--coverage-expected", expected)
+			Expect(err).To(HaveOccurred())
+			Expect(stderr).To(ContainSubstring("--coverage-expected requires --coverage"))
+		})
+
+		it("splits the addresses of the results", func() {
+			stdout, stderr, err := pipeCLI(home, env, "", "Whole Foods in USA", "--prompt-dir", prompts, "--parse-address", "--format", "csv")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			rows, err := output.DecodeCSV([]byte(stdout))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rows).NotTo(BeEmpty())
+
+			for _, row := range rows {
+				Expect(row.Location.Address).NotTo(BeNil(), row.Location.FormattedAddress)
+				Expect(row.Location.Address.Region).To(Or(Equal("OH"), Equal("IA")), row.Location.FormattedAddress)
+				Expect(row.Location.Address.City).NotTo(BeEmpty(), row.Location.FormattedAddress)
+			}
+		})
+
+		it("records the provenance of the results when asked to", func() {
+			stdout, stderr, err := pipeCLI(home, env, "", "Whole Foods in USA", "--prompt-dir", prompts)
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stdout).NotTo(ContainSubstring("provenance"))
+
+			stdout, stderr, err = pipeCLI(home, env, "", "Whole Foods in USA", "--prompt-dir", prompts, "--with-provenance")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			rows, err := output.Decode([]byte(stdout))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rows).NotTo(BeEmpty())
+
+			for _, row := range rows {
+				p := row.Location.Provenance
+				Expect(p).NotTo(BeNil(), row.Location.Name)
+				Expect(p.Query).To(HavePrefix("Whole Foods in "))
+				Expect(p.Page).To(BeNumerically(">=", 1))
+				Expect(p.FetchedAt).NotTo(BeZero())
+				Expect(p.Provider).To(Equal("google_places"))
+				Expect(p.Rule).To(Or(Equal("contains"), Equal("matches")))
+			}
+		})
+
+		it("spreads the requests over several API keys", func() {
+			stdout, stderr, err := pipeCLI(home, append(env, "GOOGLE_API_KEYS=revoked-key-0000"), "", "Whole Foods in USA", "--prompt-dir", prompts)
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			var locations []types.Location
+			Expect(json.Unmarshal([]byte(stdout), &locations)).To(Succeed())
+			Expect(locations).To(HaveLen(38))
+
+			Expect(stderr).To(ContainSubstring("API key usage:\n"))
+			Expect(stderr).To(MatchRegexp(`  \.\.\.-key: \d+ requests\n`))
+			Expect(stderr).To(ContainSubstring("  ...0000: 1 requests, 1 failed (REQUEST_DENIED)\n"))
+			Expect(fake.Usage("revoked-key-0000")).To(Equal(1))
+		})
+
+		it("reads the settings and profiles of the config file", func() {
+			_, stderr, err := pipeCLI(home, env, "", "config", "init")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			path := filepath.Join(home, ".config", "maps", "config.yaml")
+			Expect(stderr).To(ContainSubstring("Wrote " + path))
+
+			_, stderr, err = pipeCLI(home, env, "", "config", "init")
+			Expect(err).To(HaveOccurred())
+			Expect(stderr).To(ContainSubstring("already exists"))
+
+			Expect(os.WriteFile(path, []byte("prompt-dir: "+prompts+"\nprofiles:\n  audit:\n    api-key: "+apiKey+"\n    with-provenance: true\n"), 0600)).To(Succeed())
+
+			stdout, stderr, err := pipeCLI(home, env[1:], "", "config", "validate")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stdout).To(ContainSubstring(path + " is valid, profiles: audit"))
+
+			stdout, stderr, err = pipeCLI(home, env[1:], "", "Whole Foods in USA", "--profile", "audit")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			rows, err := output.Decode([]byte(stdout))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rows).To(HaveLen(38))
+			Expect(rows[0].Location.Provenance).NotTo(BeNil())
+
+			stdout, stderr, err = pipeCLI(home, env, "", "config", "show", "--profile", "audit")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stdout).To(ContainSubstring("# config file: " + path))
+			Expect(stdout).To(ContainSubstring("with-provenance: true"))
+			Expect(stdout).NotTo(ContainSubstring(apiKey))
+
+			_, stderr, err = pipeCLI(home, env, "", "Whole Foods in USA", "--profile", "eu")
+			Expect(err).To(HaveOccurred())
+			Expect(stderr).To(ContainSubstring(`unknown profile "eu", use one of audit`))
+
+			other := filepath.Join(home, "other.yaml")
+			Expect(os.WriteFile(other, []byte("max-result: 20\nwatch:\n  every: daily\n"), 0600)).To(Succeed())
+
+			_, stderr, err = pipeCLI(home, append(env, "MAPS_CONFIG="+other), "", "Whole Foods in USA", "--prompt-dir", prompts)
+			Expect(err).To(HaveOccurred())
+			Expect(stderr).To(ContainSubstring("invalid config file " + other + ":\n" +
+				"  line 1: max-result: unknown key, did you mean max-results?\n" +
+				"  line 3: watch.every: expected a duration, e.g. 90s or 24h, got \"daily\""))
+			Expect(llm.Requests()).To(Equal(2))
+		})
+
+		it("requires a query", func() {
+			_, stderr, err := pipeCLI(home, env, "", "--prompt-dir", prompts)
+			Expect(err).To(HaveOccurred())
+			Expect(stderr).To(ContainSubstring("missing query"))
+			Expect(fake.Requests()).To(BeEmpty())
+			Expect(llm.Requests()).To(BeZero())
+		})
+
+		it("runs a batch of queries and lists the ones that failed", func() {
+			llm.Close()
+			llm = newFakeLLM(map[string]string{
+				"breakdown": "search [1]: {query}",
+				"filter":    "contains: whole foods market\nmatches: whole foods",
+			})
+			env[3] = "OPENAI_URL=" + llm.URL()
+
+			input := filepath.Join(home, "queries.txt")
+			Expect(os.WriteFile(input, []byte("Whole Foods in Ohio\nWhole Foods in Iowa\nWhole Foods in Texas\n"), 0644)).To(Succeed())
+
+			output := filepath.Join(home, "results.csv")
+			failed := filepath.Join(home, "failed.txt")
+
+			stdout, err := runCLI(home, env, "batch", "--input", input, "--prompt-dir", prompts, "--output", output, "--failed", failed)
+			Expect(err).To(HaveOccurred(), stdout)
+			Expect(stdout).To(ContainSubstring("1 of 3 queries failed"))
+			Expect(stdout).To(MatchRegexp(`Whole Foods in Ohio\s+36\s+3\s+1\s+ok`))
+			Expect(stdout).To(ContainSubstring("TOTAL (1 failed)"))
+
+			data, err := os.ReadFile(failed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("Whole Foods in Texas\n"))
+
+			data, err = os.ReadFile(output)
+			Expect(err).NotTo(HaveOccurred())
+
+			records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0][0]).To(Equal("query"))
+
+			perQuery := make(map[string]int)
+			for _, record := range records[1:] {
+				perQuery[record[0]]++
+			}
+			Expect(perQuery).To(HaveKeyWithValue("Whole Foods in Ohio", 36))
+			Expect(perQuery).To(HaveKey("Whole Foods in Iowa"))
+			Expect(perQuery).NotTo(HaveKey("Whole Foods in Texas"))
+		})
+
+		it("watches a search and reports the changes since the last run", func() {
+			store := filepath.Join(home, "snapshot.json")
+			args := []string{"watch", "Whole Foods in USA", "--prompt-dir", prompts, "--store", store, "--once"}
+
+			stdout, stderr, err := pipeCLI(home, env, "", args...)
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stdout).To(BeEmpty())
+			Expect(stderr).To(ContainSubstring("Saved the first snapshot with"))
+
+			var snapshot watch.Snapshot
+			data, err := os.ReadFile(store)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(data, &snapshot)).To(Succeed())
+
+			added := snapshot.Locations[0]
+			changed := snapshot.Locations[1]
+			snapshot.Locations = snapshot.Locations[1:]
+			snapshot.Locations[0].BusinessStatus = "CLOSED_TEMPORARILY"
+
+			data, err = json.Marshal(snapshot)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(os.WriteFile(store, data, 0644)).To(Succeed())
+
+			stdout, stderr, err = pipeCLI(home, env, "", args...)
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			lines := strings.Split(strings.TrimSpace(stdout), "\n")
+			Expect(lines).To(HaveLen(2))
+
+			var events []watch.Event
+			for _, line := range lines {
+				var event watch.Event
+				Expect(json.Unmarshal([]byte(line), &event)).To(Succeed(), line)
+				events = append(events, event)
+			}
+
+			Expect(events[0].Type).To(Equal(watch.EventNewPlace))
+			Expect(events[0].Place.PlaceId).To(Equal(added.PlaceId))
+			Expect(events[1].Type).To(Equal(watch.EventStatusChange))
+			Expect(events[1].Place.PlaceId).To(Equal(changed.PlaceId))
+			Expect(events[1].OldStatus).To(Equal("CLOSED_TEMPORARILY"))
+		})
+
+		it("serves searches as jobs over HTTP", func() {
+			addr, err := freeAddr()
+			Expect(err).NotTo(HaveOccurred())
+
+			logs, err := startCLI(t, home, env, "serve", "--addr", addr, "--prompt-dir", prompts, "--concurrency", "1")
+			Expect(err).NotTo(HaveOccurred())
+
+			base := "http://" + addr
+			Eventually(func() error {
+				_, err := nethttp.Get(base + "/jobs")
+				return err
+			}, 10*time.Second, 50*time.Millisecond).Should(Succeed(), func() string { return logs.String() })
+
+			response, err := nethttp.Post(base+"/jobs", "application/json", strings.NewReader(`{"query": "Whole Foods in USA", "where": "rating >= 4"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(nethttp.StatusAccepted))
+
+			var job server.Job
+			Expect(json.NewDecoder(response.Body).Decode(&job)).To(Succeed())
+			response.Body.Close()
+
+			Eventually(func() string {
+				response, err := nethttp.Get(base + "/jobs/" + job.ID)
+				Expect(err).NotTo(HaveOccurred())
+				defer response.Body.Close()
+
+				Expect(json.NewDecoder(response.Body).Decode(&job)).To(Succeed())
+				return job.Status
+			}, 30*time.Second, 100*time.Millisecond).Should(Equal(server.StatusSucceeded), func() string { return logs.String() })
+
+			Expect(job.Results).To(Equal(37))
+
+			response, err = nethttp.Get(base + "/jobs/" + job.ID + "/results?format=ndjson")
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+
+			data, err := io.ReadAll(response.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Split(strings.TrimSpace(string(data)), "\n")).To(HaveLen(37))
+		})
+
+		it("keeps the LLM conversations of concurrent jobs apart", func() {
+			// the ChatGPT history is only written when its directory exists
+			Expect(os.MkdirAll(filepath.Join(home, ".chatgpt-cli", "history"), 0755)).To(Succeed())
+
+			addr, err := freeAddr()
+			Expect(err).NotTo(HaveOccurred())
+
+			logs, err := startCLI(t, home, env, "serve", "--addr", addr, "--prompt-dir", prompts, "--concurrency", "2")
+			Expect(err).NotTo(HaveOccurred())
+
+			base := "http://" + addr
+			Eventually(func() error {
+				_, err := nethttp.Get(base + "/jobs")
+				return err
+			}, 10*time.Second, 50*time.Millisecond).Should(Succeed(), func() string { return logs.String() })
+
+			for _, query := range []string{"Whole Foods in USA", "Trader Joe's in USA"} {
+				response, err := nethttp.Post(base+"/jobs", "application/json", strings.NewReader(`{"query": "`+query+`"}`))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(response.StatusCode).To(Equal(nethttp.StatusAccepted))
+				response.Body.Close()
+			}
+
+			Eventually(func() []string {
+				response, err := nethttp.Get(base + "/jobs")
+				Expect(err).NotTo(HaveOccurred())
+				defer response.Body.Close()
+
+				var jobs []server.Job
+				Expect(json.NewDecoder(response.Body).Decode(&jobs)).To(Succeed())
+
+				var statuses []string
+				for _, job := range jobs {
+					statuses = append(statuses, job.Status)
+				}
+				return statuses
+			}, 60*time.Second, 100*time.Millisecond).Should(Equal([]string{server.StatusSucceeded, server.StatusSucceeded}), func() string { return logs.String() })
+
+			Expect(llm.Inputs()).To(HaveLen(4))
+			for _, inputs := range llm.Inputs() {
+				Expect(inputs).To(HaveLen(1))
+			}
+		})
+
+		it("answers tool calls over the Model Context Protocol", func() {
+			requests := strings.Join([]string{
+				`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2025-06-18"}}`,
+				`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+				`{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "search_places", "arguments": {"query": "Whole Foods in USA", "where": "rating >= 4"}}}`,
+			}, "\n")
+
+			stdout, stderr, err := pipeCLI(home, env, requests, "mcp", "--prompt-dir", prompts)
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			lines := strings.Split(strings.TrimSpace(stdout), "\n")
+			Expect(lines).To(HaveLen(2), stdout)
+
+			var reply struct {
+				Result struct {
+					IsError           bool          `json:"isError"`
+					StructuredContent mcp.PlaceList `json:"structuredContent"`
+				} `json:"result"`
+			}
+			Expect(json.Unmarshal([]byte(lines[1]), &reply)).To(Succeed(), lines[1])
+			Expect(reply.Result.IsError).To(BeFalse(), lines[1])
+			Expect(reply.Result.StructuredContent.Count).To(Equal(37))
+		})
+
+		it("fails when the Places API rejects the key", func() {
+			env[0] = "GOOGLE_API_KEY=wrong"
+
+			stdout, err := runCLI(home, env, "--query", "Whole Foods in USA", "--prompt-dir", prompts)
+			Expect(err).To(HaveOccurred())
+			Expect(stdout).To(ContainSubstring("REQUEST_DENIED"))
+		})
+	})
+
+	when("result files are merged", func() {
+		it("writes one record per place with the files it was found in", func() {
+			home := t.TempDir()
+			ohio := filepath.Join(home, "ohio.json")
+			usa := filepath.Join(home, "usa.csv")
+			merged := filepath.Join(home, "all.ndjson")
+
+			Expect(os.WriteFile(ohio, []byte(`[
+				{"query": "Whole Foods in Ohio", "place_id": "a", "name": "Whole Foods Market", "formatted_address": "Columbus"},
+				{"query": "Whole Foods in Ohio", "place_id": "b", "name": "Whole Foods Market", "formatted_address": "Cleveland"}
+			]`), 0644)).To(Succeed())
+			Expect(os.WriteFile(usa, []byte("query,name,formatted_address,place_id\n"+
+				"Whole Foods in USA,Whole Foods Market,Columbus,a\n"+
+				"Whole Foods in USA,Whole Foods Market,Austin,c\n"), 0644)).To(Succeed())
+
+			stdout, err := runCLI(home, nil, "merge", ohio, usa, "--output", merged)
+			Expect(err).NotTo(HaveOccurred(), stdout)
+			Expect(stdout).To(ContainSubstring("Merged 4 records from 2 files into 3 places"))
+
+			rows, err := output.ReadFile(merged)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rows).To(HaveLen(3))
+			Expect(rows[0].Location.PlaceId).To(Equal("a"))
+			Expect(rows[0].Sources).To(Equal([]output.Source{
+				{File: ohio, Query: "Whole Foods in Ohio"},
+				{File: usa, Query: "Whole Foods in USA"},
+			}))
+		})
+	})
+
+	when("places near a point are listed", func() {
+		it("writes the nearest places of a result file, the closest first", func() {
+			home := t.TempDir()
+			results := filepath.Join(home, "ohio.csv")
+
+			Expect(os.WriteFile(results, []byte("query,name,formatted_address,place_id,lat,lng\n"+
+				"Whole Foods in Ohio,Whole Foods Market,Columbus,a,39.9995,-83.0458\n"+
+				"Whole Foods in Ohio,Whole Foods Market,Cleveland,b,41.4993,-81.6944\n"+
+				"Whole Foods in Ohio,Whole Foods Market,Dayton,c,39.6359,-84.2174\n"), 0644)).To(Succeed())
+
+			stdout, stderr, err := pipeCLI(home, nil, "", "near", "--from", results, "--lat", "39.76", "--lng", "-84.19", "--k", "2", "--format", "ndjson")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			rows, err := output.Decode([]byte(stdout))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rows).To(HaveLen(2))
+			Expect(rows[0].Location.PlaceId).To(Equal("c"))
+			Expect(rows[0].Query).To(Equal("Whole Foods in Ohio"))
+			Expect(rows[1].Location.PlaceId).To(Equal("a"))
+			Expect(stderr).To(ContainSubstring("Whole Foods Market, Dayton"))
+
+			stdout, stderr, err = pipeCLI(home, nil, "", "near", "--from", results, "--lat", "39.76", "--lng", "-84.19", "--k", "0", "--radius", "50000")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+
+			rows, err = output.Decode([]byte(stdout))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rows).To(HaveLen(1))
+		})
+	})
+
+	when("the places of a result file are clustered", func() {
+		it("writes the cluster of every place as JSON or GeoJSON", func() {
+			home := t.TempDir()
+			results := filepath.Join(home, "ohio.csv")
+
+			Expect(os.WriteFile(results, []byte("query,name,formatted_address,place_id,lat,lng\n"+
+				"Whole Foods in Ohio,Whole Foods Market,Columbus,a,39.9995,-83.0458\n"+
+				"Whole Foods in Ohio,Whole Foods Market,Columbus,b,40.0500,-83.0200\n"+
+				"Whole Foods in Ohio,Whole Foods Market,Columbus,c,39.9800,-83.0700\n"+
+				"Whole Foods in Ohio,Whole Foods Market,Cleveland,d,41.4993,-81.6944\n"), 0644)).To(Succeed())
+
+			stdout, stderr, err := pipeCLI(home, nil, "", "analyze", "clusters", results, "--eps", "10km", "--min", "3")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stderr).To(ContainSubstring("1 clusters, 1 places not in a cluster (dbscan)"))
+
+			var report cluster.Report
+			Expect(json.Unmarshal([]byte(stdout), &report)).To(Succeed())
+			Expect(report.Clusters).To(HaveLen(1))
+			Expect(report.Clusters[0].PlaceIds).To(Equal([]string{"a", "b", "c"}))
+			Expect(report.Assignments[3].Cluster).To(Equal(cluster.Noise))
+
+			stdout, stderr, err = pipeCLI(home, nil, "", "analyze", "clusters", results, "--algorithm", "kmeans", "--k", "2", "--geojson")
+			Expect(err).NotTo(HaveOccurred(), stderr)
+			Expect(stdout).To(ContainSubstring(`"type": "FeatureCollection"`))
+
+			_, stderr, err = pipeCLI(home, nil, "", "analyze", "clusters", results, "--eps", "far")
+			Expect(err).To(HaveOccurred())
+			Expect(stderr).To(ContainSubstring("invalid distance"))
+		})
+	})
+
+	when("result files are compared", func() {
+		var home, previous, current string
+
+		exitCode := func(err error) int {
+			var exit *exec.ExitError
+			if errors.As(err, &exit) {
+				return exit.ExitCode()
+			}
+			Expect(err).NotTo(HaveOccurred())
+			return 0
+		}
+
+		it.Before(func() {
+			home = t.TempDir()
+			previous = filepath.Join(home, "previous.json")
+			current = filepath.Join(home, "current.ndjson")
+
+			Expect(os.WriteFile(previous, []byte(`[
+				{"place_id": "a", "name": "Whole Foods Market", "formatted_address": "Columbus", "business_status": "OPERATIONAL"},
+				{"place_id": "b", "name": "Whole Foods Market", "formatted_address": "Cleveland", "business_status": "OPERATIONAL"}
+			]`), 0644)).To(Succeed())
+		})
+
+		it("exits with 1 and reports the differences", func() {
+			Expect(os.WriteFile(current, []byte(
+				`{"place_id": "a", "name": "Whole Foods Market", "formatted_address": "Columbus", "business_status": "CLOSED_PERMANENTLY"}`+"\n"+
+					`{"place_id": "c", "name": "Trader Joe's", "formatted_address": "Dayton", "business_status": "OPERATIONAL"}`+"\n"), 0644)).To(Succeed())
+
+			stdout, err := runCLI(home, nil, "diff", previous, current)
+			Expect(exitCode(err)).To(Equal(1), stdout)
+			Expect(stdout).To(ContainSubstring("+ Trader Joe's, Dayton (c)"))
+			Expect(stdout).To(ContainSubstring("- Whole Foods Market, Cleveland (b)"))
+			Expect(stdout).To(ContainSubstring("business_status: OPERATIONAL -> CLOSED_PERMANENTLY"))
+
+			stdout, err = runCLI(home, nil, "diff", "--json", previous, current)
+			Expect(exitCode(err)).To(Equal(1), stdout)
+
+			var report diff.Report
+			Expect(json.Unmarshal([]byte(stdout), &report)).To(Succeed())
+			Expect(report.Added).To(HaveLen(1))
+			Expect(report.Removed).To(HaveLen(1))
+			Expect(report.Changed).To(HaveLen(1))
+		})
+
+		it("exits with 0 when the files match", func() {
+			stdout, err := runCLI(home, nil, "diff", previous, previous)
+			Expect(exitCode(err)).To(BeZero(), stdout)
+			Expect(stdout).To(Equal("0 added, 0 removed, 0 changed\n"))
+		})
+
+		it("exits with 2 on errors", func() {
+			stdout, err := runCLI(home, nil, "diff", previous, filepath.Join(home, "missing.json"))
+			Expect(exitCode(err)).To(Equal(2), stdout)
+			Expect(stdout).To(ContainSubstring("no such file"))
+		})
+
+		it("exits with 2 on usage errors", func() {
+			stdout, err := runCLI(home, nil, "diff", previous)
+			Expect(exitCode(err)).To(Equal(2), stdout)
+			Expect(stdout).To(ContainSubstring("accepts 2 arg(s), received 1"))
+
+			stdout, err = runCLI(home, nil, "diff", "--bogus", previous, current)
+			Expect(exitCode(err)).To(Equal(2), stdout)
+			Expect(stdout).To(ContainSubstring("unknown flag: --bogus"))
+
+			stdout, err = runCLI(home, []string{"MAPS_CONFIG=" + filepath.Join(home, "missing.yaml")}, "diff", previous, previous)
+			Expect(exitCode(err)).To(Equal(2), stdout)
+		})
+	})
+
+	when("nearby search and details are requested", func() {
+		it("serves places within the radius and their details", func() {
+			caller := http.New()
+
+			data, err := caller.Get(fake.URL() + placesfake.NearbySearchPath + "?location=41.59,-93.62&radius=1000&keyword=whole&key=" + apiKey)
+			Expect(err).NotTo(HaveOccurred())
+
+			var nearby types.Response
+			Expect(json.Unmarshal(data, &nearby)).To(Succeed())
+			Expect(nearby.Status).To(Equal("OK"))
+			Expect(nearby.Results).To(HaveLen(1))
+			Expect(nearby.Results[0].PlaceId).To(Equal("iowa-01"))
+
+			data, err = caller.Get(fake.URL() + placesfake.DetailsPath + "?place_id=iowa-02&key=" + apiKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"status":"OK"`))
+			Expect(string(data)).To(ContainSubstring("Iowa City"))
+
+			data, err = caller.Get(fake.URL() + placesfake.DetailsPath + "?place_id=unknown&key=" + apiKey)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"status":"NOT_FOUND"`))
+		})
+
+		it("rejects a token that is used too early", func() {
+			fake.WithTokenDelay(time.Hour)
+			subject := client.New(http.New(), apiKey).WithBaseURL(fake.URL())
+
+			_, err := subject.FetchLocations("Whole Foods in Ohio", nil, nil)
+			Expect(err).To(MatchError(ContainSubstring("INVALID_REQUEST")))
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package llm
+
+import (
+	"fmt"
+	"github.com/kardolus/maps/cache"
+	"github.com/kardolus/maps/types"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultBatchSize  = 25
+	DefaultBatchChars = 6000
+	classifyInput     = inputQuery + "%s\n\n%s"
+)
+
+var verdictRegex = regexp.MustCompile(`(?i)^\[(\d+)\]\s*(relevant|irrelevant)\s*:?\s*(.*)$`)
+
+type VerdictCache interface {
+	Get(key string, v interface{}) (bool, error)
+	Set(key string, v interface{}) error
+}
+
+// Ensure cache.Cache implements VerdictCache interface
+var _ VerdictCache = &cache.Cache{}
+
+// Classifier asks the LLM whether fetched locations are relevant to the query. Candidates are sent in batches that are
+// bounded by both count and size, and verdicts are cached by place id and query.
+type Classifier struct {
+	llm        *LLM
+	cache      VerdictCache
+	batchSize  int
+	batchChars int
+}
+
+func NewClassifier(llm *LLM, cache VerdictCache) *Classifier {
+	return &Classifier{
+		llm:        llm,
+		cache:      cache,
+		batchSize:  DefaultBatchSize,
+		batchChars: DefaultBatchChars,
+	}
+}
+
+// WithBatchSize configures the maximum number of candidates sent to the LLM at once
+func (c *Classifier) WithBatchSize(size int) *Classifier {
+	c.batchSize = size
+	return c
+}
+
+// WithBatchChars configures the maximum size in characters of the candidate list sent to the LLM at once
+func (c *Classifier) WithBatchChars(chars int) *Classifier {
+	c.batchChars = chars
+	return c
+}
+
+// Classify records a verdict on every location. Locations the LLM did not return a verdict for are left untouched.
+func (c *Classifier) Classify(query string, locations []types.Location) ([]types.Location, error) {
+	result := make([]types.Location, len(locations))
+	copy(result, locations)
+
+	var pending []int
+	for i, location := range result {
+		var verdict types.Verdict
+
+		ok, err := c.cache.Get(verdictKey(location, query), &verdict)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			result[i].Relevance = &verdict
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	for _, batch := range c.batches(result, pending) {
+		verdicts, err := c.classifyBatch(query, result, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for n, verdict := range verdicts {
+			i := batch[n]
+			v := verdict
+
+			result[i].Relevance = &v
+			if err := c.cache.Set(verdictKey(result[i], query), v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Relevant drops the locations that were classified as irrelevant
+func Relevant(locations []types.Location) []types.Location {
+	var result []types.Location
+
+	for _, location := range locations {
+		if location.Relevance == nil || location.Relevance.Relevant {
+			result = append(result, location)
+		}
+	}
+
+	return result
+}
+
+// batches splits the pending indexes into groups that respect the size limits
+func (c *Classifier) batches(locations []types.Location, pending []int) [][]int {
+	var (
+		result  [][]int
+		current []int
+		chars   int
+	)
+
+	for _, i := range pending {
+		size := len(describeCandidate(len(current)+1, locations[i]))
+
+		if len(current) > 0 && (len(current) >= c.batchSize || chars+size > c.batchChars) {
+			result = append(result, current)
+			current, chars = nil, 0
+			size = len(describeCandidate(1, locations[i]))
+		}
+
+		current = append(current, i)
+		chars += size
+	}
+
+	if len(current) > 0 {
+		result = append(result, current)
+	}
+
+	return result
+}
+
+// classifyBatch returns the verdicts keyed by position in the batch
+func (c *Classifier) classifyBatch(query string, locations []types.Location, batch []int) (map[int]types.Verdict, error) {
+	prompt, err := c.llm.renderPrompt(ClassifyPromptFile, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	for n, i := range batch {
+		sb.WriteString(describeCandidate(n+1, locations[i]))
+	}
+
+	// every batch is a new conversation, so the size limits hold for the whole request
+	response, err := c.llm.ask(prompt, fmt.Sprintf(classifyInput, query, sb.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	return extractVerdicts(response, len(batch)), nil
+}
+
+func describeCandidate(number int, location types.Location) string {
+	return fmt.Sprintf("[%d] %s | %s | %s\n", number, location.Name, strings.Join(location.Types.Strings(), ", "), location.FormattedAddress)
+}
+
+// extractVerdicts parses lines like "[2] irrelevant: a restaurant" into verdicts keyed by zero based position
+func extractVerdicts(input string, size int) map[int]types.Verdict {
+	result := make(map[int]types.Verdict)
+
+	for _, line := range strings.Split(input, "\n") {
+		matches := verdictRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if len(matches) < 4 {
+			continue
+		}
+
+		number, err := strconv.Atoi(matches[1])
+		if err != nil || number < 1 || number > size {
+			continue
+		}
+
+		result[number-1] = types.Verdict{
+			Relevant: strings.EqualFold(matches[2], "relevant"),
+			Reason:   strings.TrimSpace(matches[3]),
+		}
+	}
+
+	return result
+}
+
+func verdictKey(location types.Location, query string) string {
+	return location.PlaceId + "|" + strings.ToLower(strings.TrimSpace(query))
+}
@@ -0,0 +1,171 @@
+package llm_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	gpthttp "github.com/kardolus/chatgpt-cli/http"
+	chatgpt "github.com/kardolus/chatgpt-cli/types"
+	"github.com/kardolus/maps/cache"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitClassifier(t *testing.T) {
+	spec.Run(t, "Classifier Unit Tests", testClassifier, spec.Report(report.Terminal{}))
+}
+
+func testClassifier(t *testing.T, when spec.G, it spec.S) {
+	const query = "Apple Store in Ohio"
+
+	var (
+		ctrl       *gomock.Controller
+		client     *MockLLMClient
+		reader     *MockFileReader
+		verdicts   *cache.Cache
+		classifier *llm.Classifier
+		locations  []types.Location
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		ctrl = gomock.NewController(t)
+		client = NewMockLLMClient(ctrl)
+		reader = NewMockFileReader(ctrl)
+		verdicts = cache.New()
+
+		classifier = llm.NewClassifier(llm.New(client, reader), verdicts)
+
+		locations = []types.Location{
+			{PlaceId: "a", Name: "Apple Easton", Types: types.PlaceTypes{"electronics_store", "store"}, FormattedAddress: "4030 Easton Station, Columbus, OH"},
+			{PlaceId: "b", Name: "Applebee's Grill + Bar", Types: types.PlaceTypes{"restaurant"}, FormattedAddress: "1 Main St, Dayton, OH"},
+			{PlaceId: "c", Name: "Apple Kenwood", Types: types.PlaceTypes{"electronics_store"}, FormattedAddress: "7875 Montgomery Rd, Cincinnati, OH"},
+		}
+	})
+
+	it.After(func() {
+		ctrl.Finish()
+	})
+
+	it("records a verdict for every location", func() {
+		expectedInput := "input query: " + query + "\n\n" +
+			"[1] Apple Easton | electronics_store, store | 4030 Easton Station, Columbus, OH\n" +
+			"[2] Applebee's Grill + Bar | restaurant | 1 Main St, Dayton, OH\n" +
+			"[3] Apple Kenwood | electronics_store | 7875 Montgomery Rd, Cincinnati, OH\n"
+
+		reader.EXPECT().FileToBytes("classify_prompt.txt").Return([]byte("classify"), nil)
+		client.EXPECT().Reset()
+		client.EXPECT().ProvideContext("classify")
+		client.EXPECT().Query(expectedInput).Return("[1] relevant: Apple retail store\n[2] Irrelevant: restaurant chain\n[3] relevant: Apple retail store", 0, nil)
+
+		result, err := classifier.Classify(query, locations)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(HaveLen(3))
+		Expect(*result[0].Relevance).To(Equal(types.Verdict{Relevant: true, Reason: "Apple retail store"}))
+		Expect(*result[1].Relevance).To(Equal(types.Verdict{Relevant: false, Reason: "restaurant chain"}))
+		Expect(locations[0].Relevance).To(BeNil()) // the input is not modified
+		Expect(verdicts.Len()).To(Equal(3))
+
+		Expect(llm.Relevant(result)).To(HaveLen(2))
+	})
+
+	it("leaves locations without a verdict unclassified", func() {
+		reader.EXPECT().FileToBytes("classify_prompt.txt").Return([]byte("classify"), nil)
+		client.EXPECT().Reset()
+		client.EXPECT().ProvideContext("classify")
+		client.EXPECT().Query(gomock.Any()).Return("[2] irrelevant: restaurant\n[7] relevant: out of range", 0, nil)
+
+		result, err := classifier.Classify(query, locations)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result[0].Relevance).To(BeNil())
+		Expect(result[1].Relevance.Relevant).To(BeFalse())
+		Expect(result[2].Relevance).To(BeNil())
+		Expect(llm.Relevant(result)).To(HaveLen(2))
+	})
+
+	it("uses cached verdicts and only sends the remaining locations", func() {
+		Expect(verdicts.Set("a|apple store in ohio", types.Verdict{Relevant: true, Reason: "cached"})).To(Succeed())
+		Expect(verdicts.Set("b|apple store in ohio", types.Verdict{Relevant: false, Reason: "cached"})).To(Succeed())
+
+		reader.EXPECT().FileToBytes("classify_prompt.txt").Return([]byte("classify"), nil)
+		client.EXPECT().Reset()
+		client.EXPECT().ProvideContext("classify")
+		client.EXPECT().Query("input query: "+query+"\n\n[1] Apple Kenwood | electronics_store | 7875 Montgomery Rd, Cincinnati, OH\n").Return("[1] relevant: store", 0, nil)
+
+		result, err := classifier.Classify(query, locations)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result[0].Relevance.Reason).To(Equal("cached"))
+		Expect(result[2].Relevance.Reason).To(Equal("store"))
+	})
+
+	it("splits candidates into batches by count", func() {
+		classifier.WithBatchSize(2)
+
+		reader.EXPECT().FileToBytes("classify_prompt.txt").Return([]byte("classify"), nil).Times(2)
+		client.EXPECT().Reset().Times(2)
+		client.EXPECT().ProvideContext("classify").Times(2)
+		client.EXPECT().Query(gomock.Any()).Return("[1] relevant: a\n[2] irrelevant: b", 0, nil)
+		client.EXPECT().Query("input query: "+query+"\n\n[1] Apple Kenwood | electronics_store | 7875 Montgomery Rd, Cincinnati, OH\n").Return("[1] relevant: c", 0, nil)
+
+		result, err := classifier.Classify(query, locations)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result[2].Relevance.Reason).To(Equal("c"))
+	})
+
+	it("splits candidates into batches by size", func() {
+		classifier.WithBatchChars(80)
+
+		reader.EXPECT().FileToBytes("classify_prompt.txt").Return([]byte("classify"), nil).Times(3)
+		client.EXPECT().Reset().Times(3)
+		client.EXPECT().ProvideContext("classify").Times(3)
+		client.EXPECT().Query(gomock.Any()).Return("[1] relevant: ok", 0, nil).Times(3)
+
+		result, err := classifier.Classify(query, locations)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(llm.Relevant(result)).To(HaveLen(3))
+	})
+
+	it("sends every batch in a new conversation", func() {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("OPENAI_API_KEY", "key")
+
+		caller := &recordingCaller{reply: "[1] relevant: ok"}
+		conversation, err := llm.NewChatGPTClientWithCaller(func(chatgpt.Config) gpthttp.Caller { return caller })
+		Expect(err).NotTo(HaveOccurred())
+
+		classifier = llm.NewClassifier(llm.New(conversation, reader), verdicts).WithBatchSize(1)
+		reader.EXPECT().FileToBytes("classify_prompt.txt").Return([]byte("classify"), nil).Times(3)
+
+		_, err = classifier.Classify(query, locations)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(caller.inputs).To(HaveLen(3))
+		for i, inputs := range caller.inputs {
+			Expect(inputs).To(Equal([]string{
+				"classify",
+				"input query: " + query + "\n\n" + fmt.Sprintf("[1] %s | %s | %s\n", locations[i].Name, strings.Join(locations[i].Types.Strings(), ", "), locations[i].FormattedAddress),
+			}))
+		}
+	})
+
+	it("returns an error when the query fails", func() {
+		reader.EXPECT().FileToBytes("classify_prompt.txt").Return([]byte("classify"), nil)
+		client.EXPECT().Reset()
+		client.EXPECT().ProvideContext("classify")
+		client.EXPECT().Query(gomock.Any()).Return("", 0, fmt.Errorf("query error"))
+
+		_, err := classifier.Classify(query, locations)
+
+		Expect(err).To(MatchError("query error"))
+	})
+}
@@ -77,6 +77,18 @@ func (mr *MockLLMClientMockRecorder) Query(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Query", reflect.TypeOf((*MockLLMClient)(nil).Query), arg0)
 }
 
+// Reset mocks base method.
+func (m *MockLLMClient) Reset() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Reset")
+}
+
+// Reset indicates an expected call of Reset.
+func (mr *MockLLMClientMockRecorder) Reset() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reset", reflect.TypeOf((*MockLLMClient)(nil).Reset))
+}
+
 // Stream mocks base method.
 func (m *MockLLMClient) Stream(arg0 string) error {
 	m.ctrl.T.Helper()
@@ -1,34 +1,60 @@
 package llm
 
 import (
+	"fmt"
 	"github.com/kardolus/chatgpt-cli/client"
 	"github.com/kardolus/chatgpt-cli/config"
-	"github.com/kardolus/chatgpt-cli/configmanager"
 	"github.com/kardolus/chatgpt-cli/history"
 	"github.com/kardolus/chatgpt-cli/http"
+	chatgpt "github.com/kardolus/chatgpt-cli/types"
+	"github.com/kardolus/maps/types"
 	"github.com/kardolus/maps/utils"
 	"regexp"
 	"strings"
+	"text/template"
 )
 
 const (
-	promptFile = "query_prompt.txt"
-	filterFile = "filter_prompt.txt"
-	inputQuery = "input query: "
+	QueryPromptFile    = "query_prompt.txt"
+	FilterPromptFile   = "filter_prompt.txt"
+	SplitPromptFile    = "split_prompt.txt"
+	ClassifyPromptFile = "classify_prompt.txt"
+	inputQuery         = "input query: "
+	splitInput         = inputQuery + "%s\nresults: %d\npages: %d"
+	DefaultMaxResults  = 50
 )
 
+// PromptData holds the variables that are available to the prompt templates
+type PromptData struct {
+	Query      string
+	MaxResults int
+	Locale     string
+}
+
 //go:generate mockgen -destination=clientmocks_test.go -package=llm_test github.com/kardolus/maps/llm LLMClient
 type LLMClient interface {
 	ProvideContext(context string)
 	Query(input string) (string, int, error)
+	Reset()
 	Stream(input string) error
 	ListModels() ([]string, error)
 	WithContextWindow(window int) *client.Client
 	WithServiceURL(url string) *client.Client
 }
 
-// Ensure client.Client implements LLMClient interface
-var _ LLMClient = &client.Client{}
+// Ensure Conversation implements LLMClient interface
+var _ LLMClient = &Conversation{}
+
+// Conversation is a ChatGPT client that can start over, so every prompt is answered without the earlier prompts and
+// answers in its context
+type Conversation struct {
+	*client.Client
+}
+
+// Reset forgets the prompts and answers exchanged so far
+func (c *Conversation) Reset() {
+	c.History = nil
+}
 
 //go:generate mockgen -destination=readermocks_test.go -package=llm_test github.com/kardolus/maps/llm FileReader
 type FileReader interface {
@@ -41,35 +67,124 @@ var _ FileReader = &utils.Utils{}
 type LLM struct {
 	client     LLMClient
 	fileReader FileReader
+	maxResults int
+	locale     string
 }
 
 func New(client LLMClient, fileReader FileReader) *LLM {
 	return &LLM{
 		client:     client,
 		fileReader: fileReader,
+		maxResults: DefaultMaxResults,
 	}
 }
 
-func NewChatGPTClient() (*client.Client, error) {
+// WithMaxResults configures the number of results a single sub-query should stay under
+func (l *LLM) WithMaxResults(maxResults int) *LLM {
+	l.maxResults = maxResults
+	return l
+}
+
+// WithLocale configures the locale that is passed to the prompt templates
+func (l *LLM) WithLocale(locale string) *LLM {
+	l.locale = locale
+	return l
+}
+
+func NewChatGPTClient() (*Conversation, error) {
+	return NewChatGPTClientWithCaller(http.RealCallerFactory)
+}
+
+// Provider overrides the LLM settings of the ChatGPT config. Empty fields keep the configured value and the OPENAI_*
+// environment variables still take precedence.
+type Provider struct {
+	URL    string
+	Model  string
+	APIKey string
+}
+
+// NewChatGPTClientFor creates a ChatGPT client for the provider
+func NewChatGPTClientFor(provider Provider) (*Conversation, error) {
+	return newConversation(http.RealCallerFactory, providerStore{ConfigStore: config.New(), provider: provider})
+}
+
+// providerStore applies the settings of a provider on top of the config it reads
+type providerStore struct {
+	config.ConfigStore
+	provider Provider
+}
+
+func (s providerStore) ReadDefaults() chatgpt.Config {
+	return s.apply(s.ConfigStore.ReadDefaults())
+}
+
+func (s providerStore) Read() (chatgpt.Config, error) {
+	result, err := s.ConfigStore.Read()
+	return s.apply(result), err
+}
+
+func (s providerStore) apply(c chatgpt.Config) chatgpt.Config {
+	if s.provider.URL != "" {
+		c.URL = s.provider.URL
+	}
+	if s.provider.Model != "" {
+		c.Model = s.provider.Model
+	}
+	if s.provider.APIKey != "" {
+		c.APIKey = s.provider.APIKey
+	}
+	return c
+}
+
+// NewChatGPTClientWithCaller creates a ChatGPT client whose HTTP calls go through the given factory, e.g. a recorder
+func NewChatGPTClientWithCaller(factory http.CallerFactory) (*Conversation, error) {
+	return newConversation(factory, config.New())
+}
+
+func newConversation(factory http.CallerFactory, store config.ConfigStore) (*Conversation, error) {
 	hs, _ := history.New() // do not error out
-	return client.New(http.RealCallerFactory, config.New(), hs, false)
+
+	c, err := client.New(factory, store, hs, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// the history stays in memory: the thread file of the ChatGPT config is shared by every client, e.g. the jobs of
+	// the server, which would read each other's prompts
+	c.Config.OmitHistory = true
+
+	return &Conversation{Client: c}, nil
 }
 
+// ClearHistory starts a new conversation
 func (l *LLM) ClearHistory() error {
-	cm := configmanager.New(config.New())
-
-	return cm.DeleteThread(cm.Config.Thread)
+	l.client.Reset()
+	return nil
 }
 
 func (l *LLM) GenerateSubQueries(query string) ([]string, error) {
-	bytes, err := l.fileReader.FileToBytes(promptFile)
+	prompt, err := l.renderPrompt(QueryPromptFile, query)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := l.ask(prompt, inputQuery+query)
 	if err != nil {
 		return nil, err
 	}
 
-	l.client.ProvideContext(string(bytes))
+	return extractSearchQueries(response), nil
+}
+
+// SplitSaturatedQuery asks the LLM to subdivide a sub-query that hit the Places API result cap. The observed counts
+// are passed along so the model learns its initial breakdown was too coarse.
+func (l *LLM) SplitSaturatedQuery(stats types.QueryStats) ([]string, error) {
+	prompt, err := l.renderPrompt(SplitPromptFile, stats.Query)
+	if err != nil {
+		return nil, err
+	}
 
-	response, _, err := l.client.Query(inputQuery + query)
+	response, err := l.ask(prompt, fmt.Sprintf(splitInput, stats.Query, stats.Results, stats.Pages))
 	if err != nil {
 		return nil, err
 	}
@@ -79,14 +194,12 @@ func (l *LLM) GenerateSubQueries(query string) ([]string, error) {
 
 // GenerateFilter will extract the 'contains' and 'matches' strings from the LLM's response
 func (l *LLM) GenerateFilter(query string) ([]string, []string, error) {
-	bytes, err := l.fileReader.FileToBytes(filterFile)
+	prompt, err := l.renderPrompt(FilterPromptFile, query)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	l.client.ProvideContext(string(bytes))
-
-	response, _, err := l.client.Query(inputQuery + query)
+	response, err := l.ask(prompt, inputQuery+query)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -96,6 +209,42 @@ func (l *LLM) GenerateFilter(query string) ([]string, []string, error) {
 	return contains, matches, nil
 }
 
+// ask sends the prompt and the input in a new conversation. Carrying the earlier prompts along would skew the answer,
+// e.g. of the later split rounds, and eventually exceed the context window.
+func (l *LLM) ask(prompt, input string) (string, error) {
+	l.client.Reset()
+	l.client.ProvideContext(prompt)
+
+	response, _, err := l.client.Query(input)
+	return response, err
+}
+
+// renderPrompt reads a prompt and executes it as a text/template using PromptData
+func (l *LLM) renderPrompt(fileName, query string) (string, error) {
+	bytes, err := l.fileReader.FileToBytes(fileName)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := template.New(fileName).Option("missingkey=error").Parse(string(bytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse prompt %s: %w", fileName, err)
+	}
+
+	var sb strings.Builder
+	data := PromptData{
+		Query:      query,
+		MaxResults: l.maxResults,
+		Locale:     l.locale,
+	}
+
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render prompt %s: %w", fileName, err)
+	}
+
+	return sb.String(), nil
+}
+
 // extractContainsAndMatches will extract the 'contains' and 'matches' strings using regex
 func extractContainsAndMatches(input string) ([]string, []string) {
 	var containsList, matchesList []string
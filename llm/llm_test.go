@@ -1,11 +1,17 @@
 package llm_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/golang/mock/gomock"
+	gpthttp "github.com/kardolus/chatgpt-cli/http"
+	chatgpt "github.com/kardolus/chatgpt-cli/types"
 	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/types"
 	. "github.com/onsi/gomega"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -39,6 +45,7 @@ func testLLM(t *testing.T, when spec.G, it spec.S) {
 	it("generates sub-queries for a valid input query", func() {
 		query := "Whole Foods in USA"
 		mockReader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte("prompt content"), nil)
+		mockClient.EXPECT().Reset()
 		mockClient.EXPECT().ProvideContext("prompt content")
 		mockClient.EXPECT().Query("input query: "+query).Return("search [1]: Whole Foods in New York\nsearch [2]: Whole Foods in California", 0, nil)
 
@@ -51,6 +58,7 @@ func testLLM(t *testing.T, when spec.G, it spec.S) {
 	it("extracts 'contains' and 'matches' from a query", func() {
 		query := "Whole Foods in USA"
 		mockReader.EXPECT().FileToBytes("filter_prompt.txt").Return([]byte("filter prompt content"), nil)
+		mockClient.EXPECT().Reset()
 		mockClient.EXPECT().ProvideContext("filter prompt content")
 		mockClient.EXPECT().Query("input query: "+query).Return("contains: whole foods market, whole foods\nmatches: whole foods", 0, nil)
 
@@ -72,6 +80,7 @@ func testLLM(t *testing.T, when spec.G, it spec.S) {
 
 	it("returns an error when Query fails in GenerateSubQueries", func() {
 		mockReader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte("prompt content"), nil)
+		mockClient.EXPECT().Reset()
 		mockClient.EXPECT().ProvideContext("prompt content")
 		mockClient.EXPECT().Query("input query: Whole Foods in USA").Return("", 0, fmt.Errorf("query error"))
 
@@ -80,4 +89,145 @@ func testLLM(t *testing.T, when spec.G, it spec.S) {
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(ContainSubstring("query error"))
 	})
+
+	when("rendering prompt templates", func() {
+		it("substitutes the query, max results and locale", func() {
+			query := "Boulangeries in Paris"
+			template := "{{.Query}} under {{.MaxResults}}{{if .Locale}} in {{.Locale}}{{end}}"
+			subject.WithMaxResults(20).WithLocale("fr-FR")
+
+			mockReader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte(template), nil)
+			mockClient.EXPECT().Reset()
+			mockClient.EXPECT().ProvideContext("Boulangeries in Paris under 20 in fr-FR")
+			mockClient.EXPECT().Query("input query: "+query).Return("search [1]: Boulangeries in Paris 1er", 0, nil)
+
+			result, err := subject.GenerateSubQueries(query)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal([]string{"Boulangeries in Paris 1er"}))
+		})
+
+		it("uses the default max results when none is configured", func() {
+			mockReader.EXPECT().FileToBytes("filter_prompt.txt").Return([]byte("max {{.MaxResults}}"), nil)
+			mockClient.EXPECT().Reset()
+			mockClient.EXPECT().ProvideContext(fmt.Sprintf("max %d", llm.DefaultMaxResults))
+			mockClient.EXPECT().Query(gomock.Any()).Return("No filtering required", 0, nil)
+
+			contains, matches, err := subject.GenerateFilter("Wine Stores in Paris")
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(contains).To(BeEmpty())
+			Expect(matches).To(BeEmpty())
+		})
+
+		it("returns an error for an invalid template", func() {
+			mockReader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte("{{.Unknown"), nil)
+
+			_, err := subject.GenerateSubQueries("Whole Foods in USA")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to parse prompt query_prompt.txt"))
+		})
+
+		it("returns an error for an unknown template variable", func() {
+			mockReader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte("{{.Unknown}}"), nil)
+
+			_, err := subject.GenerateSubQueries("Whole Foods in USA")
+
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("failed to render prompt query_prompt.txt"))
+		})
+	})
+	it("starts a new conversation for every prompt", func() {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("OPENAI_API_KEY", "key")
+
+		caller := &recordingCaller{reply: "search [1]: Whole Foods in Los Angeles"}
+		conversation, err := llm.NewChatGPTClientWithCaller(func(chatgpt.Config) gpthttp.Caller { return caller })
+		Expect(err).NotTo(HaveOccurred())
+
+		subject = llm.New(conversation, mockReader)
+		mockReader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte("breakdown"), nil)
+		mockReader.EXPECT().FileToBytes("split_prompt.txt").Return([]byte("split"), nil).Times(2)
+
+		_, err = subject.GenerateSubQueries("Whole Foods in USA")
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, query := range []string{"Whole Foods in California", "Whole Foods in Texas"} {
+			_, err = subject.SplitSaturatedQuery(types.QueryStats{Query: query, Results: 60, Pages: 3})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(caller.inputs).To(Equal([][]string{
+			{"breakdown", "input query: Whole Foods in USA"},
+			{"split", "input query: Whole Foods in California\nresults: 60\npages: 3"},
+			{"split", "input query: Whole Foods in Texas\nresults: 60\npages: 3"},
+		}))
+	})
+
+	it("keeps the conversation out of the ChatGPT history", func() {
+		home := t.TempDir()
+		t.Setenv("HOME", home)
+		t.Setenv("OPENAI_API_KEY", "key")
+		Expect(os.MkdirAll(filepath.Join(home, ".chatgpt-cli", "history"), 0755)).To(Succeed())
+
+		caller := &recordingCaller{reply: "search [1]: Whole Foods in Ohio"}
+		mockReader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte("breakdown"), nil).Times(2)
+
+		for _, query := range []string{"Whole Foods in USA", "Trader Joe's in USA"} {
+			conversation, err := llm.NewChatGPTClientWithCaller(func(chatgpt.Config) gpthttp.Caller { return caller })
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = llm.New(conversation, mockReader).GenerateSubQueries(query)
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		Expect(caller.inputs).To(Equal([][]string{
+			{"breakdown", "input query: Whole Foods in USA"},
+			{"breakdown", "input query: Trader Joe's in USA"},
+		}))
+		Expect(filepath.Join(home, ".chatgpt-cli", "history", "default.json")).NotTo(BeAnExistingFile())
+	})
+
+	it("lets the OPENAI_* environment variables take precedence over the provider", func() {
+		t.Setenv("HOME", t.TempDir())
+		t.Setenv("OPENAI_MODEL", "env-model")
+		t.Setenv("OPENAI_API_KEY", "env-key")
+		t.Setenv("OPENAI_URL", "")
+
+		conversation, err := llm.NewChatGPTClientFor(llm.Provider{URL: "http://provider", Model: "provider-model", APIKey: "provider-key"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conversation.Config.Model).To(Equal("env-model"))
+		Expect(conversation.Config.APIKey).To(Equal("env-key"))
+		Expect(conversation.Config.URL).To(Equal("http://provider"))
+	})
+}
+
+// recordingCaller answers every completion with the same reply and records the prompts and inputs of every request
+type recordingCaller struct {
+	reply  string
+	inputs [][]string
+}
+
+func (r *recordingCaller) Get(url string) ([]byte, error) {
+	return nil, fmt.Errorf("unexpected GET %s", url)
+}
+
+func (r *recordingCaller) Post(url string, body []byte, stream bool) ([]byte, error) {
+	var request chatgpt.CompletionsRequest
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, err
+	}
+
+	var inputs []string
+	for _, message := range request.Messages {
+		if message.Role == "user" {
+			inputs = append(inputs, message.Content)
+		}
+	}
+	r.inputs = append(r.inputs, inputs)
+
+	return json.Marshal(map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": r.reply}}},
+	})
 }
@@ -0,0 +1,189 @@
+package llm
+
+import (
+	"fmt"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/types"
+	"strings"
+)
+
+const DefaultRounds = 2
+
+//go:generate mockgen -destination=fetchermocks_test.go -package=llm_test github.com/kardolus/maps/llm Fetcher
+type Fetcher interface {
+	FetchFiltered(entity string, names *filter.Names) ([]types.Location, types.QueryStats, error)
+}
+
+// PlanNode is a single query in the planning tree. The root holds the user's query, every other node holds a
+// sub-query together with what the Places API returned for it.
+type PlanNode struct {
+	Query    string           `json:"query"`
+	Stats    types.QueryStats `json:"stats"`
+	Children []*PlanNode      `json:"children,omitempty"`
+}
+
+type Planner struct {
+	llm     *LLM
+	fetcher Fetcher
+	rounds  int
+}
+
+func NewPlanner(llm *LLM, fetcher Fetcher) *Planner {
+	return &Planner{
+		llm:     llm,
+		fetcher: fetcher,
+		rounds:  DefaultRounds,
+	}
+}
+
+// WithRounds configures how many times saturated sub-queries are sent back to the LLM for subdivision
+func (p *Planner) WithRounds(rounds int) *Planner {
+	p.rounds = rounds
+	return p
+}
+
+// Plan breaks the query down into sub-queries and fetches them. Sub-queries that hit the Places API result cap are
+// sent back to the LLM to be split further, up to the configured number of rounds. Locations are de-duplicated by
+// place id across the whole tree.
+func (p *Planner) Plan(query string, names *filter.Names) (*PlanNode, []types.Location, error) {
+	root := &PlanNode{Query: query}
+
+	subQueries, err := p.llm.GenerateSubQueries(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(subQueries) == 0 {
+		subQueries = []string{query}
+	}
+
+	var result []types.Location
+
+	found := make(map[string]struct{})
+	seen := make(map[string]struct{})
+
+	frontier := p.addChildren(root, subQueries, seen)
+
+	for round := 0; len(frontier) > 0; round++ {
+		for _, node := range frontier {
+			locations, stats, err := p.fetcher.FetchFiltered(node.Query, names)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			node.Stats = stats
+
+			for _, location := range locations {
+				if _, ok := found[location.PlaceId]; !ok {
+					result = append(result, location)
+					found[location.PlaceId] = struct{}{}
+				}
+			}
+		}
+
+		if round == p.rounds {
+			break
+		}
+
+		var next []*PlanNode
+		for _, node := range frontier {
+			if !node.Stats.Saturated() {
+				continue
+			}
+
+			children, err := p.llm.SplitSaturatedQuery(node.Stats)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			next = append(next, p.addChildren(node, children, seen)...)
+		}
+
+		frontier = next
+	}
+
+	root.Stats = root.total()
+	root.Stats.Kept = len(result)
+
+	return root, result, nil
+}
+
+// Queries returns every query in the tree that was sent to the Places API
+func (n *PlanNode) Queries() []string {
+	var result []string
+
+	for _, child := range n.Children {
+		result = append(result, child.Query)
+		result = append(result, child.Queries()...)
+	}
+
+	return result
+}
+
+// String renders the tree, one query per line, annotated with the observed counts
+func (n *PlanNode) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("%s %s\n", n.Query, describe(n.Stats)))
+	n.render(&sb, "")
+
+	return sb.String()
+}
+
+func (n *PlanNode) render(sb *strings.Builder, prefix string) {
+	for i, child := range n.Children {
+		branch, indent := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		line := fmt.Sprintf("%s%s%s %s", prefix, branch, child.Query, describe(child.Stats))
+		if child.Stats.Saturated() {
+			line += " [saturated]"
+		}
+
+		sb.WriteString(line + "\n")
+		child.render(sb, prefix+indent)
+	}
+}
+
+// total sums the stats of all descendants
+func (n *PlanNode) total() types.QueryStats {
+	result := types.QueryStats{Query: n.Query}
+
+	for _, child := range n.Children {
+		result.Pages += child.Stats.Pages
+		result.Results += child.Stats.Results
+		result.Kept += child.Stats.Kept
+
+		sub := child.total()
+		result.Pages += sub.Pages
+		result.Results += sub.Results
+		result.Kept += sub.Kept
+	}
+
+	return result
+}
+
+// addChildren attaches the queries to the node, skipping any that were planned before
+func (p *Planner) addChildren(node *PlanNode, queries []string, seen map[string]struct{}) []*PlanNode {
+	var result []*PlanNode
+
+	for _, query := range queries {
+		key := strings.ToLower(strings.TrimSpace(query))
+		if _, ok := seen[key]; ok || key == "" {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		child := &PlanNode{Query: query}
+		node.Children = append(node.Children, child)
+		result = append(result, child)
+	}
+
+	return result
+}
+
+func describe(stats types.QueryStats) string {
+	return fmt.Sprintf("(%d results, %d pages, %d kept)", stats.Results, stats.Pages, stats.Kept)
+}
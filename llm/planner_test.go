@@ -0,0 +1,139 @@
+package llm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/llm"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitPlanner(t *testing.T) {
+	spec.Run(t, "Planner Unit Tests", testPlanner, spec.Report(report.Terminal{}))
+}
+
+func testPlanner(t *testing.T, when spec.G, it spec.S) {
+	const query = "Whole Foods in USA"
+
+	var (
+		ctrl        *gomock.Controller
+		client      *MockLLMClient
+		reader      *MockFileReader
+		fetcher     *MockFetcher
+		planner     *llm.Planner
+		names       = filter.NewNames([]string{"whole foods"}, []string{"whole foods market"})
+		unsaturated = func(q string, kept int) types.QueryStats {
+			return types.QueryStats{Query: q, Pages: 1, Results: 20, Kept: kept}
+		}
+		saturated = func(q string) types.QueryStats {
+			return types.QueryStats{Query: q, Pages: 3, Results: types.MaxResultsPerQuery, Kept: 2}
+		}
+		location = func(id string) types.Location {
+			return types.Location{PlaceId: id, Name: "Whole Foods Market"}
+		}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		ctrl = gomock.NewController(t)
+		client = NewMockLLMClient(ctrl)
+		reader = NewMockFileReader(ctrl)
+		fetcher = NewMockFetcher(ctrl)
+
+		planner = llm.NewPlanner(llm.New(client, reader), fetcher)
+
+		reader.EXPECT().FileToBytes("query_prompt.txt").Return([]byte("breakdown"), nil)
+		gomock.InOrder(client.EXPECT().Reset(), client.EXPECT().ProvideContext("breakdown"))
+	})
+
+	it.After(func() {
+		ctrl.Finish()
+	})
+
+	it("fetches every sub-query once when none are saturated", func() {
+		client.EXPECT().Query("input query: "+query).Return("search [1]: Whole Foods in Ohio\nsearch [2]: Whole Foods in Iowa", 0, nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in Ohio", names).Return([]types.Location{location("a")}, unsaturated("Whole Foods in Ohio", 1), nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in Iowa", names).Return([]types.Location{location("b"), location("a")}, unsaturated("Whole Foods in Iowa", 2), nil)
+
+		tree, result, err := planner.Plan(query, names)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(HaveLen(2))
+		Expect(tree.Queries()).To(Equal([]string{"Whole Foods in Ohio", "Whole Foods in Iowa"}))
+		Expect(tree.Stats.Results).To(Equal(40))
+		Expect(tree.Stats.Kept).To(Equal(2))
+	})
+
+	it("falls back to the original query when the breakdown is empty", func() {
+		client.EXPECT().Query("input query: "+query).Return("", 0, nil)
+		fetcher.EXPECT().FetchFiltered(query, names).Return(nil, unsaturated(query, 0), nil)
+
+		tree, _, err := planner.Plan(query, names)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Queries()).To(Equal([]string{query}))
+	})
+
+	it("sends saturated sub-queries back to the LLM with their counts", func() {
+		client.EXPECT().Query("input query: "+query).Return("search [1]: Whole Foods in California\nsearch [2]: Whole Foods in Ohio", 0, nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in California", names).Return([]types.Location{location("a")}, saturated("Whole Foods in California"), nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in Ohio", names).Return([]types.Location{location("b")}, unsaturated("Whole Foods in Ohio", 1), nil)
+
+		reader.EXPECT().FileToBytes("split_prompt.txt").Return([]byte("split"), nil)
+		gomock.InOrder(client.EXPECT().Reset(), client.EXPECT().ProvideContext("split"))
+		client.EXPECT().Query("input query: Whole Foods in California\nresults: 60\npages: 3").Return("search [1]: Whole Foods in Los Angeles\nsearch [2]: Whole Foods in Ohio", 0, nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in Los Angeles", names).Return([]types.Location{location("c"), location("a")}, unsaturated("Whole Foods in Los Angeles", 2), nil)
+
+		tree, result, err := planner.Plan(query, names)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(HaveLen(3))
+		Expect(tree.Children).To(HaveLen(2))
+		Expect(tree.Children[0].Children).To(HaveLen(1)) // Ohio was already planned
+		Expect(tree.Queries()).To(Equal([]string{"Whole Foods in California", "Whole Foods in Los Angeles", "Whole Foods in Ohio"}))
+		Expect(tree.String()).To(ContainSubstring("├── Whole Foods in California (60 results, 3 pages, 2 kept) [saturated]"))
+		Expect(tree.String()).To(ContainSubstring("│   └── Whole Foods in Los Angeles (20 results, 1 pages, 2 kept)"))
+	})
+
+	it("stops after the configured number of rounds", func() {
+		planner.WithRounds(1)
+
+		client.EXPECT().Query("input query: "+query).Return("search [1]: Whole Foods in California", 0, nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in California", names).Return(nil, saturated("Whole Foods in California"), nil)
+
+		reader.EXPECT().FileToBytes("split_prompt.txt").Return([]byte("split"), nil)
+		gomock.InOrder(client.EXPECT().Reset(), client.EXPECT().ProvideContext("split"))
+		client.EXPECT().Query(gomock.Any()).Return("search [1]: Whole Foods in Los Angeles", 0, nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in Los Angeles", names).Return(nil, saturated("Whole Foods in Los Angeles"), nil)
+
+		tree, _, err := planner.Plan(query, names)
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tree.Queries()).To(HaveLen(2))
+	})
+
+	it("does not re-split when rounds is zero", func() {
+		planner.WithRounds(0)
+
+		client.EXPECT().Query("input query: "+query).Return("search [1]: Whole Foods in California", 0, nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in California", names).Return(nil, saturated("Whole Foods in California"), nil)
+
+		_, _, err := planner.Plan(query, names)
+
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	it("returns an error when a fetch fails", func() {
+		client.EXPECT().Query("input query: "+query).Return("search [1]: Whole Foods in Ohio", 0, nil)
+		fetcher.EXPECT().FetchFiltered("Whole Foods in Ohio", names).Return(nil, types.QueryStats{}, fmt.Errorf("fetch error"))
+
+		_, _, err := planner.Plan(query, names)
+
+		Expect(err).To(MatchError("fetch error"))
+	})
+}
@@ -0,0 +1,188 @@
+// Package mcp serves the searches as tools of the Model Context Protocol, so assistants can look up places. Messages
+// are JSON-RPC 2.0 requests and responses, one per line, read from stdin and written to stdout.
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/types"
+	"io"
+	"slices"
+)
+
+const (
+	ProtocolVersion = "2025-06-18"
+	ServerName      = "maps"
+	ServerVersion   = "dev"
+
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+
+	maxMessageSize = 16 * 1024 * 1024
+)
+
+// supportedVersions are the protocol versions the server answers with when a client asks for them, newest first
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// Factory returns the finder for a search_places call. The finder should write its progress messages to log.
+type Factory func(opts app.Options, log io.Writer) (app.Finder, error)
+
+// Places looks up places directly, without planning the search
+type Places interface {
+	Nearby(query client.NearbyQuery, names *filter.Names) ([]types.Location, types.QueryStats, error)
+	Details(placeId string) (types.Location, error)
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+type Server struct {
+	base    app.Options
+	factory Factory
+	places  Places
+	log     io.Writer
+}
+
+// New answers the tool calls with the finders of the factory and the places client. Searches start from the base
+// options, which hold the configuration the server was started with.
+func New(base app.Options, factory Factory, places Places) *Server {
+	return &Server{
+		base:    base,
+		factory: factory,
+		places:  places,
+		log:     io.Discard,
+	}
+}
+
+// WithLog configures where the progress of the tool calls is written. It must not be the output of the protocol.
+func (s *Server) WithLog(log io.Writer) *Server {
+	s.log = log
+	return s
+}
+
+// Serve answers the requests read from in until it is closed. Requests are handled one at a time; notifications are
+// not answered.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageSize)
+
+	encoder := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		if reply := s.handle(line); reply != nil {
+			if err := encoder.Encode(reply); err != nil {
+				return err
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// handle answers a single message, or returns nil for notifications
+func (s *Server) handle(message []byte) *response {
+	var req request
+	if err := json.Unmarshal(message, &req); err != nil {
+		return &response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "parse error: " + err.Error()}}
+	}
+
+	if req.ID == nil {
+		return nil
+	}
+
+	reply := &response{JSONRPC: "2.0", ID: req.ID}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		reply.Error = &rpcError{Code: codeInvalidRequest, Message: "invalid request, expected a JSON-RPC 2.0 request with a method"}
+		return reply
+	}
+
+	result, err := s.dispatch(req.Method, req.Params)
+	if err != nil {
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		reply.Error = rpcErr
+		return reply
+	}
+
+	reply.Result = result
+	return reply
+}
+
+func (s *Server) dispatch(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		return s.initialize(params)
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]interface{}{"tools": Tools()}, nil
+	case "tools/call":
+		var call struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &call); err != nil {
+			return nil, fmt.Errorf("invalid tools/call params: %w", err)
+		}
+		return s.Call(call.Name, call.Arguments)
+	}
+
+	return nil, &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", method)}
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var init struct {
+		ProtocolVersion string `json:"protocolVersion"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &init); err != nil {
+			return nil, fmt.Errorf("invalid initialize params: %w", err)
+		}
+	}
+
+	version := ProtocolVersion
+	if slices.Contains(supportedVersions, init.ProtocolVersion) {
+		version = init.ProtocolVersion
+	}
+
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities":    map[string]interface{}{"tools": map[string]interface{}{"listChanged": false}},
+		"serverInfo":      map[string]string{"name": ServerName, "version": ServerVersion},
+	}, nil
+}
@@ -0,0 +1,270 @@
+package mcp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/mcp"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitMCP(t *testing.T) {
+	spec.Run(t, "MCP Unit Tests", testMCP, spec.Report(report.Terminal{}))
+}
+
+type fakeFinder struct {
+	locations []types.Location
+	err       error
+}
+
+func (f *fakeFinder) Find(opts app.Options) ([]types.Location, app.Summary, error) {
+	return f.locations, app.Summary{}, f.err
+}
+
+type fakePlaces struct {
+	query     client.NearbyQuery
+	locations []types.Location
+	err       error
+}
+
+func (f *fakePlaces) Nearby(query client.NearbyQuery, names *filter.Names) ([]types.Location, types.QueryStats, error) {
+	f.query = query
+
+	var result []types.Location
+	for _, location := range f.locations {
+		if names.Keep(location.Name) {
+			result = append(result, location)
+		}
+	}
+	return result, types.QueryStats{}, f.err
+}
+
+func (f *fakePlaces) Details(placeId string) (types.Location, error) {
+	for _, location := range f.locations {
+		if location.PlaceId == placeId {
+			return location, nil
+		}
+	}
+	return types.Location{}, &client.StatusError{Status: client.StatusNotFound, Query: placeId}
+}
+
+type message struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func testMCP(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject   *mcp.Server
+		finder    *fakeFinder
+		places    *fakePlaces
+		received  []app.Options
+		locations = []types.Location{
+			{PlaceId: "a", Name: "Whole Foods Market", Rating: types.Ptr(4.6)},
+			{PlaceId: "b", Name: "Whole Foods Market Cafe", Rating: types.Ptr(3.9)},
+		}
+	)
+
+	serve := func(lines ...string) []message {
+		var out bytes.Buffer
+		Expect(subject.Serve(strings.NewReader(strings.Join(lines, "\n")), &out)).To(Succeed())
+
+		var result []message
+		decoder := json.NewDecoder(&out)
+		for decoder.More() {
+			var m message
+			Expect(decoder.Decode(&m)).To(Succeed())
+			result = append(result, m)
+		}
+		return result
+	}
+
+	call := func(name, arguments string) mcp.Result {
+		result, err := subject.Call(name, json.RawMessage(arguments))
+		Expect(err).NotTo(HaveOccurred())
+		return result
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+
+		received = nil
+		finder = &fakeFinder{locations: locations}
+		places = &fakePlaces{locations: locations}
+
+		factory := func(opts app.Options, log io.Writer) (app.Finder, error) {
+			received = append(received, opts)
+			return finder, nil
+		}
+
+		subject = mcp.New(app.Options{APIKey: "server-key", MaxResults: 50, Rounds: 2}, factory, places)
+	})
+
+	when("serving", func() {
+		it("answers the requests and ignores notifications", func() {
+			replies := serve(
+				`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "2024-11-05", "capabilities": {}}}`,
+				`{"jsonrpc": "2.0", "method": "notifications/initialized"}`,
+				``,
+				`{"jsonrpc": "2.0", "id": 2, "method": "tools/list"}`,
+				`{"jsonrpc": "2.0", "id": 3, "method": "tools/call", "params": {"name": "place_details", "arguments": {"place_id": "a"}}}`,
+			)
+			Expect(replies).To(HaveLen(3))
+
+			var init struct {
+				ProtocolVersion string `json:"protocolVersion"`
+				ServerInfo      struct {
+					Name string `json:"name"`
+				} `json:"serverInfo"`
+			}
+			Expect(json.Unmarshal(replies[0].Result, &init)).To(Succeed())
+			Expect(init.ProtocolVersion).To(Equal("2024-11-05"))
+			Expect(init.ServerInfo.Name).To(Equal(mcp.ServerName))
+
+			var list struct {
+				Tools []mcp.Tool `json:"tools"`
+			}
+			Expect(json.Unmarshal(replies[1].Result, &list)).To(Succeed())
+			Expect(list.Tools).To(Equal(mcp.Tools()))
+
+			Expect(replies[2].ID).To(Equal(3))
+			var details mcp.Result
+			Expect(json.Unmarshal(replies[2].Result, &details)).To(Succeed())
+			Expect(details.StructuredContent).To(HaveKeyWithValue("place", HaveKeyWithValue("place_id", "a")))
+		})
+
+		it("offers its newest protocol version to unknown versions", func() {
+			replies := serve(`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {"protocolVersion": "1999-01-01"}}`)
+			Expect(string(replies[0].Result)).To(ContainSubstring(`"protocolVersion":"` + mcp.ProtocolVersion + `"`))
+		})
+
+		it("reports protocol errors", func() {
+			replies := serve(
+				`not json`,
+				`{"jsonrpc": "2.0", "id": 1, "method": "resources/list"}`,
+				`{"jsonrpc": "2.0", "id": 2, "method": "tools/call", "params": {"name": "delete_places"}}`,
+				`{"id": 3, "method": "tools/list"}`,
+			)
+
+			Expect(replies).To(HaveLen(4))
+			Expect(replies[0].Error.Code).To(Equal(-32700))
+			Expect(replies[1].Error.Code).To(Equal(-32601))
+			Expect(replies[2].Error.Code).To(Equal(-32602))
+			Expect(replies[2].Error.Message).To(Equal("unknown tool: delete_places"))
+			Expect(replies[3].Error.Code).To(Equal(-32600))
+		})
+	})
+
+	it("describes the filter options in the schemas", func() {
+		tools := mcp.Tools()
+		Expect(tools).To(HaveLen(4))
+
+		search := tools[0].InputSchema
+		Expect(search.Required).To(Equal([]string{"query"}))
+		Expect(search.Properties).To(HaveKey("where"))
+		Expect(search.Properties).To(HaveKey("exclude_regex"))
+		Expect(search.Properties["format"].Enum).To(Equal([]string{"json", "ndjson", "csv"}))
+		Expect(search.Properties["exclude"].Items.Type).To(Equal("string"))
+
+		nearby := tools[1].InputSchema
+		Expect(nearby.Required).To(Equal([]string{"lat", "lng", "radius"}))
+		Expect(nearby.Properties).To(HaveKey("dedupe"))
+	})
+
+	when("searching places", func() {
+		it("applies the arguments to the options of the search", func() {
+			result := call(mcp.ToolSearchPlaces, `{"query": "Whole Foods in Ohio", "where": "rating >= 4", "matches": ["whole foods market"], "exclude": ["cafe"], "dedupe": true, "max_results": 20}`)
+			Expect(result.IsError).To(BeFalse())
+			Expect(result.StructuredContent).To(Equal(mcp.PlaceList{Count: 2, Places: locations}))
+
+			Expect(received).To(HaveLen(1))
+			Expect(received[0].Query).To(Equal("Whole Foods in Ohio"))
+			Expect(received[0].Where.String()).To(Equal("rating >= 4"))
+			Expect(received[0].Rules.Matches).To(Equal([]string{"whole foods market"}))
+			Expect(received[0].Rules.Exclude).To(Equal([]string{"cafe"}))
+			Expect(received[0].Dedupe).To(BeTrue())
+			Expect(received[0].MaxResults).To(Equal(20))
+			Expect(received[0].Rounds).To(Equal(2))
+		})
+
+		it("writes the text content in the requested format", func() {
+			result := call(mcp.ToolSearchPlaces, `{"query": "Whole Foods in Ohio", "format": "csv"}`)
+			Expect(result.Content).To(HaveLen(1))
+			Expect(result.Content[0].Text).To(HavePrefix("query,name,formatted_address,place_id"))
+		})
+
+		it("reports invalid arguments and failed searches as tool errors", func() {
+			for arguments, expected := range map[string]string{
+				`{}`:                                     "missing query",
+				`{"query": "Whole Foods", "where": "?"}`: "invalid where expression",
+				`{"query": "Whole Foods", "api_key": ""}`: `unknown field "api_key"`,
+				`{"query": "Whole Foods", "format": "x"}`: "unsupported output format",
+			} {
+				result := call(mcp.ToolSearchPlaces, arguments)
+				Expect(result.IsError).To(BeTrue(), arguments)
+				Expect(result.Content[0].Text).To(ContainSubstring(expected), arguments)
+			}
+
+			finder.err = errors.New("http status 500")
+			result := call(mcp.ToolSearchPlaces, `{"query": "Whole Foods"}`)
+			Expect(result.IsError).To(BeTrue())
+			Expect(result.Content[0].Text).To(Equal("http status 500"))
+		})
+	})
+
+	when("listing nearby places", func() {
+		it("filters the places by name and expression", func() {
+			result := call(mcp.ToolNearbyPlaces, `{"lat": 39.96, "lng": -83, "radius": 5000, "keyword": "whole foods", "exclude": ["cafe"], "where": "rating > 4"}`)
+
+			Expect(places.query).To(Equal(client.NearbyQuery{Lat: 39.96, Lng: -83, Radius: 5000, Keyword: "whole foods"}))
+			Expect(result.StructuredContent).To(Equal(mcp.PlaceList{Count: 1, Places: locations[:1]}))
+		})
+
+		it("requires a center", func() {
+			result := call(mcp.ToolNearbyPlaces, `{"radius": 5000}`)
+			Expect(result.IsError).To(BeTrue())
+			Expect(result.Content[0].Text).To(Equal("missing lat or lng"))
+		})
+	})
+
+	it("reports unknown places", func() {
+		result := call(mcp.ToolPlaceDetails, `{"place_id": "missing"}`)
+		Expect(result.IsError).To(BeTrue())
+		Expect(result.Content[0].Text).To(ContainSubstring("NOT_FOUND"))
+	})
+
+	it("compares result files", func() {
+		dir := t.TempDir()
+		previous := filepath.Join(dir, "previous.json")
+		current := filepath.Join(dir, "current.json")
+
+		Expect(os.WriteFile(previous, []byte(`[{"place_id": "a", "name": "Whole Foods Market"}]`), 0644)).To(Succeed())
+		Expect(os.WriteFile(current, []byte(`[{"place_id": "a", "name": "Whole Foods Market"}, {"place_id": "b", "name": "Trader Joe's"}]`), 0644)).To(Succeed())
+
+		result := call(mcp.ToolDiffResults, `{"previous": "`+previous+`", "current": "`+current+`"}`)
+		Expect(result.IsError).To(BeFalse())
+
+		var changes diff.Report
+		Expect(json.Unmarshal([]byte(result.Content[0].Text), &changes)).To(Succeed())
+		Expect(changes.Added).To(HaveLen(1))
+		Expect(changes.Added[0].PlaceId).To(Equal("b"))
+		Expect(changes.Removed).To(BeEmpty())
+	})
+}
@@ -0,0 +1,404 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/kardolus/maps/client"
+	"github.com/kardolus/maps/dedupe"
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	"strings"
+)
+
+const (
+	ToolSearchPlaces = "search_places"
+	ToolNearbyPlaces = "nearby_places"
+	ToolPlaceDetails = "place_details"
+	ToolDiffResults  = "diff_results"
+)
+
+// Tool describes a tool and the JSON schema of its arguments
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	InputSchema Schema `json:"inputSchema"`
+}
+
+type Schema struct {
+	Type                 string              `json:"type"`
+	Properties           map[string]Property `json:"properties"`
+	Required             []string            `json:"required,omitempty"`
+	AdditionalProperties bool                `json:"additionalProperties"`
+}
+
+type Property struct {
+	Type        string    `json:"type"`
+	Description string    `json:"description,omitempty"`
+	Enum        []string  `json:"enum,omitempty"`
+	Items       *Property `json:"items,omitempty"`
+	Minimum     *float64  `json:"minimum,omitempty"`
+	Maximum     *float64  `json:"maximum,omitempty"`
+}
+
+// Content is a single content item of a tool result
+type Content struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// Result is the result of a tool call. The structured content holds the same data as the text, as a JSON object.
+// Failed calls are results too, with IsError set, so the assistant sees what went wrong.
+type Result struct {
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError,omitempty"`
+}
+
+// PlaceList is the structured content of the tools that return places
+type PlaceList struct {
+	Count  int              `json:"count"`
+	Places []types.Location `json:"places"`
+}
+
+// filterArgs are the name rules, the where expression and the output format shared by the tools that return places
+type filterArgs struct {
+	Where        string   `json:"where"`
+	Contains     []string `json:"contains"`
+	Matches      []string `json:"matches"`
+	Exclude      []string `json:"exclude"`
+	NameRegex    []string `json:"name_regex"`
+	ExcludeRegex []string `json:"exclude_regex"`
+	Dedupe       bool     `json:"dedupe"`
+	Format       string   `json:"format"`
+}
+
+func (f filterArgs) rules() filter.Rules {
+	return filter.Rules{Contains: f.Contains, Matches: f.Matches, Exclude: f.Exclude, NameRegex: f.NameRegex, ExcludeRegex: f.ExcludeRegex}
+}
+
+func (f filterArgs) where() (*filter.Expression, error) {
+	if f.Where == "" {
+		return nil, nil
+	}
+
+	where, err := filter.Compile(f.Where)
+	if err != nil {
+		return nil, fmt.Errorf("invalid where expression: %w", err)
+	}
+	return where, nil
+}
+
+type searchArgs struct {
+	Query string `json:"query"`
+	filterArgs
+	Classify       bool `json:"classify"`
+	KeepIrrelevant bool `json:"keep_irrelevant"`
+	MaxResults     int  `json:"max_results"`
+	Rounds         int  `json:"rounds"`
+}
+
+type nearbyArgs struct {
+	Lat     *float64 `json:"lat"`
+	Lng     *float64 `json:"lng"`
+	Radius  float64  `json:"radius"`
+	Keyword string   `json:"keyword"`
+	Type    string   `json:"type"`
+	filterArgs
+}
+
+type detailsArgs struct {
+	PlaceId string `json:"place_id"`
+}
+
+type diffArgs struct {
+	Previous string   `json:"previous"`
+	Current  string   `json:"current"`
+	Radius   *float64 `json:"radius"`
+}
+
+// Tools lists the tools with the schemas of their arguments
+func Tools() []Tool {
+	return []Tool{
+		{
+			Name: ToolSearchPlaces,
+			Description: "Search places with a free-text query such as \"Whole Foods in Ohio\". Large areas are split " +
+				"into smaller searches until every place is found, so a search can take a while and make many requests.",
+			InputSchema: schema(withFilters(map[string]Property{
+				"query":           {Type: "string", Description: "What and where to search, e.g. \"Whole Foods in Ohio\""},
+				"classify":        {Type: "boolean", Description: "Ask the LLM whether every place is relevant to the query and drop the irrelevant ones"},
+				"keep_irrelevant": {Type: "boolean", Description: "With classify, keep the irrelevant places and only mark them"},
+				"max_results":     {Type: "integer", Description: "Maximum number of results a single sub-query should return; the LLM splits larger areas into more sub-queries", Minimum: number(1)},
+				"rounds":          {Type: "integer", Description: "Maximum number of planning rounds", Minimum: number(1)},
+			}), "query"),
+		},
+		{
+			Name:        ToolNearbyPlaces,
+			Description: "List the places within a radius of a point, optionally matching a keyword and a place type",
+			InputSchema: schema(withFilters(map[string]Property{
+				"lat":     {Type: "number", Description: "Latitude of the center", Minimum: number(-90), Maximum: number(90)},
+				"lng":     {Type: "number", Description: "Longitude of the center", Minimum: number(-180), Maximum: number(180)},
+				"radius":  {Type: "number", Description: "Radius in meters, at most 50000", Minimum: number(1), Maximum: number(50000)},
+				"keyword": {Type: "string", Description: "Term matched against the names, types and addresses of the places"},
+				"type":    {Type: "string", Description: "Place type, e.g. grocery_or_supermarket"},
+			}), "lat", "lng", "radius"),
+		},
+		{
+			Name:        ToolPlaceDetails,
+			Description: "Look up a single place by its place id",
+			InputSchema: schema(map[string]Property{
+				"place_id": {Type: "string", Description: "Place id as returned by the other tools"},
+			}, "place_id"),
+		},
+		{
+			Name: ToolDiffResults,
+			Description: "Compare two result files of the same search and report the places that were added, removed " +
+				"or changed. Places are matched by place id, then by name and proximity.",
+			InputSchema: schema(map[string]Property{
+				"previous": {Type: "string", Description: "Path of the older JSON, NDJSON or CSV result file"},
+				"current":  {Type: "string", Description: "Path of the newer JSON, NDJSON or CSV result file"},
+				"radius":   {Type: "number", Description: fmt.Sprintf("Distance in meters within which places with the same name but a different place id are matched, %g by default, 0 to disable", diff.DefaultRadius), Minimum: number(0)},
+			}, "previous", "current"),
+		},
+	}
+}
+
+func schema(properties map[string]Property, required ...string) Schema {
+	return Schema{Type: "object", Properties: properties, Required: required}
+}
+
+func withFilters(properties map[string]Property) map[string]Property {
+	list := func(description string) Property {
+		return Property{Type: "array", Description: description, Items: &Property{Type: "string"}}
+	}
+
+	formats := make([]string, 0, len(output.Formats))
+	for _, format := range output.Formats {
+		formats = append(formats, string(format))
+	}
+
+	properties["where"] = Property{Type: "string", Description: "Keep the places matching the expression, e.g. \"rating >= 4.2 && user_ratings_total > 50\""}
+	properties["contains"] = list("Keep the places whose name contains any of these terms")
+	properties["matches"] = list("Keep the places whose name is exactly one of these names, ignoring case")
+	properties["exclude"] = list("Drop the places whose name contains any of these terms")
+	properties["name_regex"] = list("Keep the places whose name matches any of these regular expressions")
+	properties["exclude_regex"] = list("Drop the places whose name matches any of these regular expressions")
+	properties["dedupe"] = Property{Type: "boolean", Description: "Merge nearby places with similar names"}
+	properties["format"] = Property{Type: "string", Description: "Format of the text content, json by default", Enum: formats}
+
+	return properties
+}
+
+func number(n float64) *float64 {
+	return &n
+}
+
+// Call runs a tool. Invalid arguments and failed searches are reported in the result; only unknown tools are errors.
+func (s *Server) Call(name string, arguments json.RawMessage) (Result, error) {
+	var (
+		result Result
+		err    error
+	)
+
+	switch name {
+	case ToolSearchPlaces:
+		var args searchArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = s.search(args)
+		}
+	case ToolNearbyPlaces:
+		var args nearbyArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = s.nearby(args)
+		}
+	case ToolPlaceDetails:
+		var args detailsArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = s.details(args)
+		}
+	case ToolDiffResults:
+		var args diffArgs
+		if err = decode(arguments, &args); err == nil {
+			result, err = s.diff(args)
+		}
+	default:
+		return Result{}, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool: %s", name)}
+	}
+
+	if err != nil {
+		fmt.Fprintf(s.log, "Tool %s failed: %s\n", name, err)
+		return Result{Content: []Content{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+
+	return result, nil
+}
+
+func decode(arguments json.RawMessage, v interface{}) error {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(arguments))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) search(args searchArgs) (Result, error) {
+	query := strings.TrimSpace(args.Query)
+	if query == "" {
+		return Result{}, fmt.Errorf("missing query")
+	}
+
+	format, err := output.ParseFormat(args.Format)
+	if err != nil {
+		return Result{}, err
+	}
+
+	opts, err := s.base.ForQuery(query)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if opts.Where, err = args.where(); err != nil {
+		return Result{}, err
+	}
+
+	opts.Rules = opts.Rules.Merge(args.rules())
+	if _, err := opts.Rules.Compile(); err != nil {
+		return Result{}, err
+	}
+
+	opts.Classify = args.Classify
+	opts.KeepIrrelevant = args.KeepIrrelevant
+	opts.Dedupe = args.Dedupe
+
+	if args.MaxResults > 0 {
+		opts.MaxResults = args.MaxResults
+	}
+	if args.Rounds > 0 {
+		opts.Rounds = args.Rounds
+	}
+
+	finder, err := s.factory(opts, s.log)
+	if err != nil {
+		return Result{}, err
+	}
+
+	locations, _, err := finder.Find(opts)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return placeList(query, locations, format)
+}
+
+func (s *Server) nearby(args nearbyArgs) (Result, error) {
+	if args.Lat == nil || args.Lng == nil {
+		return Result{}, fmt.Errorf("missing lat or lng")
+	}
+
+	format, err := output.ParseFormat(args.Format)
+	if err != nil {
+		return Result{}, err
+	}
+
+	where, err := args.where()
+	if err != nil {
+		return Result{}, err
+	}
+
+	names, err := args.rules().Compile()
+	if err != nil {
+		return Result{}, err
+	}
+
+	query := client.NearbyQuery{Lat: *args.Lat, Lng: *args.Lng, Radius: args.Radius, Keyword: args.Keyword, Type: args.Type}
+	fmt.Fprintf(s.log, "Fetching places %s\n", query)
+
+	locations, _, err := s.places.Nearby(query, names)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if where != nil {
+		locations = where.Filter(locations)
+	}
+
+	if args.Dedupe {
+		locations, _ = dedupe.New().
+			WithRadius(s.base.DedupeRadius).
+			WithSimilarity(s.base.DedupeSimilarity).
+			Dedupe(locations)
+	}
+
+	return placeList("", locations, format)
+}
+
+func (s *Server) details(args detailsArgs) (Result, error) {
+	location, err := s.places.Details(args.PlaceId)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return structured(map[string]types.Location{"place": location})
+}
+
+func (s *Server) diff(args diffArgs) (Result, error) {
+	if args.Previous == "" || args.Current == "" {
+		return Result{}, fmt.Errorf("missing previous or current result file")
+	}
+
+	previous, err := output.ReadFile(args.Previous)
+	if err != nil {
+		return Result{}, err
+	}
+
+	current, err := output.ReadFile(args.Current)
+	if err != nil {
+		return Result{}, err
+	}
+
+	differ := diff.New()
+	if args.Radius != nil {
+		differ.WithRadius(*args.Radius)
+	}
+
+	return structured(differ.Compare(output.Locations(previous), output.Locations(current)))
+}
+
+// placeList returns the places as structured content and as text in the requested format
+func placeList(query string, locations []types.Location, format output.Format) (Result, error) {
+	if locations == nil {
+		locations = []types.Location{}
+	}
+
+	if format == "" || format == output.FormatJSON {
+		return structured(PlaceList{Count: len(locations), Places: locations})
+	}
+
+	var buf bytes.Buffer
+	if err := output.Encode(&buf, format, output.Rows(query, locations)); err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Content:           []Content{{Type: "text", Text: buf.String()}},
+		StructuredContent: PlaceList{Count: len(locations), Places: locations},
+	}, nil
+}
+
+// structured returns v as structured content, with its JSON encoding as text for clients without structured content
+func structured(v interface{}) (Result, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Content: []Content{{Type: "text", Text: string(data)}}, StructuredContent: v}, nil
+}
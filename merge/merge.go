@@ -0,0 +1,180 @@
+// Package merge combines the result files of overlapping searches into a single set of unique places.
+package merge
+
+import (
+	"fmt"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	"os"
+	"time"
+)
+
+const (
+	PreferNewest   = "newest"
+	PreferComplete = "complete"
+)
+
+// Input is the content of a result file
+type Input struct {
+	File     string
+	Modified time.Time
+	Rows     []output.Row
+}
+
+// ReadInput reads a result file in any of the supported formats, using its modification time to tell which records
+// are the newest
+func ReadInput(path string) (Input, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Input{}, err
+	}
+
+	rows, err := output.ReadFile(path)
+	if err != nil {
+		return Input{}, err
+	}
+
+	return Input{File: path, Modified: info.ModTime(), Rows: rows}, nil
+}
+
+// ParsePreference validates the name of a conflict resolution strategy
+func ParsePreference(name string) (string, error) {
+	switch name {
+	case PreferNewest, PreferComplete:
+		return name, nil
+	default:
+		return "", fmt.Errorf("unsupported preference %q, use %s or %s", name, PreferNewest, PreferComplete)
+	}
+}
+
+type Merger struct {
+	prefer string
+}
+
+func New() *Merger {
+	return &Merger{prefer: PreferNewest}
+}
+
+// WithPreference configures which record is kept when the same place is found more than once. PreferNewest keeps the
+// record of the most recently modified file, PreferComplete the record with the most fields set. The other criterion
+// breaks ties, after which the record of the file listed last wins.
+func (m *Merger) WithPreference(prefer string) *Merger {
+	m.prefer = prefer
+	return m
+}
+
+type candidate struct {
+	row      output.Row
+	input    int
+	modified time.Time
+	score    int
+}
+
+// Merge deduplicates the rows of all inputs by place id, in the order the places were first seen. Every row lists the
+// files and queries the place was found in. Rows without a place id cannot be matched and are kept as they are.
+func (m *Merger) Merge(inputs []Input) []output.Row {
+	var result []output.Row
+
+	position := make(map[string]int)
+	best := make(map[string]*candidate)
+	sources := make(map[string][]output.Source)
+
+	for i, input := range inputs {
+		for _, row := range input.Rows {
+			provenance := row.Sources
+			if len(provenance) == 0 {
+				provenance = []output.Source{{File: input.File, Query: row.Query}}
+			}
+
+			id := row.Location.PlaceId
+			if id == "" {
+				row.Sources = provenance
+				result = append(result, row)
+				continue
+			}
+
+			sources[id] = appendUnique(sources[id], provenance...)
+
+			next := &candidate{row: row, input: i, modified: input.Modified, score: Completeness(row.Location)}
+			current, ok := best[id]
+			if !ok {
+				position[id] = len(result)
+				result = append(result, row)
+			}
+			if !ok || m.prefers(next, current) {
+				best[id] = next
+			}
+		}
+	}
+
+	for id, c := range best {
+		row := c.row
+		row.Sources = sources[id]
+		result[position[id]] = row
+	}
+
+	return result
+}
+
+// prefers reports whether the next record should replace the current one
+func (m *Merger) prefers(next, current *candidate) bool {
+	newer := next.modified.Compare(current.modified)
+	richer := next.score - current.score
+
+	first, second := newer, richer
+	if m.prefer == PreferComplete {
+		first, second = richer, newer
+	}
+
+	switch {
+	case first != 0:
+		return first > 0
+	case second != 0:
+		return second > 0
+	default:
+		return next.input >= current.input
+	}
+}
+
+// Completeness counts the fields of the location that are set
+func Completeness(l types.Location) int {
+	score := 0
+	for _, set := range []bool{
+		l.Name != "",
+		l.FormattedAddress != "",
+		l.BusinessStatus != "",
+		l.Geometry.Location.Lat != 0 || l.Geometry.Location.Lng != 0,
+		l.Rating != nil,
+		l.UserRatingsTotal != nil,
+		l.PriceLevel != nil,
+		l.OpeningHours.OpenNow != nil,
+		len(l.Types) > 0,
+		l.Vicinity != "",
+		len(l.OpeningHours.WeekdayText) > 0,
+		len(l.Photos) > 0,
+		l.PlusCode.GlobalCode != "",
+		l.Icon != "",
+		l.Relevance != nil,
+	} {
+		if set {
+			score++
+		}
+	}
+	return score
+}
+
+func appendUnique(sources []output.Source, more ...output.Source) []output.Source {
+	for _, source := range more {
+		found := false
+		for _, existing := range sources {
+			if existing == source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
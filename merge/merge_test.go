@@ -0,0 +1,133 @@
+package merge_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kardolus/maps/merge"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitMerge(t *testing.T) {
+	spec.Run(t, "Merge Unit Tests", testMerge, spec.Report(report.Terminal{}))
+}
+
+func testMerge(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject   *merge.Merger
+		yesterday = time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+		today     = yesterday.AddDate(0, 0, 1)
+		sparse    = types.Location{PlaceId: "a", Name: "Whole Foods Market"}
+		complete  = types.Location{PlaceId: "a", Name: "Whole Foods Market", FormattedAddress: "Columbus", Rating: types.Ptr(4.5)}
+		other     = types.Location{PlaceId: "b", Name: "Trader Joe's"}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		subject = merge.New()
+	})
+
+	it("deduplicates by place id and records where every place was found", func() {
+		result := subject.Merge([]merge.Input{
+			{File: "ohio.json", Modified: yesterday, Rows: output.Rows("Whole Foods in Ohio", []types.Location{sparse, other})},
+			{File: "usa.csv", Modified: yesterday, Rows: output.Rows("Whole Foods in USA", []types.Location{sparse})},
+		})
+
+		Expect(result).To(HaveLen(2))
+		Expect(result[0].Location.PlaceId).To(Equal("a"))
+		Expect(result[0].Sources).To(Equal([]output.Source{
+			{File: "ohio.json", Query: "Whole Foods in Ohio"},
+			{File: "usa.csv", Query: "Whole Foods in USA"},
+		}))
+		Expect(result[1].Location.PlaceId).To(Equal("b"))
+		Expect(result[1].Sources).To(Equal([]output.Source{{File: "ohio.json", Query: "Whole Foods in Ohio"}}))
+	})
+
+	it("prefers the record of the newest file by default", func() {
+		result := subject.Merge([]merge.Input{
+			{File: "new.json", Modified: today, Rows: output.Rows("", []types.Location{sparse})},
+			{File: "old.json", Modified: yesterday, Rows: output.Rows("", []types.Location{complete})},
+		})
+
+		Expect(result).To(HaveLen(1))
+		Expect(result[0].Location).To(Equal(sparse))
+	})
+
+	it("prefers the most complete record when configured", func() {
+		subject.WithPreference(merge.PreferComplete)
+
+		result := subject.Merge([]merge.Input{
+			{File: "new.json", Modified: today, Rows: output.Rows("", []types.Location{sparse})},
+			{File: "old.json", Modified: yesterday, Rows: output.Rows("", []types.Location{complete})},
+		})
+
+		Expect(result[0].Location).To(Equal(complete))
+	})
+
+	it("breaks ties with completeness, then with the order of the files", func() {
+		result := subject.Merge([]merge.Input{
+			{File: "a.json", Modified: today, Rows: output.Rows("", []types.Location{complete})},
+			{File: "b.json", Modified: today, Rows: output.Rows("", []types.Location{sparse})},
+		})
+		Expect(result[0].Location).To(Equal(complete))
+
+		renamed := complete
+		renamed.Name = "Whole Foods"
+
+		result = subject.Merge([]merge.Input{
+			{File: "a.json", Modified: today, Rows: output.Rows("", []types.Location{complete})},
+			{File: "b.json", Modified: today, Rows: output.Rows("", []types.Location{renamed})},
+		})
+		Expect(result[0].Location).To(Equal(renamed))
+	})
+
+	it("counts a reported open_now as complete whether the place is open or closed", func() {
+		open, closed := complete, complete
+		open.OpeningHours.OpenNow = types.Ptr(true)
+		closed.OpeningHours.OpenNow = types.Ptr(false)
+
+		Expect(merge.Completeness(closed)).To(Equal(merge.Completeness(open)))
+		Expect(merge.Completeness(closed)).To(Equal(merge.Completeness(complete) + 1))
+	})
+
+	it("keeps the sources of files that were merged before", func() {
+		merged := output.Row{Location: sparse, Sources: []output.Source{{File: "ohio.json", Query: "Whole Foods in Ohio"}}}
+
+		result := subject.Merge([]merge.Input{
+			{File: "all.json", Rows: []output.Row{merged}},
+			{File: "ohio.json", Rows: output.Rows("Whole Foods in Ohio", []types.Location{sparse})},
+		})
+
+		Expect(result[0].Sources).To(Equal(merged.Sources))
+	})
+
+	it("keeps records without a place id", func() {
+		result := subject.Merge([]merge.Input{
+			{File: "a.json", Rows: output.Rows("", []types.Location{{Name: "Unknown"}, {Name: "Unknown"}})},
+		})
+
+		Expect(result).To(HaveLen(2))
+	})
+
+	it("validates preferences", func() {
+		_, err := merge.ParsePreference("oldest")
+		Expect(err).To(MatchError(ContainSubstring(`unsupported preference "oldest"`)))
+	})
+
+	it("reads the modification time of the file", func() {
+		path := filepath.Join(t.TempDir(), "results.ndjson")
+		Expect(os.WriteFile(path, []byte(`{"place_id": "a"}`+"\n"), 0644)).To(Succeed())
+		Expect(os.Chtimes(path, today, today)).To(Succeed())
+
+		input, err := merge.ReadInput(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(input.Modified.Equal(today)).To(BeTrue())
+		Expect(output.Locations(input.Rows)).To(Equal([]types.Location{{PlaceId: "a"}}))
+	})
+}
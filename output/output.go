@@ -0,0 +1,494 @@
+// Package output writes search results to stdout or files, as JSON, newline delimited JSON or CSV.
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/kardolus/maps/types"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// Formats lists the supported formats
+var Formats = []Format{FormatJSON, FormatNDJSON, FormatCSV}
+
+var csvHeader = []string{
+	"name",
+	"formatted_address",
+	"place_id",
+	"business_status",
+	"lat",
+	"lng",
+	"rating",
+	"user_ratings_total",
+	"price_level",
+	"open_now",
+	"types",
+}
+
+// addressHeader are the columns of the parsed address, added when any row has one
+var addressHeader = []string{
+	"street",
+	"city",
+	"region",
+	"postal_code",
+	"country",
+	"address_confidence",
+}
+
+// provenanceHeader are the columns of the provenance of a location, added when any location has one
+var provenanceHeader = []string{
+	"provenance_query",
+	"provenance_page",
+	"fetched_at",
+	"provider",
+	"provenance_rule",
+}
+
+// relevanceHeader are the columns of the relevance verdict, added when any location has one
+var relevanceHeader = []string{
+	"relevant",
+	"relevance_reason",
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+type Writer interface {
+	Write(locations []types.Location) error
+}
+
+// Row is a location together with the query that found it. Writers add a query column when any row has a query and
+// a sources column when any row has sources. CSV writers add the address columns when any location has a parsed
+// address, the relevance columns when any location has a verdict and an aliases column when any location has aliases.
+// The provenance of the locations is added when any
+// location has one.
+type Row struct {
+	Query    string
+	Location types.Location
+	Sources  []Source
+}
+
+// Source records a file and query a merged location was found in
+type Source struct {
+	File  string `json:"file"`
+	Query string `json:"query,omitempty"`
+}
+
+func (s Source) String() string {
+	if s.Query == "" {
+		return s.File
+	}
+	return fmt.Sprintf("%s (%s)", s.File, s.Query)
+}
+
+// FormatFor picks the format from the file extension, defaulting to JSON
+func FormatFor(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	default:
+		return FormatJSON
+	}
+}
+
+// ParseFormat validates a format name. An empty name is returned as is and means "pick by file extension".
+func ParseFormat(name string) (Format, error) {
+	if name == "" {
+		return "", nil
+	}
+
+	for _, format := range Formats {
+		if strings.EqualFold(name, string(format)) {
+			return format, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported output format %q, use one of %s, %s or %s", name, FormatJSON, FormatNDJSON, FormatCSV)
+}
+
+// Select returns a writer for the output flags: the file when path is set, stdout otherwise. Without a format, files
+// get the format matching their extension and stdout gets JSON.
+func Select(path string, format Format, stdout io.Writer) Writer {
+	if path == "" {
+		return NewStream(stdout, format)
+	}
+
+	return NewFile(path).WithFormat(format)
+}
+
+// Stream writes the results to an io.Writer
+type Stream struct {
+	out    io.Writer
+	format Format
+}
+
+// NewStream writes to out in the given format, JSON when empty
+func NewStream(out io.Writer, format Format) *Stream {
+	if format == "" {
+		format = FormatJSON
+	}
+	return &Stream{out: out, format: format}
+}
+
+func (s *Stream) Write(locations []types.Location) error {
+	return s.WriteRows(Rows("", locations))
+}
+
+func (s *Stream) WriteRows(rows []Row) error {
+	return Encode(s.out, s.format, rows)
+}
+
+// File replaces the content of a file with the results, in the format matching its extension unless configured
+type File struct {
+	path   string
+	format Format
+}
+
+func NewFile(path string) *File {
+	return &File{path: path, format: FormatFor(path)}
+}
+
+// WithFormat overrides the format picked by extension. An empty format is ignored.
+func (f *File) WithFormat(format Format) *File {
+	if format != "" {
+		f.format = format
+	}
+	return f
+}
+
+func (f *File) Path() string {
+	return f.path
+}
+
+func (f *File) Write(locations []types.Location) error {
+	return f.WriteRows(Rows("", locations))
+}
+
+func (f *File) WriteRows(rows []Row) error {
+	var buf bytes.Buffer
+	if err := Encode(&buf, f.format, rows); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(f.path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write to file: %w", err)
+	}
+
+	return nil
+}
+
+// Dir writes one file per query into a directory
+type Dir struct {
+	path   string
+	format Format
+}
+
+func NewDir(path string, format Format) *Dir {
+	return &Dir{path: path, format: format}
+}
+
+// For returns the file for the query, named after it, e.g. "whole-foods-in-ohio.json"
+func (d *Dir) For(query string) *File {
+	name := strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(query), "-"), "-")
+	if name == "" {
+		name = "query"
+	}
+
+	return NewFile(filepath.Join(d.path, name+"."+string(d.format)))
+}
+
+// Create makes sure the directory exists
+func (d *Dir) Create() error {
+	return os.MkdirAll(d.path, 0755)
+}
+
+// Rows pairs every location with the query
+func Rows(query string, locations []types.Location) []Row {
+	result := make([]Row, 0, len(locations))
+	for _, location := range locations {
+		result = append(result, Row{Query: query, Location: location})
+	}
+	return result
+}
+
+// Encode writes the rows in the given format
+func Encode(w io.Writer, format Format, rows []Row) error {
+	switch format {
+	case FormatCSV:
+		return encodeCSV(w, rows)
+	case FormatJSON:
+		return encodeJSON(w, rows)
+	case FormatNDJSON:
+		return encodeNDJSON(w, rows)
+	default:
+		return fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+func encodeJSON(w io.Writer, rows []Row) error {
+	records, err := marshalRows(rows)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal locations: %w", err)
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// encodeNDJSON writes one compact JSON object per line, so consumers can process the results line by line
+func encodeNDJSON(w io.Writer, rows []Row) error {
+	records, err := marshalRows(rows)
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if _, err := fmt.Fprintln(w, string(record)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// marshalRows encodes the locations, adding a leading query field when any row has a query, a provenance field when any
+// location has one and a trailing sources field when any row has sources
+func marshalRows(rows []Row) ([]json.RawMessage, error) {
+	withQuery := hasQuery(rows)
+	withSources := hasSources(rows)
+	withProvenance := hasProvenance(rows)
+
+	records := make([]json.RawMessage, 0, len(rows))
+	for _, row := range rows {
+		data, err := json.Marshal(row.Location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal locations: %w", err)
+		}
+
+		if withQuery {
+			query, _ := json.Marshal(row.Query)
+			data = append([]byte(`{"query":`+string(query)+","), data[1:]...)
+		}
+
+		if withProvenance {
+			provenance, err := json.Marshal(row.Location.Provenance)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal provenance: %w", err)
+			}
+			data = append(data[:len(data)-1], []byte(`,"provenance":`+string(provenance)+"}")...)
+		}
+
+		if withSources {
+			if row.Sources == nil {
+				row.Sources = []Source{}
+			}
+
+			sources, err := json.Marshal(row.Sources)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal sources: %w", err)
+			}
+			data = append(data[:len(data)-1], []byte(`,"sources":`+string(sources)+"}")...)
+		}
+
+		records = append(records, data)
+	}
+
+	return records, nil
+}
+
+func encodeCSV(w io.Writer, rows []Row) error {
+	withQuery := hasQuery(rows)
+	withSources := hasSources(rows)
+	withAddress := hasAddress(rows)
+	withProvenance := hasProvenance(rows)
+	withRelevance := hasRelevance(rows)
+	withAliases := hasAliases(rows)
+
+	header := csvHeader
+	if withQuery {
+		header = append([]string{"query"}, header...)
+	}
+	if withAddress {
+		header = append(header[:len(header):len(header)], addressHeader...)
+	}
+	if withProvenance {
+		header = append(header[:len(header):len(header)], provenanceHeader...)
+	}
+	if withRelevance {
+		header = append(header[:len(header):len(header)], relevanceHeader...)
+	}
+	if withAliases {
+		header = append(header[:len(header):len(header)], "aliases")
+	}
+	if withSources {
+		header = append(header[:len(header):len(header)], "sources")
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		l := row.Location
+		record := []string{
+			l.Name,
+			l.FormattedAddress,
+			l.PlaceId,
+			string(l.BusinessStatus),
+			strconv.FormatFloat(l.Geometry.Location.Lat, 'f', -1, 64),
+			strconv.FormatFloat(l.Geometry.Location.Lng, 'f', -1, 64),
+			formatFloat(l.Rating),
+			formatInt(l.UserRatingsTotal),
+			formatInt(l.PriceLevel),
+			formatBool(l.OpeningHours.OpenNow),
+			strings.Join(l.Types.Strings(), ";"),
+		}
+
+		if withQuery {
+			record = append([]string{row.Query}, record...)
+		}
+
+		if withAddress {
+			if a := l.Address; a != nil {
+				record = append(record, a.Street, a.City, a.Region, a.PostalCode, a.Country,
+					strconv.FormatFloat(a.Confidence, 'f', -1, 64))
+			} else {
+				record = append(record, make([]string, len(addressHeader))...)
+			}
+		}
+
+		if withProvenance {
+			if p := l.Provenance; p != nil {
+				record = append(record, p.Query, strconv.Itoa(p.Page), p.FetchedAt.Format(time.RFC3339), p.Provider, p.Rule)
+			} else {
+				record = append(record, make([]string, len(provenanceHeader))...)
+			}
+		}
+
+		if withRelevance {
+			if v := l.Relevance; v != nil {
+				record = append(record, strconv.FormatBool(v.Relevant), v.Reason)
+			} else {
+				record = append(record, make([]string, len(relevanceHeader))...)
+			}
+		}
+
+		if withAliases {
+			record = append(record, strings.Join(l.Aliases, ";"))
+		}
+
+		if withSources {
+			var sources []string
+			for _, source := range row.Sources {
+				sources = append(sources, source.String())
+			}
+			record = append(record, strings.Join(sources, ";"))
+		}
+
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatFloat leaves the cell empty when the Places API did not return the number
+func formatFloat(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
+
+func formatInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func formatBool(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
+
+func hasQuery(rows []Row) bool {
+	for _, row := range rows {
+		if row.Query != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAddress(rows []Row) bool {
+	for _, row := range rows {
+		if row.Location.Address != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasProvenance(rows []Row) bool {
+	for _, row := range rows {
+		if row.Location.Provenance != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasRelevance(rows []Row) bool {
+	for _, row := range rows {
+		if row.Location.Relevance != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAliases(rows []Row) bool {
+	for _, row := range rows {
+		if len(row.Location.Aliases) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSources(rows []Row) bool {
+	for _, row := range rows {
+		if len(row.Sources) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package output_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitOutput(t *testing.T) {
+	spec.Run(t, "Output Package Unit Tests", testOutput, spec.Report(report.Terminal{}))
+}
+
+func testOutput(t *testing.T, when spec.G, it spec.S) {
+	var (
+		stdout    *bytes.Buffer
+		locations = []types.Location{{PlaceId: "a", Name: "Whole Foods Market"}}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		stdout = &bytes.Buffer{}
+	})
+
+	when("no path is given", func() {
+		it("writes indented JSON to stdout", func() {
+			Expect(output.Select("", "", stdout).Write(locations)).To(Succeed())
+			Expect(stdout.String()).To(HavePrefix("[\n  {\n"))
+			Expect(stdout.String()).To(ContainSubstring(`"name": "Whole Foods Market"`))
+			Expect(stdout.String()).To(HaveSuffix("]\n"))
+		})
+
+		it("writes an empty array rather than null", func() {
+			Expect(output.Select("", "", stdout).Write(nil)).To(Succeed())
+			Expect(stdout.String()).To(Equal("[]\n"))
+		})
+	})
+
+	when("rows carry a query", func() {
+		rows := []output.Row{
+			{Query: "Whole Foods in Ohio", Location: types.Location{PlaceId: "a", Name: "Whole Foods Market", Types: types.PlaceTypes{"store", "food"}}},
+			{Query: "Trader Joe's in Ohio", Location: types.Location{PlaceId: "b", Name: "Trader Joe's, Columbus"}},
+		}
+
+		it("adds a query field to every JSON record", func() {
+			Expect(output.NewStream(stdout, output.FormatJSON).WriteRows(rows)).To(Succeed())
+
+			var records []map[string]interface{}
+			Expect(json.Unmarshal(stdout.Bytes(), &records)).To(Succeed())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0]["query"]).To(Equal("Whole Foods in Ohio"))
+			Expect(records[0]["place_id"]).To(Equal("a"))
+			Expect(records[1]["query"]).To(Equal("Trader Joe's in Ohio"))
+		})
+
+		it("adds a query column to the CSV", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).WriteRows(rows)).To(Succeed())
+
+			records, err := csv.NewReader(stdout).ReadAll()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(3))
+			Expect(records[0][:3]).To(Equal([]string{"query", "name", "formatted_address"}))
+			Expect(records[1][0]).To(Equal("Whole Foods in Ohio"))
+			Expect(records[1][len(records[1])-1]).To(Equal("store;food"))
+			Expect(records[2][1]).To(Equal("Trader Joe's, Columbus"))
+		})
+	})
+
+	when("rows carry sources", func() {
+		rows := []output.Row{
+			{Location: types.Location{PlaceId: "a"}, Sources: []output.Source{{File: "ohio.json", Query: "Whole Foods in Ohio"}, {File: "usa.csv"}}},
+			{Location: types.Location{PlaceId: "b"}},
+		}
+
+		it("adds a sources field to every JSON record", func() {
+			Expect(output.NewStream(stdout, output.FormatNDJSON).WriteRows(rows)).To(Succeed())
+
+			lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+			Expect(lines).To(HaveLen(2))
+			Expect(lines[0]).To(HaveSuffix(`,"sources":[{"file":"ohio.json","query":"Whole Foods in Ohio"},{"file":"usa.csv"}]}`))
+			Expect(lines[1]).To(HaveSuffix(`,"sources":[]}`))
+		})
+
+		it("adds a sources column to the CSV", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).WriteRows(rows)).To(Succeed())
+
+			records, err := csv.NewReader(stdout).ReadAll()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0][len(records[0])-1]).To(Equal("sources"))
+			Expect(records[1][len(records[1])-1]).To(Equal("ohio.json (Whole Foods in Ohio);usa.csv"))
+		})
+	})
+
+	when("locations carry a parsed address", func() {
+		parsed := types.Location{PlaceId: "a", FormattedAddress: "1555 W Lane Ave, Columbus, OH 43221, United States",
+			Address: &types.Address{Street: "1555 W Lane Ave", City: "Columbus", Region: "OH", PostalCode: "43221", Country: "United States", Confidence: 1}}
+
+		it("adds an address field to the JSON records", func() {
+			Expect(output.NewStream(stdout, output.FormatNDJSON).Write([]types.Location{parsed})).To(Succeed())
+			Expect(stdout.String()).To(ContainSubstring(`"address":{"street":"1555 W Lane Ave","city":"Columbus","region":"OH","postal_code":"43221","country":"United States","confidence":1}`))
+		})
+
+		it("adds the address columns to the CSV", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).Write([]types.Location{parsed, {PlaceId: "b"}})).To(Succeed())
+
+			records, err := csv.NewReader(stdout).ReadAll()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0][len(records[0])-6:]).To(Equal([]string{"street", "city", "region", "postal_code", "country", "address_confidence"}))
+			Expect(records[1][len(records[1])-6:]).To(Equal([]string{"1555 W Lane Ave", "Columbus", "OH", "43221", "United States", "1"}))
+			Expect(records[2][len(records[2])-6:]).To(Equal([]string{"", "", "", "", "", ""}))
+		})
+
+		it("leaves the address columns out when no location has an address", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).Write([]types.Location{{PlaceId: "b"}})).To(Succeed())
+			Expect(stdout.String()).NotTo(ContainSubstring("address_confidence"))
+		})
+	})
+
+	when("locations carry their provenance", func() {
+		fetchedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+		traced := types.Location{PlaceId: "a", Provenance: &types.Provenance{Query: "Whole Foods in Ohio", Page: 2,
+			FetchedAt: fetchedAt, Provider: "google_places", Rule: "contains"}}
+
+		it("adds a provenance field to the JSON records", func() {
+			Expect(output.NewStream(stdout, output.FormatNDJSON).Write([]types.Location{traced, {PlaceId: "b"}})).To(Succeed())
+
+			lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			Expect(lines[0]).To(HaveSuffix(`"provenance":{"query":"Whole Foods in Ohio","page":2,"fetched_at":"2024-03-01T12:30:00Z","provider":"google_places","rule":"contains"}}`))
+			Expect(lines[1]).To(HaveSuffix(`"provenance":null}`))
+		})
+
+		it("adds the provenance columns to the CSV", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).Write([]types.Location{traced, {PlaceId: "b"}})).To(Succeed())
+
+			records, err := csv.NewReader(stdout).ReadAll()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0][len(records[0])-5:]).To(Equal([]string{"provenance_query", "provenance_page", "fetched_at", "provider", "provenance_rule"}))
+			Expect(records[1][len(records[1])-5:]).To(Equal([]string{"Whole Foods in Ohio", "2", "2024-03-01T12:30:00Z", "google_places", "contains"}))
+			Expect(records[2][len(records[2])-5:]).To(Equal([]string{"", "", "", "", ""}))
+		})
+
+		it("leaves the provenance out when no location has one", func() {
+			Expect(output.NewStream(stdout, output.FormatNDJSON).Write(locations)).To(Succeed())
+			Expect(stdout.String()).NotTo(ContainSubstring("provenance"))
+		})
+	})
+
+	when("locations carry a relevance verdict", func() {
+		judged := types.Location{PlaceId: "a", Relevance: &types.Verdict{Relevant: false, Reason: "a cafe, not a grocery store"}}
+
+		it("adds the relevance columns to the CSV", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).Write([]types.Location{judged, {PlaceId: "b"}})).To(Succeed())
+
+			records, err := csv.NewReader(stdout).ReadAll()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0][len(records[0])-2:]).To(Equal([]string{"relevant", "relevance_reason"}))
+			Expect(records[1][len(records[1])-2:]).To(Equal([]string{"false", "a cafe, not a grocery store"}))
+			Expect(records[2][len(records[2])-2:]).To(Equal([]string{"", ""}))
+		})
+
+		it("leaves the relevance columns out when no location has a verdict", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).Write(locations)).To(Succeed())
+			Expect(stdout.String()).NotTo(ContainSubstring("relevant"))
+		})
+	})
+
+	when("locations carry aliases", func() {
+		merged := types.Location{PlaceId: "a", Aliases: []string{"b", "c"}}
+
+		it("adds an aliases column to the CSV", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).Write([]types.Location{merged, {PlaceId: "d"}})).To(Succeed())
+
+			records, err := csv.NewReader(stdout).ReadAll()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records[0][len(records[0])-1]).To(Equal("aliases"))
+			Expect(records[1][len(records[1])-1]).To(Equal("b;c"))
+			Expect(records[2][len(records[2])-1]).To(BeEmpty())
+		})
+
+		it("leaves the aliases column out when no location has aliases", func() {
+			Expect(output.NewStream(stdout, output.FormatCSV).Write(locations)).To(Succeed())
+			Expect(stdout.String()).NotTo(ContainSubstring("aliases"))
+		})
+	})
+
+	it("writes CSV without a query column for plain results", func() {
+		Expect(output.NewStream(stdout, output.FormatCSV).Write(locations)).To(Succeed())
+		Expect(stdout.String()).To(HavePrefix("name,formatted_address,place_id,"))
+	})
+
+	it("leaves open_now empty in the CSV when it is unknown", func() {
+		open, closed := locations[0], locations[0]
+		open.OpeningHours.OpenNow = types.Ptr(true)
+		closed.OpeningHours.OpenNow = types.Ptr(false)
+		Expect(output.NewStream(stdout, output.FormatCSV).Write([]types.Location{open, closed, locations[0]})).To(Succeed())
+
+		records, err := csv.NewReader(stdout).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		column := -1
+		for i, name := range records[0] {
+			if name == "open_now" {
+				column = i
+			}
+		}
+		Expect(column).NotTo(Equal(-1))
+		Expect([]string{records[1][column], records[2][column], records[3][column]}).To(Equal([]string{"true", "false", ""}))
+	})
+
+	it("writes one JSON object per line", func() {
+		rows := append(output.Rows("Whole Foods in Ohio", locations), output.Rows("Whole Foods in Iowa", locations)...)
+
+		Expect(output.NewStream(stdout, output.FormatNDJSON).WriteRows(rows)).To(Succeed())
+
+		lines := strings.Split(strings.TrimSuffix(stdout.String(), "\n"), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(HavePrefix(`{"query":"Whole Foods in Ohio","business_status":""`))
+		Expect(lines[1]).To(HavePrefix(`{"query":"Whole Foods in Iowa",`))
+	})
+
+	it("writes nothing for no results in ndjson", func() {
+		Expect(output.Select("", output.FormatNDJSON, stdout).Write(nil)).To(Succeed())
+		Expect(stdout.Len()).To(BeZero())
+	})
+
+	it("validates format names", func() {
+		format, err := output.ParseFormat("NDJSON")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(format).To(Equal(output.FormatNDJSON))
+
+		format, err = output.ParseFormat("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(format).To(BeEmpty())
+
+		_, err = output.ParseFormat("xml")
+		Expect(err).To(MatchError(ContainSubstring(`unsupported output format "xml"`)))
+	})
+
+	it("picks the format from the file extension", func() {
+		Expect(output.FormatFor("results.jsonl")).To(Equal(output.FormatNDJSON))
+		Expect(output.FormatFor("results.CSV")).To(Equal(output.FormatCSV))
+		Expect(output.FormatFor("results.json")).To(Equal(output.FormatJSON))
+		Expect(output.FormatFor("results")).To(Equal(output.FormatJSON))
+	})
+
+	it("names the files of a directory after the query", func() {
+		dir := output.NewDir(filepath.Join(t.TempDir(), "out"), output.FormatCSV)
+		Expect(dir.Create()).To(Succeed())
+
+		file := dir.For("Trader Joe's in Ohio!")
+		Expect(filepath.Base(file.Path())).To(Equal("trader-joe-s-in-ohio.csv"))
+		Expect(file.Write(locations)).To(Succeed())
+		Expect(filepath.Base(dir.For("!!!").Path())).To(Equal("query.csv"))
+	})
+
+	when("a path is given", func() {
+		it("writes CSV when the file has a csv extension", func() {
+			path := filepath.Join(t.TempDir(), "results.csv")
+
+			Expect(output.Select(path, "", stdout).Write(locations)).To(Succeed())
+
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(HavePrefix("name,"))
+		})
+
+		it("lets the format override the extension", func() {
+			path := filepath.Join(t.TempDir(), "results.txt")
+
+			Expect(output.Select(path, output.FormatNDJSON, stdout).Write(locations)).To(Succeed())
+
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(HavePrefix(`{"business_status":"",`))
+		})
+
+		it("writes the file and nothing to stdout", func() {
+			path := filepath.Join(t.TempDir(), "results.json")
+
+			writer := output.Select(path, "", stdout)
+			Expect(writer).To(BeAssignableToTypeOf(&output.File{}))
+			Expect(writer.Write(locations)).To(Succeed())
+
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"place_id": "a"`))
+			Expect(stdout.Len()).To(BeZero())
+		})
+
+		it("returns an error when the file cannot be written", func() {
+			path := filepath.Join(t.TempDir(), "missing", "results.json")
+
+			err := output.Select(path, "", stdout).Write(locations)
+			Expect(err).To(MatchError(ContainSubstring("failed to write to file")))
+		})
+	})
+}
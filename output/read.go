@@ -0,0 +1,266 @@
+package output
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/types"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadFile reads results written by any of the writers. It accepts a JSON array, newline delimited JSON, a raw Places
+// API response with a results field and, for files with a csv extension, CSV.
+func ReadFile(path string) ([]Row, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decode := Decode
+	if FormatFor(path) == FormatCSV {
+		decode = DecodeCSV
+	}
+
+	rows, err := decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read results from %s: %w", path, err)
+	}
+
+	return rows, nil
+}
+
+// Decode parses results in any of the JSON forms accepted by ReadFile
+func Decode(data []byte) ([]Row, error) {
+	data = bytes.TrimSpace(data)
+
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	if data[0] == '[' {
+		var records []json.RawMessage
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, err
+		}
+		return decodeRecords(records)
+	}
+
+	var records []json.RawMessage
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var record json.RawMessage
+		if err := decoder.Decode(&record); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	if len(records) == 1 {
+		var response struct {
+			Results []types.Location `json:"results"`
+			PlaceId string           `json:"place_id"`
+		}
+
+		if err := json.Unmarshal(records[0], &response); err == nil && response.PlaceId == "" && response.Results != nil {
+			return Rows("", response.Results), nil
+		}
+	}
+
+	return decodeRecords(records)
+}
+
+func decodeRecords(records []json.RawMessage) ([]Row, error) {
+	result := make([]Row, 0, len(records))
+
+	for _, record := range records {
+		var row Row
+		if err := json.Unmarshal(record, &row.Location); err != nil {
+			return nil, err
+		}
+
+		var extra struct {
+			Query      string            `json:"query"`
+			Sources    []Source          `json:"sources"`
+			Provenance *types.Provenance `json:"provenance"`
+		}
+		if err := json.Unmarshal(record, &extra); err != nil {
+			return nil, err
+		}
+		row.Query = extra.Query
+		row.Sources = extra.Sources
+		row.Location.Provenance = extra.Provenance
+
+		// the query, sources and provenance are not part of the place
+		delete(row.Location.Extra, "query")
+		delete(row.Location.Extra, "sources")
+		delete(row.Location.Extra, "provenance")
+		if len(row.Location.Extra) == 0 {
+			row.Location.Extra = nil
+		}
+
+		result = append(result, row)
+	}
+
+	return result, nil
+}
+
+// DecodeCSV parses results written by the CSV writer. Columns are looked up by name, so the query, sources, address,
+// provenance, relevance and aliases columns are optional and unknown columns are ignored.
+func DecodeCSV(data []byte) ([]Row, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := columns["place_id"]; !ok {
+		return nil, errors.New("the CSV header does not have a place_id column")
+	}
+
+	result := make([]Row, 0, len(records)-1)
+	for n, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		var row Row
+		var err error
+
+		row.Query = field("query")
+		row.Location.Name = field("name")
+		row.Location.FormattedAddress = field("formatted_address")
+		row.Location.PlaceId = field("place_id")
+		row.Location.BusinessStatus = types.BusinessStatus(field("business_status"))
+
+		parse := func(name string, parse func(string) error) {
+			if value := field(name); value != "" && err == nil {
+				if parseErr := parse(value); parseErr != nil {
+					err = fmt.Errorf("line %d: invalid %s %q", n+2, name, value)
+				}
+			}
+		}
+
+		parse("lat", func(value string) (err error) {
+			row.Location.Geometry.Location.Lat, err = strconv.ParseFloat(value, 64)
+			return
+		})
+		parse("lng", func(value string) (err error) {
+			row.Location.Geometry.Location.Lng, err = strconv.ParseFloat(value, 64)
+			return
+		})
+		parse("rating", func(value string) error {
+			rating, err := strconv.ParseFloat(value, 64)
+			row.Location.Rating = &rating
+			return err
+		})
+		parse("user_ratings_total", func(value string) error {
+			total, err := strconv.Atoi(value)
+			row.Location.UserRatingsTotal = &total
+			return err
+		})
+		parse("price_level", func(value string) error {
+			level, err := strconv.Atoi(value)
+			row.Location.PriceLevel = &level
+			return err
+		})
+		parse("open_now", func(value string) error {
+			open, err := strconv.ParseBool(value)
+			row.Location.OpeningHours.OpenNow = &open
+			return err
+		})
+
+		if field("address_confidence") != "" {
+			row.Location.Address = &types.Address{
+				Street:     field("street"),
+				City:       field("city"),
+				Region:     field("region"),
+				PostalCode: field("postal_code"),
+				Country:    field("country"),
+			}
+			parse("address_confidence", func(value string) (err error) {
+				row.Location.Address.Confidence, err = strconv.ParseFloat(value, 64)
+				return
+			})
+		}
+
+		if field("provider") != "" {
+			row.Location.Provenance = &types.Provenance{
+				Query:    field("provenance_query"),
+				Provider: field("provider"),
+				Rule:     field("provenance_rule"),
+			}
+			parse("provenance_page", func(value string) (err error) {
+				row.Location.Provenance.Page, err = strconv.Atoi(value)
+				return
+			})
+			parse("fetched_at", func(value string) (err error) {
+				row.Location.Provenance.FetchedAt, err = time.Parse(time.RFC3339, value)
+				return
+			})
+		}
+
+		parse("relevant", func(value string) error {
+			relevant, err := strconv.ParseBool(value)
+			row.Location.Relevance = &types.Verdict{Relevant: relevant, Reason: field("relevance_reason")}
+			return err
+		})
+
+		if err != nil {
+			return nil, err
+		}
+
+		if value := field("types"); value != "" {
+			row.Location.Types = types.ParsePlaceTypes(strings.Split(value, ";"))
+		}
+
+		if aliases := field("aliases"); aliases != "" {
+			row.Location.Aliases = strings.Split(aliases, ";")
+		}
+
+		if sources := field("sources"); sources != "" {
+			for _, source := range strings.Split(sources, ";") {
+				row.Sources = append(row.Sources, parseSource(source))
+			}
+		}
+
+		result = append(result, row)
+	}
+
+	return result, nil
+}
+
+// parseSource reverses Source.String
+func parseSource(value string) Source {
+	if i := strings.LastIndex(value, " ("); i > 0 && strings.HasSuffix(value, ")") {
+		return Source{File: value[:i], Query: value[i+2 : len(value)-1]}
+	}
+	return Source{File: value}
+}
+
+// Locations drops the queries of the rows
+func Locations(rows []Row) []types.Location {
+	result := make([]types.Location, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, row.Location)
+	}
+	return result
+}
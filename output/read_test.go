@@ -0,0 +1,160 @@
+package output_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitRead(t *testing.T) {
+	spec.Run(t, "Read Unit Tests", testRead, spec.Report(report.Terminal{}))
+}
+
+func testRead(t *testing.T, when spec.G, it spec.S) {
+	rows := []output.Row{
+		{Query: "Whole Foods in Ohio", Location: types.Location{PlaceId: "a", Name: "Whole Foods Market", Rating: types.Ptr(4.5)}},
+		{Query: "Whole Foods in Iowa", Location: types.Location{PlaceId: "b", Name: "Whole Foods Market"}},
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("reads what the JSON writers wrote", func() {
+		for _, format := range []output.Format{output.FormatJSON, output.FormatNDJSON} {
+			var buf bytes.Buffer
+			Expect(output.Encode(&buf, format, rows)).To(Succeed())
+
+			result, err := output.Decode(buf.Bytes())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(rows), string(format))
+		}
+	})
+
+	it("reads what the CSV writer wrote", func() {
+		location := types.Location{PlaceId: "a", Name: "Whole Foods, Columbus", BusinessStatus: "OPERATIONAL", Rating: types.Ptr(4.5),
+			UserRatingsTotal: types.Ptr(120), PriceLevel: types.Ptr(2), Types: types.PlaceTypes{"store", "food"}}
+		location.Geometry.Location.Lat = 40.0063
+		location.Geometry.Location.Lng = -83.0405
+		location.OpeningHours.OpenNow = types.Ptr(true)
+
+		written := []output.Row{{Query: "Whole Foods in Ohio", Location: location, Sources: []output.Source{{File: "ohio.json", Query: "Whole Foods in Ohio"}}}}
+
+		path := filepath.Join(t.TempDir(), "results.csv")
+		Expect(output.NewFile(path).WriteRows(written)).To(Succeed())
+
+		result, err := output.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(written))
+	})
+
+	it("reads the parsed addresses the CSV writer wrote", func() {
+		written := []output.Row{
+			{Location: types.Location{PlaceId: "a", Address: &types.Address{City: "Columbus", Region: "OH", Confidence: 0.45}}},
+			{Location: types.Location{PlaceId: "b"}},
+		}
+
+		path := filepath.Join(t.TempDir(), "results.csv")
+		Expect(output.NewFile(path).WriteRows(written)).To(Succeed())
+
+		result, err := output.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(written))
+	})
+
+	it("reads the provenance the writers wrote", func() {
+		provenance := &types.Provenance{Query: "Whole Foods in Ohio", Page: 1, FetchedAt: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+			Provider: "google_places", Rule: "matches"}
+		written := []output.Row{
+			{Location: types.Location{PlaceId: "a", Provenance: provenance}},
+			{Location: types.Location{PlaceId: "b"}},
+		}
+
+		for _, name := range []string{"results.json", "results.ndjson", "results.csv"} {
+			path := filepath.Join(t.TempDir(), name)
+			Expect(output.NewFile(path).WriteRows(written)).To(Succeed())
+
+			result, err := output.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(written), name)
+		}
+	})
+
+	it("reads the relevance verdicts the CSV writer wrote", func() {
+		written := []output.Row{
+			{Location: types.Location{PlaceId: "a", Relevance: &types.Verdict{Relevant: true, Reason: "grocery store"}}},
+			{Location: types.Location{PlaceId: "b", Relevance: &types.Verdict{Relevant: false}}},
+			{Location: types.Location{PlaceId: "c"}},
+		}
+
+		path := filepath.Join(t.TempDir(), "results.csv")
+		Expect(output.NewFile(path).WriteRows(written)).To(Succeed())
+
+		result, err := output.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(written))
+	})
+
+	it("reads the aliases the CSV writer wrote", func() {
+		written := []output.Row{
+			{Location: types.Location{PlaceId: "a", Aliases: []string{"b", "c"}}},
+			{Location: types.Location{PlaceId: "d"}},
+		}
+
+		path := filepath.Join(t.TempDir(), "results.csv")
+		Expect(output.NewFile(path).WriteRows(written)).To(Succeed())
+
+		result, err := output.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(written))
+	})
+
+	it("returns an error for CSV without a place_id column", func() {
+		_, err := output.DecodeCSV([]byte("name\nWhole Foods\n"))
+		Expect(err).To(MatchError(ContainSubstring("place_id column")))
+	})
+
+	it("returns an error for invalid CSV values", func() {
+		_, err := output.DecodeCSV([]byte("place_id,rating\na,great\n"))
+		Expect(err).To(MatchError(`line 2: invalid rating "great"`))
+	})
+
+	it("reads a Places API response", func() {
+		result, err := output.Decode([]byte(`{"html_attributions": [], "results": [{"place_id": "a"}], "status": "OK"}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output.Locations(result)).To(Equal([]types.Location{{PlaceId: "a"}}))
+	})
+
+	it("reads a single location", func() {
+		result, err := output.Decode([]byte(`{"place_id": "a", "name": "Whole Foods Market"}`))
+
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(HaveLen(1))
+	})
+
+	it("reads an empty file", func() {
+		path := filepath.Join(t.TempDir(), "results.json")
+		Expect(os.WriteFile(path, []byte("\n"), 0644)).To(Succeed())
+
+		result, err := output.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(BeEmpty())
+	})
+
+	it("returns an error for invalid content", func() {
+		path := filepath.Join(t.TempDir(), "results.json")
+		Expect(os.WriteFile(path, []byte("[{"), 0644)).To(Succeed())
+
+		_, err := output.ReadFile(path)
+		Expect(err).To(MatchError(ContainSubstring("failed to read results from " + path)))
+	})
+}
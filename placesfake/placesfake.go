@@ -0,0 +1,417 @@
+// Package placesfake implements a local stand-in for the Google Places API so the client and the CLI can be tested
+// without network access or API keys. It serves text search, nearby search and place details, paginates with
+// next_page_token (including the delay before a token becomes valid) and can be told to answer with error statuses.
+package placesfake
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/kardolus/maps/geo"
+	"github.com/kardolus/maps/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TextSearchPath    = "/maps/api/place/textsearch/json"
+	NearbySearchPath  = "/maps/api/place/nearbysearch/json"
+	DetailsPath       = "/maps/api/place/details/json"
+	DefaultPageSize   = 20
+	DefaultTokenDelay = 50 * time.Millisecond
+	maxPages          = 3
+	errInvalidKey     = "The provided API key is invalid."
+	errQuota          = "You have exceeded your daily request quota for this API."
+)
+
+// Fixture is the on-disk format for seeding the server: the results and optional status for a single text query
+type Fixture struct {
+	Query   string           `json:"query"`
+	Status  string           `json:"status,omitempty"`
+	Results []types.Location `json:"results"`
+}
+
+type page struct {
+	results []types.Location
+	issued  time.Time
+}
+
+type Server struct {
+	mu         sync.Mutex
+	server     *httptest.Server
+	queries    map[string]Fixture
+	places     map[string]types.Location
+	order      []string
+	tokens     map[string]page
+	quotas     map[string]int
+	usage      map[string]int
+	requests   []string
+	apiKey     string
+	pageSize   int
+	tokenDelay time.Duration
+}
+
+func New() *Server {
+	return &Server{
+		queries:    make(map[string]Fixture),
+		places:     make(map[string]types.Location),
+		tokens:     make(map[string]page),
+		quotas:     make(map[string]int),
+		usage:      make(map[string]int),
+		pageSize:   DefaultPageSize,
+		tokenDelay: DefaultTokenDelay,
+	}
+}
+
+// WithAPIKey makes the server reject requests that do not use the given key
+func (s *Server) WithAPIKey(key string) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKey = key
+	return s
+}
+
+// WithPageSize configures the number of results per page
+func (s *Server) WithPageSize(size int) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pageSize = size
+	return s
+}
+
+// WithTokenDelay configures how long a next_page_token stays invalid after it was issued
+func (s *Server) WithTokenDelay(delay time.Duration) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokenDelay = delay
+	return s
+}
+
+// WithQuota makes the server answer OVER_QUERY_LIMIT once the key has made limit requests
+func (s *Server) WithQuota(key string, limit int) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.quotas[key] = limit
+	return s
+}
+
+// AddFixture registers the results for a text query. Its places also become available to nearby search and details.
+func (s *Server) AddFixture(fixture Fixture) *Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.queries[normalize(fixture.Query)] = fixture
+
+	for _, location := range fixture.Results {
+		if _, ok := s.places[location.PlaceId]; !ok {
+			s.order = append(s.order, location.PlaceId)
+		}
+		s.places[location.PlaceId] = location
+	}
+
+	return s
+}
+
+// AddQuery registers the results for a text query
+func (s *Server) AddQuery(query string, results []types.Location) *Server {
+	return s.AddFixture(Fixture{Query: query, Results: results})
+}
+
+// AddStatus makes text searches for the query answer with the given status
+func (s *Server) AddStatus(query, status string) *Server {
+	return s.AddFixture(Fixture{Query: query, Status: status})
+}
+
+// LoadFixtures registers every *.json Fixture file in the directory
+func (s *Server) LoadFixtures(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return fmt.Errorf("invalid fixture %s: %w", file, err)
+		}
+
+		s.AddFixture(fixture)
+	}
+
+	return nil
+}
+
+// Start starts serving on a random local port
+func (s *Server) Start() *Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc(TextSearchPath, s.handleTextSearch)
+	mux.HandleFunc(NearbySearchPath, s.handleNearbySearch)
+	mux.HandleFunc(DetailsPath, s.handleDetails)
+
+	s.server = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the base URL of the running server
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Close shuts the server down
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Requests returns the request URIs received so far, in order
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+// Usage returns the number of requests made with the key
+func (s *Server) Usage(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.usage[key]
+}
+
+func (s *Server) handleTextSearch(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	params := r.URL.Query()
+
+	if token := params.Get("pagetoken"); token != "" {
+		s.servePage(w, token)
+		return
+	}
+
+	query := params.Get("query")
+	if query == "" {
+		writeStatus(w, types.Response{Status: "INVALID_REQUEST", ErrorMessage: "query parameter is required"})
+		return
+	}
+
+	s.mu.Lock()
+	fixture, ok := s.queries[normalize(query)]
+	s.mu.Unlock()
+
+	if ok && fixture.Status != "" && fixture.Status != "OK" {
+		writeStatus(w, types.Response{Status: fixture.Status})
+		return
+	}
+
+	s.serveResults(w, fixture.Results)
+}
+
+func (s *Server) handleNearbySearch(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	params := r.URL.Query()
+
+	if token := params.Get("pagetoken"); token != "" {
+		s.servePage(w, token)
+		return
+	}
+
+	lat, lng, err := parseLocation(params.Get("location"))
+	if err != nil {
+		writeStatus(w, types.Response{Status: "INVALID_REQUEST", ErrorMessage: err.Error()})
+		return
+	}
+
+	radius, err := strconv.ParseFloat(params.Get("radius"), 64)
+	if err != nil || radius <= 0 {
+		writeStatus(w, types.Response{Status: "INVALID_REQUEST", ErrorMessage: "radius must be a positive number"})
+		return
+	}
+
+	keyword := strings.ToLower(params.Get("keyword"))
+	placeType := params.Get("type")
+
+	var results []types.Location
+
+	s.mu.Lock()
+	for _, id := range s.order {
+		location := s.places[id]
+
+		if geo.Distance(geo.Point{Lat: lat, Lng: lng}, geo.PointOf(location)) > radius {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(location.Name), keyword) {
+			continue
+		}
+		if placeType != "" && !location.Types.Has(types.PlaceType(placeType)) {
+			continue
+		}
+
+		results = append(results, location)
+	}
+	s.mu.Unlock()
+
+	s.serveResults(w, results)
+}
+
+func (s *Server) handleDetails(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	id := r.URL.Query().Get("place_id")
+	if id == "" {
+		writeJSON(w, map[string]interface{}{"html_attributions": []string{}, "status": "INVALID_REQUEST"})
+		return
+	}
+
+	s.mu.Lock()
+	location, ok := s.places[id]
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, map[string]interface{}{"html_attributions": []string{}, "status": "NOT_FOUND"})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{"html_attributions": []string{}, "result": location, "status": "OK"})
+}
+
+// authorize records the request and checks the key and its quota
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	key := r.URL.Query().Get("key")
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	s.usage[key]++
+	used := s.usage[key]
+	limit, limited := s.quotas[key]
+	apiKey := s.apiKey
+	s.mu.Unlock()
+
+	if key == "" || (apiKey != "" && key != apiKey) {
+		writeStatus(w, types.Response{Status: "REQUEST_DENIED", ErrorMessage: errInvalidKey})
+		return false
+	}
+
+	if limited && used > limit {
+		writeStatus(w, types.Response{Status: "OVER_QUERY_LIMIT", ErrorMessage: errQuota})
+		return false
+	}
+
+	return true
+}
+
+// serveResults writes the first page and stores the remaining results behind a next_page_token
+func (s *Server) serveResults(w http.ResponseWriter, results []types.Location) {
+	if len(results) == 0 {
+		writeStatus(w, types.Response{Status: "ZERO_RESULTS"})
+		return
+	}
+
+	s.mu.Lock()
+	limit := s.pageSize * maxPages
+	s.mu.Unlock()
+
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	s.writePage(w, results)
+}
+
+func (s *Server) servePage(w http.ResponseWriter, token string) {
+	s.mu.Lock()
+	p, ok := s.tokens[token]
+	delay := s.tokenDelay
+	s.mu.Unlock()
+
+	if !ok || time.Since(p.issued) < delay {
+		writeStatus(w, types.Response{Status: "INVALID_REQUEST"})
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.tokens, token)
+	s.mu.Unlock()
+
+	s.writePage(w, p.results)
+}
+
+func (s *Server) writePage(w http.ResponseWriter, results []types.Location) {
+	response := types.Response{
+		HtmlAttributions: []string{},
+		Results:          results,
+		Status:           "OK",
+	}
+
+	s.mu.Lock()
+	if len(results) > s.pageSize {
+		response.Results = results[:s.pageSize]
+		response.NextPageToken = newToken()
+		s.tokens[response.NextPageToken] = page{results: results[s.pageSize:], issued: time.Now()}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, response)
+}
+
+func writeStatus(w http.ResponseWriter, response types.Response) {
+	response.HtmlAttributions = []string{}
+	response.Results = []types.Location{}
+	writeJSON(w, response)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func normalize(query string) string {
+	return strings.ToLower(strings.Join(strings.Fields(query), " "))
+}
+
+func parseLocation(value string) (float64, float64, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("location must be formatted as lat,lng")
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid latitude %q", parts[0])
+	}
+
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid longitude %q", parts[1])
+	}
+
+	return lat, lng, nil
+}
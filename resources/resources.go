@@ -0,0 +1,11 @@
+package resources
+
+import "embed"
+
+//go:embed *.txt
+var files embed.FS
+
+// ReadFile returns the contents of a resource that was embedded at build time
+func ReadFile(name string) ([]byte, error) {
+	return files.ReadFile(name)
+}
@@ -0,0 +1,503 @@
+// Package server exposes searches over HTTP as jobs: clients submit a query, poll the status of the job and fetch the
+// results in any output format once it succeeded. Jobs run in-process on a fixed number of workers and only use the
+// API keys of the server configuration.
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/filter"
+	"github.com/kardolus/maps/output"
+	"github.com/kardolus/maps/types"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+
+	DefaultAddr        = "127.0.0.1:8080"
+	DefaultConcurrency = 2
+	DefaultQueueSize   = 100
+	DefaultRetention   = time.Hour
+	DefaultMaxFinished = 100
+)
+
+var (
+	ErrQueueFull = errors.New("the job queue is full, try again later")
+	ErrNotFound  = errors.New("job not found")
+)
+
+// Factory returns the finder for a job. The finder should stop making requests once the context is done and write its
+// progress messages to log.
+type Factory func(ctx context.Context, opts app.Options, log io.Writer) (app.Finder, error)
+
+// Request is the body of a job submission. Everything else, including the API keys, comes from the server
+// configuration.
+type Request struct {
+	Query          string `json:"query"`
+	Where          string `json:"where,omitempty"`
+	Classify       bool   `json:"classify,omitempty"`
+	KeepIrrelevant bool   `json:"keep_irrelevant,omitempty"`
+	Dedupe         bool   `json:"dedupe,omitempty"`
+	MaxResults     int    `json:"max_results,omitempty"`
+	Rounds         int    `json:"rounds,omitempty"`
+}
+
+// Job is the status of a search as reported by the API
+type Job struct {
+	ID         string     `json:"id"`
+	Query      string     `json:"query"`
+	Status     string     `json:"status"`
+	Progress   string     `json:"progress,omitempty"`
+	Results    int        `json:"results"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+type job struct {
+	mu        sync.Mutex
+	status    Job
+	opts      app.Options
+	locations []types.Location
+	cancel    context.CancelFunc
+}
+
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// Write records the last line written as the progress of the job
+func (j *job) Write(p []byte) (int, error) {
+	lines := strings.Split(strings.TrimSpace(string(p)), "\n")
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		j.mu.Lock()
+		j.status.Progress = last
+		j.mu.Unlock()
+	}
+	return len(p), nil
+}
+
+type Server struct {
+	base        app.Options
+	factory     Factory
+	concurrency int
+	queue       chan *job
+	log         io.Writer
+	retention   time.Duration
+	maxFinished int
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// New runs the jobs with the finders of the factory. Every job starts from the base options, which hold the server
+// configuration.
+func New(base app.Options, factory Factory) *Server {
+	return &Server{
+		base:        base,
+		factory:     factory,
+		concurrency: DefaultConcurrency,
+		queue:       make(chan *job, DefaultQueueSize),
+		log:         io.Discard,
+		retention:   DefaultRetention,
+		maxFinished: DefaultMaxFinished,
+		jobs:        make(map[string]*job),
+	}
+}
+
+// WithConcurrency configures how many jobs run at the same time
+func (s *Server) WithConcurrency(concurrency int) *Server {
+	s.concurrency = concurrency
+	return s
+}
+
+// WithQueueSize configures how many jobs can wait for a worker before submissions are rejected
+func (s *Server) WithQueueSize(size int) *Server {
+	s.queue = make(chan *job, size)
+	return s
+}
+
+// WithRetention configures how long finished jobs and their results are kept, 0 to keep them until they are evicted by
+// the maximum number of finished jobs
+func (s *Server) WithRetention(retention time.Duration) *Server {
+	s.retention = retention
+	return s
+}
+
+// WithMaxFinished configures how many finished jobs are kept at most, the oldest are evicted first. 0 removes the
+// limit.
+func (s *Server) WithMaxFinished(max int) *Server {
+	s.maxFinished = max
+	return s
+}
+
+// WithLog configures where the start and end of every job are logged
+func (s *Server) WithLog(log io.Writer) *Server {
+	s.log = log
+	return s
+}
+
+// Start runs the workers until the context is done. Running jobs are canceled when it is.
+func (s *Server) Start(ctx context.Context) {
+	for i := 0; i < s.concurrency; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-s.queue:
+					s.run(ctx, j)
+					s.evict()
+				}
+			}
+		}()
+	}
+}
+
+// Submit validates the request and queues a job for it
+func (s *Server) Submit(request Request) (Job, error) {
+	opts, err := s.options(request)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j := &job{
+		opts:   opts,
+		status: Job{ID: newID(), Query: opts.Query, Status: StatusQueued, CreatedAt: time.Now().UTC()},
+	}
+
+	s.evict()
+
+	select {
+	case s.queue <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+
+	s.mu.Lock()
+	s.jobs[j.status.ID] = j
+	s.mu.Unlock()
+
+	return j.snapshot(), nil
+}
+
+func (s *Server) options(request Request) (app.Options, error) {
+	query := strings.TrimSpace(request.Query)
+	if query == "" {
+		return app.Options{}, app.ErrMissingQuery
+	}
+
+	opts, err := s.base.ForQuery(query)
+	if err != nil {
+		return app.Options{}, err
+	}
+
+	opts.Where = nil
+	if request.Where != "" {
+		if opts.Where, err = filter.Compile(request.Where); err != nil {
+			return app.Options{}, fmt.Errorf("invalid where expression: %w", err)
+		}
+	}
+
+	opts.Classify = request.Classify
+	opts.KeepIrrelevant = request.KeepIrrelevant
+	opts.Dedupe = request.Dedupe
+
+	if request.MaxResults > 0 {
+		opts.MaxResults = request.MaxResults
+	}
+	if request.Rounds > 0 {
+		opts.Rounds = request.Rounds
+	}
+
+	return opts, nil
+}
+
+func (s *Server) run(ctx context.Context, j *job) {
+	j.mu.Lock()
+	if j.status.Status != StatusQueued {
+		j.mu.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	started := time.Now().UTC()
+	j.cancel = cancel
+	j.status.Status = StatusRunning
+	j.status.StartedAt = &started
+	j.mu.Unlock()
+
+	fmt.Fprintf(s.log, "Job %s started: %s\n", j.status.ID, j.opts.Query)
+
+	var locations []types.Location
+	finder, err := s.factory(ctx, j.opts, j)
+	if err == nil {
+		locations, _, err = finder.Find(j.opts)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	finished := time.Now().UTC()
+	j.status.FinishedAt = &finished
+	j.cancel = nil
+
+	switch {
+	case ctx.Err() != nil:
+		j.status.Status = StatusCanceled
+	case err != nil:
+		j.status.Status = StatusFailed
+		j.status.Error = err.Error()
+	default:
+		j.status.Status = StatusSucceeded
+		j.status.Results = len(locations)
+		j.locations = locations
+	}
+
+	fmt.Fprintf(s.log, "Job %s %s after %s\n", j.status.ID, j.status.Status, finished.Sub(started).Round(time.Millisecond))
+}
+
+// evict forgets the finished jobs that are past the retention, then the oldest finished jobs beyond the maximum.
+// Queued and running jobs are always kept.
+func (s *Server) evict() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var finished []Job
+	for id, j := range s.jobs {
+		status := j.snapshot()
+		if status.FinishedAt == nil {
+			continue
+		}
+
+		if s.retention > 0 && time.Since(*status.FinishedAt) > s.retention {
+			delete(s.jobs, id)
+			continue
+		}
+
+		finished = append(finished, status)
+	}
+
+	if s.maxFinished <= 0 || len(finished) <= s.maxFinished {
+		return
+	}
+
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].FinishedAt.Before(*finished[b].FinishedAt)
+	})
+
+	for _, status := range finished[:len(finished)-s.maxFinished] {
+		delete(s.jobs, status.ID)
+	}
+}
+
+// Get returns the status of a job
+func (s *Server) Get(id string) (Job, error) {
+	j, err := s.job(id)
+	if err != nil {
+		return Job{}, err
+	}
+	return j.snapshot(), nil
+}
+
+// List returns the status of every job, the oldest first
+func (s *Server) List() []Job {
+	s.mu.Lock()
+	result := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		result = append(result, j.snapshot())
+	}
+	s.mu.Unlock()
+
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].CreatedAt.Before(result[b].CreatedAt)
+	})
+
+	return result
+}
+
+// Cancel stops a queued or running job. A running job stops at its next Places API request. Jobs that already
+// finished are left as they are.
+func (s *Server) Cancel(id string) (Job, error) {
+	j, err := s.job(id)
+	if err != nil {
+		return Job{}, err
+	}
+
+	j.mu.Lock()
+	switch j.status.Status {
+	case StatusQueued:
+		finished := time.Now().UTC()
+		j.status.Status = StatusCanceled
+		j.status.FinishedAt = &finished
+	case StatusRunning:
+		j.cancel()
+	}
+	j.mu.Unlock()
+
+	return j.snapshot(), nil
+}
+
+// Results returns the results of a job that succeeded
+func (s *Server) Results(id string) ([]types.Location, error) {
+	j, err := s.job(id)
+	if err != nil {
+		return nil, err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.status.Status != StatusSucceeded {
+		return nil, fmt.Errorf("job %s is %s, results are available once it succeeded", id, j.status.Status)
+	}
+
+	return j.locations, nil
+}
+
+func (s *Server) job(id string) (*job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return j, nil
+}
+
+// Handler serves the REST API:
+//
+//	POST   /jobs               submit a Request, returns the Job
+//	GET    /jobs               list the jobs
+//	GET    /jobs/{id}          status and progress of a job
+//	GET    /jobs/{id}/results  results as json, ndjson or csv, picked by the format parameter
+//	DELETE /jobs/{id}          cancel a job
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGet)
+	mux.HandleFunc("GET /jobs/{id}/results", s.handleResults)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancel)
+	return mux
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var request Request
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	submitted, err := s.Submit(request)
+	switch {
+	case errors.Is(err, ErrQueueFull):
+		writeError(w, http.StatusServiceUnavailable, err)
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+	default:
+		w.Header().Set("Location", "/jobs/"+submitted.ID)
+		writeJSON(w, http.StatusAccepted, submitted)
+	}
+}
+
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.List())
+}
+
+func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
+	status, err := s.Get(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	status, err := s.Cancel(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleResults(w http.ResponseWriter, r *http.Request) {
+	format, err := output.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if format == "" {
+		format = output.FormatJSON
+	}
+
+	id := r.PathValue("id")
+	if _, err := s.job(id); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	locations, err := s.Results(id)
+	if err != nil {
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := output.Encode(&buf, format, output.Rows("", locations)); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentTypes[format])
+	_, _ = w.Write(buf.Bytes())
+}
+
+var contentTypes = map[output.Format]string{
+	output.FormatJSON:   "application/json",
+	output.FormatNDJSON: "application/x-ndjson",
+	output.FormatCSV:    "text/csv",
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,269 @@
+package server_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kardolus/maps/app"
+	"github.com/kardolus/maps/server"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitServer(t *testing.T) {
+	spec.Run(t, "Server Unit Tests", testServer, spec.Report(report.Terminal{}))
+}
+
+// fakeFinder returns its locations, or blocks until its context is done when block is set
+type fakeFinder struct {
+	ctx       context.Context
+	log       io.Writer
+	locations []types.Location
+	err       error
+	block     bool
+}
+
+func (f *fakeFinder) Find(opts app.Options) ([]types.Location, app.Summary, error) {
+	fmt.Fprintf(f.log, "Fetching locations for query: %s\n", opts.Query)
+
+	if f.block {
+		<-f.ctx.Done()
+		return nil, app.Summary{}, f.ctx.Err()
+	}
+
+	return f.locations, app.Summary{}, f.err
+}
+
+func testServer(t *testing.T, when spec.G, it spec.S) {
+	var (
+		subject   *server.Server
+		api       *httptest.Server
+		cancel    context.CancelFunc
+		received  []app.Options
+		finder    fakeFinder
+		locations = []types.Location{
+			{PlaceId: "a", Name: "Whole Foods Market", Rating: types.Ptr(4.6)},
+			{PlaceId: "b", Name: "Whole Foods Market", Rating: types.Ptr(3.9)},
+		}
+	)
+
+	request := func(method, path, body string) (int, string) {
+		req, err := http.NewRequest(method, api.URL+path, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+
+		response, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+
+		data, err := io.ReadAll(response.Body)
+		Expect(err).NotTo(HaveOccurred())
+
+		return response.StatusCode, string(data)
+	}
+
+	submit := func(body string) server.Job {
+		status, response := request(http.MethodPost, "/jobs", body)
+		Expect(status).To(Equal(http.StatusAccepted), response)
+
+		var job server.Job
+		Expect(json.Unmarshal([]byte(response), &job)).To(Succeed())
+		return job
+	}
+
+	await := func(id string, expected string) server.Job {
+		var job server.Job
+		Eventually(func() string {
+			_, response := request(http.MethodGet, "/jobs/"+id, "")
+			Expect(json.Unmarshal([]byte(response), &job)).To(Succeed())
+			return job.Status
+		}, time.Second, 5*time.Millisecond).Should(Equal(expected))
+		return job
+	}
+
+	it.Before(func() {
+		RegisterTestingT(t)
+
+		received = nil
+		finder = fakeFinder{locations: locations}
+
+		factory := func(ctx context.Context, opts app.Options, log io.Writer) (app.Finder, error) {
+			received = append(received, opts)
+			f := finder
+			f.ctx, f.log = ctx, log
+			return &f, nil
+		}
+
+		base := app.Options{APIKey: "server-key", MaxResults: 50, Rounds: 2}
+		subject = server.New(base, factory).WithConcurrency(1)
+
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		subject.Start(ctx)
+
+		api = httptest.NewServer(subject.Handler())
+	})
+
+	it.After(func() {
+		api.Close()
+		cancel()
+	})
+
+	it("runs a job and serves its results", func() {
+		job := submit(`{"query": "Whole Foods in Ohio", "where": "rating >= 4", "max_results": 20}`)
+		Expect(job.Status).To(Equal(server.StatusQueued))
+
+		job = await(job.ID, server.StatusSucceeded)
+		Expect(job.Results).To(Equal(2))
+		Expect(job.Progress).To(Equal("Fetching locations for query: Whole Foods in Ohio"))
+		Expect(job.StartedAt).NotTo(BeNil())
+		Expect(job.FinishedAt).NotTo(BeNil())
+
+		Expect(received).To(HaveLen(1))
+		Expect(received[0].APIKey).To(Equal("server-key"))
+		Expect(received[0].MaxResults).To(Equal(20))
+		Expect(received[0].Rounds).To(Equal(2))
+		Expect(received[0].Where.String()).To(Equal("rating >= 4"))
+
+		status, body := request(http.MethodGet, "/jobs/"+job.ID+"/results", "")
+		Expect(status).To(Equal(http.StatusOK))
+
+		var result []types.Location
+		Expect(json.Unmarshal([]byte(body), &result)).To(Succeed())
+		Expect(result).To(Equal(locations))
+
+		status, body = request(http.MethodGet, "/jobs/"+job.ID+"/results?format=csv", "")
+		Expect(status).To(Equal(http.StatusOK))
+
+		records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(records).To(HaveLen(3))
+	})
+
+	it("lists the jobs", func() {
+		first := submit(`{"query": "Whole Foods in Ohio"}`)
+		second := submit(`{"query": "Whole Foods in Iowa"}`)
+
+		_, body := request(http.MethodGet, "/jobs", "")
+
+		var jobs []server.Job
+		Expect(json.Unmarshal([]byte(body), &jobs)).To(Succeed())
+		Expect(jobs).To(HaveLen(2))
+		Expect(jobs[0].ID).To(Equal(first.ID))
+		Expect(jobs[1].ID).To(Equal(second.ID))
+	})
+
+	it("reports failed jobs", func() {
+		finder.err = errors.New("http status 500")
+
+		job := await(submit(`{"query": "Whole Foods in Ohio"}`).ID, server.StatusFailed)
+		Expect(job.Error).To(Equal("http status 500"))
+
+		status, body := request(http.MethodGet, "/jobs/"+job.ID+"/results", "")
+		Expect(status).To(Equal(http.StatusConflict))
+		Expect(body).To(ContainSubstring("is failed, results are available once it succeeded"))
+	})
+
+	it("cancels running and queued jobs", func() {
+		finder.block = true
+
+		running := submit(`{"query": "Whole Foods in Ohio"}`)
+		await(running.ID, server.StatusRunning)
+
+		queued := submit(`{"query": "Whole Foods in Iowa"}`)
+
+		status, body := request(http.MethodDelete, "/jobs/"+queued.ID, "")
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(ContainSubstring(`"status":"canceled"`))
+
+		status, _ = request(http.MethodDelete, "/jobs/"+running.ID, "")
+		Expect(status).To(Equal(http.StatusOK))
+
+		await(running.ID, server.StatusCanceled)
+		Expect(received).To(HaveLen(1))
+	})
+
+	it("rejects invalid requests", func() {
+		for body, expected := range map[string]string{
+			`{"query": ""}`:                            "missing query",
+			`{"query": "Whole Foods", "where": "?"}`:   "invalid where expression",
+			`{"query": "Whole Foods", "api_key": "x"}`: `unknown field \"api_key\"`,
+			`not json`: "invalid request",
+		} {
+			status, response := request(http.MethodPost, "/jobs", body)
+			Expect(status).To(Equal(http.StatusBadRequest), body)
+			Expect(response).To(ContainSubstring(expected), body)
+		}
+
+		status, _ := request(http.MethodGet, "/jobs/missing/results?format=xml", "")
+		Expect(status).To(Equal(http.StatusBadRequest))
+	})
+
+	it("returns 404 for unknown jobs", func() {
+		for _, path := range []string{"/jobs/missing", "/jobs/missing/results"} {
+			status, body := request(http.MethodGet, path, "")
+			Expect(status).To(Equal(http.StatusNotFound))
+			Expect(body).To(ContainSubstring("job not found"))
+		}
+
+		status, _ := request(http.MethodDelete, "/jobs/missing", "")
+		Expect(status).To(Equal(http.StatusNotFound))
+	})
+
+	it("evicts the oldest finished jobs beyond the maximum", func() {
+		subject.WithMaxFinished(1)
+
+		first := await(submit(`{"query": "Whole Foods in Ohio"}`).ID, server.StatusSucceeded)
+		second := await(submit(`{"query": "Whole Foods in Iowa"}`).ID, server.StatusSucceeded)
+
+		status, _ := request(http.MethodGet, "/jobs/"+first.ID, "")
+		Expect(status).To(Equal(http.StatusNotFound))
+
+		status, _ = request(http.MethodGet, "/jobs/"+second.ID+"/results", "")
+		Expect(status).To(Equal(http.StatusOK))
+	})
+
+	it("evicts finished jobs after the retention", func() {
+		subject.WithRetention(time.Millisecond)
+		finder.block = true
+
+		running := submit(`{"query": "Whole Foods in Ohio"}`)
+		await(running.ID, server.StatusRunning)
+
+		queued, err := subject.Submit(server.Request{Query: "Whole Foods in Iowa"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = subject.Cancel(queued.ID)
+		Expect(err).NotTo(HaveOccurred())
+
+		time.Sleep(5 * time.Millisecond)
+		_, err = subject.Submit(server.Request{Query: "Whole Foods in Utah"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = subject.Get(queued.ID)
+		Expect(err).To(MatchError(server.ErrNotFound))
+
+		job, err := subject.Get(running.ID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(job.Status).To(Equal(server.StatusRunning))
+	})
+
+	it("rejects jobs when the queue is full", func() {
+		stopped := server.New(app.Options{APIKey: "server-key"}, nil).WithQueueSize(1)
+
+		_, err := stopped.Submit(server.Request{Query: "Whole Foods in Ohio"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = stopped.Submit(server.Request{Query: "Whole Foods in Iowa"})
+		Expect(err).To(MatchError(server.ErrQueueFull))
+	})
+}
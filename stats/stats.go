@@ -0,0 +1,299 @@
+// Package stats profiles a result set: where the places are, whether they are open, what types they have and how
+// they are rated and reviewed.
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kardolus/maps/address"
+	"github.com/kardolus/maps/types"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+
+	// Unknown is the key of the places whose state, country or business status is not known
+	Unknown = "unknown"
+
+	bucketWidth = 0.5 // stars
+)
+
+// Percentiles are the review count percentiles that are reported
+var Percentiles = []int{25, 50, 75, 90, 99}
+
+// ParseFormat validates the format of a report
+func ParseFormat(format string) (string, error) {
+	switch format {
+	case FormatText, FormatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid stats format %q, use %s or %s", format, FormatText, FormatJSON)
+	}
+}
+
+// Count is the number of places with a key, like a state or a type
+type Count struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// Bucket is the number of places rated from Min up to, but not including, Max; the last bucket includes 5 stars
+type Bucket struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int     `json:"count"`
+}
+
+type Ratings struct {
+	Rated     int      `json:"rated"`
+	Unrated   int      `json:"unrated"`
+	Mean      float64  `json:"mean"`
+	Histogram []Bucket `json:"histogram"`
+}
+
+// Reviews summarizes the number of reviews of the places that report one. Percentiles maps a percentile, like "p50",
+// to the number of reviews at most that share of the places have.
+type Reviews struct {
+	Reported    int            `json:"reported"`
+	Total       int            `json:"total"`
+	Percentiles map[string]int `json:"percentiles"`
+	Max         int            `json:"max"`
+}
+
+// OpenNow counts the places that were open and closed when they were fetched. The Places API leaves open_now out for
+// places without opening hours, so they are counted apart and Share is the share of open places among the others.
+type OpenNow struct {
+	Open    int     `json:"open"`
+	Closed  int     `json:"closed"`
+	Unknown int     `json:"unknown"`
+	Share   float64 `json:"share"`
+}
+
+type Report struct {
+	Places           int     `json:"places"`
+	Countries        []Count `json:"countries"`
+	States           []Count `json:"states"`
+	BusinessStatuses []Count `json:"business_statuses"`
+	Types            []Count `json:"types"`
+	Ratings          Ratings `json:"ratings"`
+	Reviews          Reviews `json:"reviews"`
+	OpenNow          OpenNow `json:"open_now"`
+}
+
+// Profile computes the report of the locations. Counts are sorted by count, then by key.
+func Profile(locations []types.Location) Report {
+	report := Report{Places: len(locations)}
+
+	countries := make(map[string]int)
+	states := make(map[string]int)
+	statuses := make(map[string]int)
+	placeTypes := make(map[string]int)
+
+	report.Ratings.Histogram = make([]Bucket, 0, int(4/bucketWidth))
+	for from := 1.0; from < 5; from += bucketWidth {
+		report.Ratings.Histogram = append(report.Ratings.Histogram, Bucket{Min: from, Max: from + bucketWidth})
+	}
+
+	var ratingSum float64
+	var reviews []int
+
+	for _, location := range locations {
+		state, country := Region(location)
+		countries[orUnknown(country)]++
+		states[orUnknown(state)]++
+		statuses[orUnknown(string(location.BusinessStatus))]++
+
+		for _, t := range location.Types {
+			placeTypes[string(t)]++
+		}
+
+		if location.Rating == nil {
+			report.Ratings.Unrated++
+		} else {
+			rating := *location.Rating
+			report.Ratings.Rated++
+			ratingSum += rating
+
+			bucket := int(math.Floor((rating - 1) / bucketWidth))
+			bucket = max(0, min(bucket, len(report.Ratings.Histogram)-1))
+			report.Ratings.Histogram[bucket].Count++
+		}
+
+		if location.UserRatingsTotal != nil {
+			reviews = append(reviews, *location.UserRatingsTotal)
+			report.Reviews.Total += *location.UserRatingsTotal
+		}
+
+		switch open := location.OpeningHours.OpenNow; {
+		case open == nil:
+			report.OpenNow.Unknown++
+		case *open:
+			report.OpenNow.Open++
+		default:
+			report.OpenNow.Closed++
+		}
+	}
+
+	report.Countries = counts(countries)
+	report.States = counts(states)
+	report.BusinessStatuses = counts(statuses)
+	report.Types = counts(placeTypes)
+
+	if report.Ratings.Rated > 0 {
+		report.Ratings.Mean = ratingSum / float64(report.Ratings.Rated)
+	}
+
+	sort.Ints(reviews)
+	report.Reviews.Reported = len(reviews)
+	report.Reviews.Percentiles = make(map[string]int, len(Percentiles))
+	if len(reviews) > 0 {
+		for _, p := range Percentiles {
+			report.Reviews.Percentiles[fmt.Sprintf("p%d", p)] = percentile(reviews, p)
+		}
+		report.Reviews.Max = reviews[len(reviews)-1]
+	}
+
+	if reported := report.OpenNow.Open + report.OpenNow.Closed; reported > 0 {
+		report.OpenNow.Share = float64(report.OpenNow.Open) / float64(reported)
+	}
+
+	return report
+}
+
+// Region returns the state and the country of the location. They are read from the parsed address, parsing the
+// formatted address when the location has none, and completed from the compound plus code, like
+// "2WX4+Q3 Columbus, OH, USA". Either is empty when it is not known.
+func Region(l types.Location) (string, string) {
+	parsed := l.Address
+	if parsed == nil {
+		a := address.Parse(l.FormattedAddress)
+		parsed = &a
+	}
+
+	state, country := parsed.Region, parsed.Country
+
+	if _, place, ok := strings.Cut(l.PlusCode.CompoundCode, " "); ok {
+		codeParts := split(place)
+		if country == "" && len(codeParts) >= 2 {
+			country = codeParts[len(codeParts)-1]
+		}
+		if state == "" && len(codeParts) >= 3 {
+			state = codeParts[len(codeParts)-2]
+		}
+	}
+
+	return state, country
+}
+
+// WriteText renders the report for humans
+func (r Report) WriteText(w io.Writer) error {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("Places: %d\n", r.Places))
+	writeCounts(&sb, "Countries", r.Countries)
+	writeCounts(&sb, "States", r.States)
+	writeCounts(&sb, "Business status", r.BusinessStatuses)
+	writeCounts(&sb, "Types", r.Types)
+
+	sb.WriteString(fmt.Sprintf("Ratings: %d rated, %d unrated", r.Ratings.Rated, r.Ratings.Unrated))
+	if r.Ratings.Rated > 0 {
+		sb.WriteString(fmt.Sprintf(", mean %.2f", r.Ratings.Mean))
+	}
+	sb.WriteString("\n")
+
+	if r.Ratings.Rated > 0 {
+		peak := 0
+		for _, bucket := range r.Ratings.Histogram {
+			peak = max(peak, bucket.Count)
+		}
+		for _, bucket := range r.Ratings.Histogram {
+			bar := strings.Repeat("#", int(math.Round(float64(bucket.Count)/float64(peak)*30)))
+			sb.WriteString(fmt.Sprintf("  %.1f-%.1f %5d %s\n", bucket.Min, bucket.Max, bucket.Count, bar))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("Reviews: %d reported, %d in total", r.Reviews.Reported, r.Reviews.Total))
+	if r.Reviews.Reported > 0 {
+		for _, p := range Percentiles {
+			key := fmt.Sprintf("p%d", p)
+			sb.WriteString(fmt.Sprintf(", %s %d", key, r.Reviews.Percentiles[key]))
+		}
+		sb.WriteString(fmt.Sprintf(", max %d", r.Reviews.Max))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString(fmt.Sprintf("Open now: %d open, %d closed, %d unknown (%.0f%% of the places that report it)\n",
+		r.OpenNow.Open, r.OpenNow.Closed, r.OpenNow.Unknown, r.OpenNow.Share*100))
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// Write renders the report in the format, FormatText or FormatJSON
+func (r Report) Write(w io.Writer, format string) error {
+	if format != FormatJSON {
+		return r.WriteText(w)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+func writeCounts(sb *strings.Builder, title string, counts []Count) {
+	sb.WriteString(title + ":\n")
+	if len(counts) == 0 {
+		sb.WriteString("  none\n")
+	}
+	for _, count := range counts {
+		sb.WriteString(fmt.Sprintf("  %-30s %5d\n", count.Key, count.Count))
+	}
+}
+
+func counts(m map[string]int) []Count {
+	result := make([]Count, 0, len(m))
+	for key, count := range m {
+		result = append(result, Count{Key: key, Count: count})
+	}
+
+	sort.Slice(result, func(a, b int) bool {
+		if result[a].Count != result[b].Count {
+			return result[a].Count > result[b].Count
+		}
+		return result[a].Key < result[b].Key
+	})
+
+	return result
+}
+
+// percentile returns the nearest-rank percentile of the sorted values
+func percentile(sorted []int, p int) int {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
+
+func orUnknown(key string) string {
+	if key == "" {
+		return Unknown
+	}
+	return key
+}
+
+func split(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package stats_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/kardolus/maps/stats"
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitStats(t *testing.T) {
+	spec.Run(t, "Stats Unit Tests", testStats, spec.Report(report.Terminal{}))
+}
+
+func testStats(t *testing.T, when spec.G, it spec.S) {
+	var (
+		place = func(address string, status types.BusinessStatus, rating *float64, reviews *int, open *bool, placeTypes ...types.PlaceType) types.Location {
+			l := types.Location{FormattedAddress: address, BusinessStatus: status, Rating: rating, UserRatingsTotal: reviews, Types: placeTypes}
+			l.OpeningHours.OpenNow = open
+			return l
+		}
+		locations = []types.Location{
+			place("1555 W Lane Ave, Columbus, OH 43221, United States", types.StatusOperational, types.Ptr(4.6), types.Ptr(1200), types.Ptr(true),
+				types.TypeGroceryOrSupermarket, types.TypeStore),
+			place("13998 Cedar Rd, South Euclid, OH 44118, United States", types.StatusOperational, types.Ptr(4.4), types.Ptr(900), types.Ptr(false),
+				types.TypeGroceryOrSupermarket),
+			place("1 Main St, Pittsburgh, PA 15222, United States", types.StatusClosedPermanently, types.Ptr(1.0), types.Ptr(10), types.Ptr(false),
+				types.TypeStore),
+			place("87 Avenue Rd, Toronto, ON M5R 3R9, Canada", types.StatusOperational, types.Ptr(5.0), types.Ptr(300), types.Ptr(true)),
+			place("", "", nil, nil, nil),
+		}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	when("finding the region of a place", func() {
+		it("reads the state and country from the formatted address", func() {
+			for address, region := range map[string][2]string{
+				"1555 W Lane Ave, Columbus, OH 43221, United States": {"OH", "United States"},
+				"87 Avenue Rd, Toronto, ON M5R 3R9, Canada":          {"ON", "Canada"},
+				"Columbus, OH 43221":                                 {"OH", ""},
+				"Columbus":                                           {"", ""},
+			} {
+				state, country := stats.Region(types.Location{FormattedAddress: address})
+				Expect([2]string{state, country}).To(Equal(region), address)
+			}
+		})
+
+		it("prefers the parsed address", func() {
+			l := types.Location{FormattedAddress: "Columbus, OH 43221", Address: &types.Address{Region: "Ohio", Country: "United States"}}
+
+			state, country := stats.Region(l)
+			Expect(state).To(Equal("Ohio"))
+			Expect(country).To(Equal("United States"))
+		})
+
+		it("completes the region from the compound plus code", func() {
+			l := types.Location{FormattedAddress: "Columbus, OH 43221"}
+			l.PlusCode.CompoundCode = "2WX4+Q3 Columbus, OH, USA"
+
+			state, country := stats.Region(l)
+			Expect(state).To(Equal("OH"))
+			Expect(country).To(Equal("USA"))
+
+			l.FormattedAddress = ""
+			l.PlusCode.CompoundCode = "2WX4+Q3 Paris, France"
+			state, country = stats.Region(l)
+			Expect(state).To(BeEmpty())
+			Expect(country).To(Equal("France"))
+		})
+	})
+
+	when("profiling the places", func() {
+		it("counts the places by region, status and type", func() {
+			r := stats.Profile(locations)
+
+			Expect(r.Places).To(Equal(5))
+			Expect(r.Countries).To(Equal([]stats.Count{{Key: "United States", Count: 3}, {Key: "Canada", Count: 1}, {Key: stats.Unknown, Count: 1}}))
+			Expect(r.States).To(Equal([]stats.Count{{Key: "OH", Count: 2}, {Key: "ON", Count: 1}, {Key: "PA", Count: 1}, {Key: stats.Unknown, Count: 1}}))
+			Expect(r.BusinessStatuses).To(Equal([]stats.Count{{Key: "OPERATIONAL", Count: 3}, {Key: "CLOSED_PERMANENTLY", Count: 1}, {Key: stats.Unknown, Count: 1}}))
+			Expect(r.Types).To(Equal([]stats.Count{{Key: "grocery_or_supermarket", Count: 2}, {Key: "store", Count: 2}}))
+		})
+
+		it("builds the rating histogram", func() {
+			r := stats.Profile(locations)
+
+			Expect(r.Ratings.Rated).To(Equal(4))
+			Expect(r.Ratings.Unrated).To(Equal(1))
+			Expect(r.Ratings.Mean).To(BeNumerically("~", 3.75, 1e-9))
+			Expect(r.Ratings.Histogram).To(HaveLen(8))
+			Expect(r.Ratings.Histogram[0]).To(Equal(stats.Bucket{Min: 1, Max: 1.5, Count: 1}))
+			Expect(r.Ratings.Histogram[6]).To(Equal(stats.Bucket{Min: 4, Max: 4.5, Count: 1}))
+			Expect(r.Ratings.Histogram[7]).To(Equal(stats.Bucket{Min: 4.5, Max: 5, Count: 2}))
+		})
+
+		it("computes the review percentiles and the open now share", func() {
+			r := stats.Profile(locations)
+
+			Expect(r.Reviews.Reported).To(Equal(4))
+			Expect(r.Reviews.Total).To(Equal(2410))
+			Expect(r.Reviews.Percentiles).To(Equal(map[string]int{"p25": 10, "p50": 300, "p75": 900, "p90": 1200, "p99": 1200}))
+			Expect(r.Reviews.Max).To(Equal(1200))
+
+			Expect(r.OpenNow).To(Equal(stats.OpenNow{Open: 2, Closed: 2, Unknown: 1, Share: 0.5}))
+		})
+
+		it("profiles an empty result set", func() {
+			r := stats.Profile(nil)
+
+			Expect(r.Places).To(BeZero())
+			Expect(r.Countries).To(BeEmpty())
+			Expect(r.Reviews.Percentiles).To(BeEmpty())
+			Expect(r.OpenNow.Share).To(BeZero())
+
+			var buf bytes.Buffer
+			Expect(r.WriteText(&buf)).To(Succeed())
+			Expect(buf.String()).To(ContainSubstring("Open now: 0 open, 0 closed, 0 unknown (0% of the places that report it)"))
+		})
+	})
+
+	when("writing the report", func() {
+		it("renders text", func() {
+			var buf bytes.Buffer
+			Expect(stats.Profile(locations).Write(&buf, stats.FormatText)).To(Succeed())
+
+			Expect(buf.String()).To(HavePrefix("Places: 5\nCountries:\n"))
+			Expect(buf.String()).To(ContainSubstring("Ratings: 4 rated, 1 unrated, mean 3.75\n"))
+			Expect(buf.String()).To(ContainSubstring("  4.5-5.0     2 ##############################\n"))
+			Expect(buf.String()).To(ContainSubstring("Reviews: 4 reported, 2410 in total, p25 10, p50 300, p75 900, p90 1200, p99 1200, max 1200\n"))
+			Expect(buf.String()).To(HaveSuffix("Open now: 2 open, 2 closed, 1 unknown (50% of the places that report it)\n"))
+		})
+
+		it("renders JSON", func() {
+			var buf bytes.Buffer
+			Expect(stats.Profile(locations).Write(&buf, stats.FormatJSON)).To(Succeed())
+
+			var decoded stats.Report
+			Expect(json.Unmarshal(buf.Bytes(), &decoded)).To(Succeed())
+			Expect(decoded).To(Equal(stats.Profile(locations)))
+		})
+
+		it("validates the format", func() {
+			_, err := stats.ParseFormat("yaml")
+			Expect(err).To(MatchError(ContainSubstring("invalid stats format")))
+		})
+	})
+}
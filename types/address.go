@@ -0,0 +1,12 @@
+package types
+
+// Address is a formatted address split into its parts. Confidence, between 0 and 1, is how sure the parser is that
+// it read the address correctly; parts it could not find are empty.
+type Address struct {
+	Street     string  `json:"street,omitempty"`
+	City       string  `json:"city,omitempty"`
+	Region     string  `json:"region,omitempty"`
+	PostalCode string  `json:"postal_code,omitempty"`
+	Country    string  `json:"country,omitempty"`
+	Confidence float64 `json:"confidence"`
+}
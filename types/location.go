@@ -0,0 +1,347 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ModelVersion is the version of the Location model. It changes whenever the JSON form of a location changes in a way
+// older readers would misinterpret. Version 2 omits the rating, the number of ratings and the price level when the
+// Places API did not return them, where version 1 wrote zeros.
+const ModelVersion = 2
+
+// BusinessStatus is the operational status of a place. It is empty when the Places API did not return one.
+type BusinessStatus string
+
+const (
+	StatusOperational       BusinessStatus = "OPERATIONAL"
+	StatusClosedTemporarily BusinessStatus = "CLOSED_TEMPORARILY"
+	StatusClosedPermanently BusinessStatus = "CLOSED_PERMANENTLY"
+)
+
+// BusinessStatuses lists the statuses the Places API returns
+var BusinessStatuses = []BusinessStatus{StatusOperational, StatusClosedTemporarily, StatusClosedPermanently}
+
+// PlaceType is a category of a place, such as grocery_or_supermarket. The constants cover the types the searches of
+// this tool run into most; the Places API may return others, which are kept as is.
+type PlaceType string
+
+const (
+	TypeATM                  PlaceType = "atm"
+	TypeBakery               PlaceType = "bakery"
+	TypeBank                 PlaceType = "bank"
+	TypeBar                  PlaceType = "bar"
+	TypeCafe                 PlaceType = "cafe"
+	TypeClothingStore        PlaceType = "clothing_store"
+	TypeConvenienceStore     PlaceType = "convenience_store"
+	TypeDepartmentStore      PlaceType = "department_store"
+	TypeDrugstore            PlaceType = "drugstore"
+	TypeEstablishment        PlaceType = "establishment"
+	TypeFood                 PlaceType = "food"
+	TypeGasStation           PlaceType = "gas_station"
+	TypeGroceryOrSupermarket PlaceType = "grocery_or_supermarket"
+	TypeHealth               PlaceType = "health"
+	TypeHomeGoodsStore       PlaceType = "home_goods_store"
+	TypeLiquorStore          PlaceType = "liquor_store"
+	TypeLodging              PlaceType = "lodging"
+	TypeMealTakeaway         PlaceType = "meal_takeaway"
+	TypeParking              PlaceType = "parking"
+	TypePharmacy             PlaceType = "pharmacy"
+	TypePointOfInterest      PlaceType = "point_of_interest"
+	TypeRestaurant           PlaceType = "restaurant"
+	TypeShoppingMall         PlaceType = "shopping_mall"
+	TypeStore                PlaceType = "store"
+	TypeSupermarket          PlaceType = "supermarket"
+)
+
+// PlaceTypes are the categories of a place, the most specific first
+type PlaceTypes []PlaceType
+
+// Has reports whether the place has the type
+func (p PlaceTypes) Has(placeType PlaceType) bool {
+	for _, t := range p {
+		if t == placeType {
+			return true
+		}
+	}
+	return false
+}
+
+// Strings returns the types as plain strings
+func (p PlaceTypes) Strings() []string {
+	if p == nil {
+		return nil
+	}
+
+	result := make([]string, 0, len(p))
+	for _, t := range p {
+		result = append(result, string(t))
+	}
+	return result
+}
+
+// ParsePlaceTypes reverses Strings
+func ParsePlaceTypes(values []string) PlaceTypes {
+	if values == nil {
+		return nil
+	}
+
+	result := make(PlaceTypes, 0, len(values))
+	for _, value := range values {
+		result = append(result, PlaceType(strings.TrimSpace(value)))
+	}
+	return result
+}
+
+// OpeningHours holds the opening hours of a place. OpenNow is nil when the Places API does not say whether the place is
+// open, which is the case for places without opening hours.
+type OpeningHours struct {
+	OpenNow     *bool    `json:"open_now,omitempty"`
+	WeekdayText []string `json:"weekday_text,omitempty"`
+
+	// Extra holds the fields of the decoded JSON that are not part of the model, e.g. periods
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type Photo struct {
+	Height           int      `json:"height"`
+	HtmlAttributions []string `json:"html_attributions"`
+	PhotoReference   string   `json:"photo_reference"`
+	Width            int      `json:"width"`
+
+	// Extra holds the fields of the decoded JSON that are not part of the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type Geometry struct {
+	Location struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"location"`
+	Viewport struct {
+		Northeast struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"northeast"`
+		Southwest struct {
+			Lat float64 `json:"lat"`
+			Lng float64 `json:"lng"`
+		} `json:"southwest"`
+	} `json:"viewport"`
+
+	// Extra holds the fields of the decoded JSON that are not part of the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type PlusCode struct {
+	CompoundCode string `json:"compound_code"`
+	GlobalCode   string `json:"global_code"`
+
+	// Extra holds the fields of the decoded JSON that are not part of the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Location is a place as returned by the Text Search, Nearby Search and Place Details APIs. Numbers the API may leave
+// out are pointers, so a missing rating is not mistaken for a rating of zero. Fields the model does not know are kept
+// in Extra, as they are in the opening hours, photos, geometry and plus code, and written back when the location is
+// encoded, so no upstream data is lost on a round-trip.
+type Location struct {
+	BusinessStatus      BusinessStatus `json:"business_status"`
+	FormattedAddress    string         `json:"formatted_address"`
+	Geometry            Geometry       `json:"geometry"`
+	Icon                string         `json:"icon"`
+	IconBackgroundColor string         `json:"icon_background_color"`
+	IconMaskBaseUri     string         `json:"icon_mask_base_uri"`
+	Name                string         `json:"name"`
+	OpeningHours        OpeningHours   `json:"opening_hours"`
+	PermanentlyClosed   bool           `json:"permanently_closed,omitempty"`
+	Photos              []Photo        `json:"photos"`
+	PlaceId             string         `json:"place_id"`
+	PlusCode            PlusCode       `json:"plus_code"`
+	PriceLevel          *int           `json:"price_level,omitempty"`
+	Rating              *float64       `json:"rating,omitempty"`
+	Reference           string         `json:"reference"`
+	Types               PlaceTypes     `json:"types"`
+	UserRatingsTotal    *int           `json:"user_ratings_total,omitempty"`
+	Vicinity            string         `json:"vicinity,omitempty"`
+	Relevance           *Verdict       `json:"relevance,omitempty"`
+	Aliases             []string       `json:"aliases,omitempty"`
+	Address             *Address       `json:"address,omitempty"`
+
+	// Provenance is left out of the JSON of a location, the output writers add it when asked to
+	Provenance *Provenance `json:"-"`
+
+	// Extra holds the fields of the decoded JSON that are not part of the model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// location has the fields of Location without its JSON methods, and so on for the nested types
+type (
+	location     Location
+	openingHours OpeningHours
+	photo        Photo
+	geometry     Geometry
+	plusCode     PlusCode
+)
+
+// the JSON names of the fields of every type that keeps unknown fields
+var (
+	locationFields     = jsonFields(reflect.TypeOf(location{}))
+	openingHoursFields = jsonFields(reflect.TypeOf(openingHours{}))
+	photoFields        = jsonFields(reflect.TypeOf(photo{}))
+	geometryFields     = jsonFields(reflect.TypeOf(geometry{}))
+	plusCodeFields     = jsonFields(reflect.TypeOf(plusCode{}))
+)
+
+// UnmarshalJSON decodes the known fields and keeps the others, compacted, in Extra
+func (l *Location) UnmarshalJSON(data []byte) error {
+	var decoded location
+	err := decodeWithExtra(data, &decoded, &decoded.Extra, locationFields)
+	*l = Location(decoded)
+	return err
+}
+
+// MarshalJSON encodes the known fields in their declaration order, followed by the fields in Extra sorted by name
+func (l Location) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(location(l), l.Extra, locationFields)
+}
+
+func (o *OpeningHours) UnmarshalJSON(data []byte) error {
+	var decoded openingHours
+	err := decodeWithExtra(data, &decoded, &decoded.Extra, openingHoursFields)
+	*o = OpeningHours(decoded)
+	return err
+}
+
+func (o OpeningHours) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(openingHours(o), o.Extra, openingHoursFields)
+}
+
+func (p *Photo) UnmarshalJSON(data []byte) error {
+	var decoded photo
+	err := decodeWithExtra(data, &decoded, &decoded.Extra, photoFields)
+	*p = Photo(decoded)
+	return err
+}
+
+func (p Photo) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(photo(p), p.Extra, photoFields)
+}
+
+func (g *Geometry) UnmarshalJSON(data []byte) error {
+	var decoded geometry
+	err := decodeWithExtra(data, &decoded, &decoded.Extra, geometryFields)
+	*g = Geometry(decoded)
+	return err
+}
+
+func (g Geometry) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(geometry(g), g.Extra, geometryFields)
+}
+
+func (p *PlusCode) UnmarshalJSON(data []byte) error {
+	var decoded plusCode
+	err := decodeWithExtra(data, &decoded, &decoded.Extra, plusCodeFields)
+	*p = PlusCode(decoded)
+	return err
+}
+
+func (p PlusCode) MarshalJSON() ([]byte, error) {
+	return encodeWithExtra(plusCode(p), p.Extra, plusCodeFields)
+}
+
+// jsonFields returns the JSON names of the fields of the struct type
+func jsonFields(t reflect.Type) map[string]bool {
+	result := make(map[string]bool)
+
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			result[name] = true
+		}
+	}
+
+	return result
+}
+
+// decodeWithExtra decodes the JSON object into v and the fields that are not known, compacted, into extra
+func decodeWithExtra(data []byte, v interface{}, extra *map[string]json.RawMessage, known map[string]bool) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	for name, value := range raw {
+		if known[name] {
+			continue
+		}
+		if *extra == nil {
+			*extra = make(map[string]json.RawMessage)
+		}
+
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, value); err != nil {
+			return err
+		}
+		(*extra)[name] = compact.Bytes()
+	}
+
+	return nil
+}
+
+// encodeWithExtra encodes v followed by the fields of extra that are not known, sorted by name
+func encodeWithExtra(v interface{}, extra map[string]json.RawMessage, known map[string]bool) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var names []string
+	for name := range extra {
+		if !known[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+
+	for i, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 || len(data) > 2 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(extra[name])
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Ptr returns a pointer to a copy of v, e.g. to set an optional field
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// Value returns what p points to, or the zero value when p is nil
+func Value[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+	return *p
+}
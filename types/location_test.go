@@ -0,0 +1,139 @@
+package types_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kardolus/maps/types"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitLocation(t *testing.T) {
+	spec.Run(t, "Location Unit Tests", testLocation, spec.Report(report.Terminal{}))
+}
+
+func testLocation(t *testing.T, when spec.G, it spec.S) {
+	const record = `{
+		"business_status": "OPERATIONAL",
+		"formatted_address": "1555 W Lane Ave, Columbus, OH 43221, United States",
+		"geometry": {"location": {"lat": 39.9995, "lng": -83.0458}},
+		"name": "Whole Foods Market",
+		"opening_hours": {"open_now": true, "weekday_text": ["Monday: 7:00 AM – 10:00 PM"]},
+		"place_id": "ChIJ-columbus",
+		"price_level": 0,
+		"types": ["grocery_or_supermarket", "health", "store"],
+		"vicinity": "1555 West Lane Avenue, Columbus",
+		"curbside_pickup": true,
+		"editorial_summary": {"overview": "Organic groceries"}
+	}`
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("decodes the typed fields", func() {
+		var location types.Location
+		Expect(json.Unmarshal([]byte(record), &location)).To(Succeed())
+
+		Expect(location.BusinessStatus).To(Equal(types.StatusOperational))
+		Expect(location.Types.Has(types.TypeGroceryOrSupermarket)).To(BeTrue())
+		Expect(location.Types.Has(types.TypeCafe)).To(BeFalse())
+		Expect(location.Types.Strings()).To(Equal([]string{"grocery_or_supermarket", "health", "store"}))
+		Expect(location.OpeningHours.WeekdayText).To(Equal([]string{"Monday: 7:00 AM – 10:00 PM"}))
+		Expect(location.Vicinity).To(Equal("1555 West Lane Avenue, Columbus"))
+	})
+
+	it("tells missing numbers from zeros", func() {
+		var location types.Location
+		Expect(json.Unmarshal([]byte(record), &location)).To(Succeed())
+
+		Expect(location.PriceLevel).To(Equal(types.Ptr(0)))
+		Expect(location.Rating).To(BeNil())
+		Expect(location.UserRatingsTotal).To(BeNil())
+		Expect(types.Value(location.Rating)).To(BeZero())
+
+		data, err := json.Marshal(location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"price_level":0`))
+		Expect(string(data)).NotTo(ContainSubstring(`"rating"`))
+		Expect(string(data)).NotTo(ContainSubstring(`"user_ratings_total"`))
+	})
+
+	it("keeps the nested fields it does not know on a round-trip", func() {
+		const nested = `{
+			"geometry": {"location": {"lat": 39.9995, "lng": -83.0458}, "location_type": "ROOFTOP"},
+			"opening_hours": {"open_now": false, "periods": [{"open": {"day": 1, "time": "0700"}}]},
+			"photos": [{"height": 1, "photo_reference": "ref", "width": 2, "author": "someone"}],
+			"plus_code": {"global_code": "86FVX2X4+Q3", "locality": "Columbus"}
+		}`
+
+		var location types.Location
+		Expect(json.Unmarshal([]byte(nested), &location)).To(Succeed())
+		Expect(location.OpeningHours.OpenNow).To(Equal(types.Ptr(false)))
+		Expect(location.Photos[0].PhotoReference).To(Equal("ref"))
+		Expect(location.PlusCode.GlobalCode).To(Equal("86FVX2X4+Q3"))
+		Expect(location.Extra).To(BeNil())
+
+		data, err := json.Marshal(location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"location_type":"ROOFTOP"}`))
+		Expect(string(data)).To(ContainSubstring(`"opening_hours":{"open_now":false,"periods":[{"open":{"day":1,"time":"0700"}}]}`))
+		Expect(string(data)).To(ContainSubstring(`"author":"someone"}]`))
+		Expect(string(data)).To(ContainSubstring(`"locality":"Columbus"}`))
+
+		var decoded types.Location
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(location))
+	})
+
+	it("writes the unknown fields of an otherwise empty object", func() {
+		var hours types.OpeningHours
+		Expect(json.Unmarshal([]byte(`{"periods": []}`), &hours)).To(Succeed())
+
+		data, err := json.Marshal(hours)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(`{"periods":[]}`))
+	})
+
+	it("tells an unknown open_now from a closed place", func() {
+		var location types.Location
+		Expect(json.Unmarshal([]byte(record), &location)).To(Succeed())
+		Expect(location.OpeningHours.OpenNow).To(Equal(types.Ptr(true)))
+
+		Expect(json.Unmarshal([]byte(`{"opening_hours": {"open_now": false}}`), &location)).To(Succeed())
+		Expect(location.OpeningHours.OpenNow).To(Equal(types.Ptr(false)))
+
+		location = types.Location{}
+		data, err := json.Marshal(location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring(`"open_now"`))
+	})
+
+	it("keeps the fields it does not know on a round-trip", func() {
+		var location types.Location
+		Expect(json.Unmarshal([]byte(record), &location)).To(Succeed())
+		Expect(location.Extra).To(HaveLen(2))
+
+		data, err := json.Marshal(location)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(HaveSuffix(`,"curbside_pickup":true,"editorial_summary":{"overview":"Organic groceries"}}`))
+
+		var decoded types.Location
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(location))
+	})
+
+	it("has no extra fields for records it fully knows", func() {
+		location := types.Location{Name: "Whole Foods Market", Rating: types.Ptr(4.6)}
+
+		data, err := json.Marshal(location)
+		Expect(err).NotTo(HaveOccurred())
+
+		var decoded types.Location
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded.Extra).To(BeNil())
+		Expect(decoded).To(Equal(location))
+	})
+}
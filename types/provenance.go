@@ -0,0 +1,13 @@
+package types
+
+import "time"
+
+// Provenance records where a location came from: the sub-query and page of the search that returned it, when the page
+// was fetched and which name rule kept it. It is only recorded when asked for, see client.Client.WithProvenance.
+type Provenance struct {
+	Query     string    `json:"query"`
+	Page      int       `json:"page"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Provider  string    `json:"provider"`
+	Rule      string    `json:"rule"`
+}
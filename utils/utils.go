@@ -1,32 +1,62 @@
 package utils
 
 import (
+	"errors"
+	"io/fs"
 	"os"
-	"path"
 	"path/filepath"
-	"runtime"
-	"strings"
+
+	"github.com/kardolus/maps/resources"
 )
 
 type Utils struct {
+	promptDir string
+	overrides map[string]string
+}
+
+func New() *Utils {
+	return &Utils{
+		overrides: make(map[string]string),
+	}
 }
 
+// WithPromptDir configures a directory that is searched for resources before falling back to the embedded defaults
+func (u *Utils) WithPromptDir(dir string) *Utils {
+	u.promptDir = dir
+	return u
+}
+
+// WithOverride replaces a single resource with a user supplied file. An empty path is ignored.
+func (u *Utils) WithOverride(fileName, path string) *Utils {
+	if path == "" {
+		return u
+	}
+
+	if u.overrides == nil {
+		u.overrides = make(map[string]string)
+	}
+
+	u.overrides[fileName] = path
+	return u
+}
+
+// FileToBytes resolves a resource by name. Explicit overrides win, followed by the prompt directory and finally the
+// copy embedded in the binary.
 func (u *Utils) FileToBytes(fileName string) ([]byte, error) {
-	_, thisFile, _, _ := runtime.Caller(0)
-
-	var (
-		urlPath string
-		err     error
-	)
-	if strings.Contains(thisFile, "vendor") {
-		urlPath, err = filepath.Abs(path.Join(thisFile, "../../../../../..", "resources", fileName))
-	} else {
-		urlPath, err = filepath.Abs(path.Join(thisFile, "../..", "resources", fileName))
+	if path, ok := u.overrides[fileName]; ok {
+		return os.ReadFile(path)
 	}
 
-	if err != nil {
-		return nil, err
+	if u.promptDir != "" {
+		data, err := os.ReadFile(filepath.Join(u.promptDir, fileName))
+		if err == nil {
+			return data, nil
+		}
+
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
 	}
 
-	return os.ReadFile(urlPath)
+	return resources.ReadFile(fileName)
 }
@@ -0,0 +1,67 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kardolus/maps/resources"
+	"github.com/kardolus/maps/utils"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+const promptFile = "query_prompt.txt"
+
+func TestUnitUtils(t *testing.T) {
+	spec.Run(t, "Utils Package Unit Tests", testUtils, spec.Report(report.Terminal{}))
+}
+
+func testUtils(t *testing.T, when spec.G, it spec.S) {
+	var dir string
+
+	it.Before(func() {
+		RegisterTestingT(t)
+		dir = t.TempDir()
+	})
+
+	when("FileToBytes()", func() {
+		it("falls back to the embedded resource", func() {
+			expected, err := resources.ReadFile(promptFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			result, err := utils.New().FileToBytes(promptFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(expected))
+		})
+
+		it("prefers a file in the prompt directory", func() {
+			Expect(os.WriteFile(filepath.Join(dir, promptFile), []byte("from dir"), 0644)).To(Succeed())
+
+			result, err := utils.New().WithPromptDir(dir).FileToBytes(promptFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(result)).To(Equal("from dir"))
+		})
+
+		it("prefers an explicit override over the prompt directory", func() {
+			override := filepath.Join(dir, "custom.txt")
+			Expect(os.WriteFile(filepath.Join(dir, promptFile), []byte("from dir"), 0644)).To(Succeed())
+			Expect(os.WriteFile(override, []byte("from override"), 0644)).To(Succeed())
+
+			result, err := utils.New().WithPromptDir(dir).WithOverride(promptFile, override).FileToBytes(promptFile)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(result)).To(Equal("from override"))
+		})
+
+		it("returns an error when an override does not exist", func() {
+			_, err := utils.New().WithOverride(promptFile, filepath.Join(dir, "missing.txt")).FileToBytes(promptFile)
+			Expect(err).To(HaveOccurred())
+		})
+
+		it("returns an error for an unknown resource", func() {
+			_, err := utils.New().WithPromptDir(dir).FileToBytes("unknown.txt")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+}
@@ -0,0 +1,190 @@
+// Package watch stores the snapshots of a search that is re-run on a schedule and turns the differences between two
+// snapshots into change events, which are written to a stream or posted to a webhook.
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/types"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	EventNewPlace     = "new_place"
+	EventClosedPlace  = "closed_place"
+	EventStatusChange = "status_change"
+)
+
+// Snapshot is the result of a single run of a watched search. The version is the types.ModelVersion of the locations;
+// stores written before locations were versioned have none.
+type Snapshot struct {
+	Version   int              `json:"version,omitempty"`
+	Query     string           `json:"query"`
+	TakenAt   time.Time        `json:"taken_at"`
+	Locations []types.Location `json:"locations"`
+}
+
+// Event is a change between two snapshots. A closed place is either reported as permanently closed or no longer
+// returned by the search, in which case the old status is empty.
+type Event struct {
+	Type       string         `json:"type"`
+	Query      string         `json:"query"`
+	DetectedAt time.Time      `json:"detected_at"`
+	OldStatus  string         `json:"old_status,omitempty"`
+	NewStatus  string         `json:"new_status,omitempty"`
+	Place      types.Location `json:"place"`
+}
+
+// Events turns a diff report into events. Changes other than the business status, like a new rating, are not events.
+func Events(query string, at time.Time, report diff.Report) []Event {
+	var result []Event
+
+	for _, location := range report.Added {
+		result = append(result, Event{Type: EventNewPlace, Query: query, DetectedAt: at, Place: location})
+	}
+
+	for _, location := range report.Removed {
+		result = append(result, Event{Type: EventClosedPlace, Query: query, DetectedAt: at, Place: location})
+	}
+
+	for _, modified := range report.Changed {
+		for _, change := range modified.Changes {
+			if change.Field != "business_status" {
+				continue
+			}
+
+			event := Event{
+				Type:       EventStatusChange,
+				Query:      query,
+				DetectedAt: at,
+				OldStatus:  change.Old,
+				NewStatus:  change.New,
+				Place:      modified.New,
+			}
+			if types.BusinessStatus(change.New) == types.StatusClosedPermanently {
+				event.Type = EventClosedPlace
+			}
+
+			result = append(result, event)
+		}
+	}
+
+	return result
+}
+
+// FileStore keeps the last snapshot in a JSON file
+type FileStore struct {
+	path string
+}
+
+// OpenStore returns the store for the path. Only JSON files are supported; SQLite databases are rejected rather than
+// overwritten with JSON.
+func OpenStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, errors.New("missing store, pass the file that keeps the snapshot via --store")
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sqlite", ".sqlite3", ".db":
+		return nil, fmt.Errorf("unsupported store %s, snapshots are stored in a JSON file such as results.json", path)
+	}
+
+	return &FileStore{path: path}, nil
+}
+
+// Load returns the last snapshot, or nil when there is none yet
+func (f *FileStore) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to read the snapshot from %s: %w", f.path, err)
+	}
+
+	if snapshot.Version > types.ModelVersion {
+		return nil, fmt.Errorf("the snapshot in %s was written by a newer version of maps (model version %d), upgrade to read it", f.path, snapshot.Version)
+	}
+
+	return &snapshot, nil
+}
+
+// Save replaces the last snapshot, stamped with the current model version. The file is written next to the store and
+// renamed, so a crash never leaves a partial snapshot behind.
+func (f *FileStore) Save(snapshot Snapshot) error {
+	snapshot.Version = types.ModelVersion
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, f.path)
+}
+
+// Stream writes every event as a line of JSON
+type Stream struct {
+	out io.Writer
+}
+
+func NewStream(out io.Writer) *Stream {
+	return &Stream{out: out}
+}
+
+func (s *Stream) Send(events []Event) error {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintln(s.out, string(data)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type Poster interface {
+	Post(url string, body []byte) ([]byte, error)
+}
+
+// Webhook posts the events of a run as a JSON array to a URL
+type Webhook struct {
+	url    string
+	poster Poster
+}
+
+func NewWebhook(url string, poster Poster) *Webhook {
+	return &Webhook{url: url, poster: poster}
+}
+
+func (w *Webhook) Send(events []Event) error {
+	data, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.poster.Post(w.url, data); err != nil {
+		return fmt.Errorf("failed to post events to %s: %w", w.url, err)
+	}
+
+	return nil
+}
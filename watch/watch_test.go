@@ -0,0 +1,138 @@
+package watch_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kardolus/maps/diff"
+	"github.com/kardolus/maps/types"
+	"github.com/kardolus/maps/watch"
+	. "github.com/onsi/gomega"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+)
+
+func TestUnitWatch(t *testing.T) {
+	spec.Run(t, "Watch Unit Tests", testWatch, spec.Report(report.Terminal{}))
+}
+
+type fakePoster struct {
+	url  string
+	body []byte
+	err  error
+}
+
+func (f *fakePoster) Post(url string, body []byte) ([]byte, error) {
+	f.url, f.body = url, body
+	return nil, f.err
+}
+
+func testWatch(t *testing.T, when spec.G, it spec.S) {
+	var (
+		now      = time.Date(2024, 5, 14, 16, 0, 0, 0, time.UTC)
+		columbus = types.Location{PlaceId: "a", Name: "Whole Foods Market", BusinessStatus: "OPERATIONAL"}
+		events   = []watch.Event{{Type: watch.EventNewPlace, Query: "Whole Foods in Ohio", DetectedAt: now, Place: columbus}}
+	)
+
+	it.Before(func() {
+		RegisterTestingT(t)
+	})
+
+	it("reports a permanently closed place as closed", func() {
+		closed := columbus
+		closed.BusinessStatus = "CLOSED_PERMANENTLY"
+
+		result := watch.Events("Whole Foods in Ohio", now, diff.New().Compare([]types.Location{columbus}, []types.Location{closed}))
+
+		Expect(result).To(Equal([]watch.Event{{
+			Type:       watch.EventClosedPlace,
+			Query:      "Whole Foods in Ohio",
+			DetectedAt: now,
+			OldStatus:  "OPERATIONAL",
+			NewStatus:  "CLOSED_PERMANENTLY",
+			Place:      closed,
+		}}))
+	})
+
+	it("ignores changes other than the business status", func() {
+		rated := columbus
+		rated.Rating = types.Ptr(4.2)
+
+		Expect(watch.Events("", now, diff.New().Compare([]types.Location{columbus}, []types.Location{rated}))).To(BeEmpty())
+	})
+
+	when("storing snapshots", func() {
+		it("returns no snapshot before the first save", func() {
+			store, err := watch.OpenStore(filepath.Join(t.TempDir(), "snapshot.json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			snapshot, err := store.Load()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(snapshot).To(BeNil())
+		})
+
+		it("loads the saved snapshot", func() {
+			store, err := watch.OpenStore(filepath.Join(t.TempDir(), "snapshot.json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			saved := watch.Snapshot{Query: "Whole Foods in Ohio", TakenAt: now, Locations: []types.Location{columbus}}
+			Expect(store.Save(saved)).To(Succeed())
+
+			snapshot, err := store.Load()
+			Expect(err).NotTo(HaveOccurred())
+
+			saved.Version = types.ModelVersion
+			Expect(*snapshot).To(Equal(saved))
+		})
+
+		it("refuses snapshots of a newer model version", func() {
+			path := filepath.Join(t.TempDir(), "snapshot.json")
+			Expect(os.WriteFile(path, []byte(`{"version": 99, "query": "Whole Foods in Ohio"}`), 0644)).To(Succeed())
+
+			store, err := watch.OpenStore(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = store.Load()
+			Expect(err).To(MatchError(ContainSubstring("written by a newer version of maps")))
+		})
+
+		it("does not support SQLite", func() {
+			for _, path := range []string{"results.sqlite", "results.SQLITE3", "results.db"} {
+				_, err := watch.OpenStore(path)
+				Expect(err).To(MatchError(ContainSubstring("unsupported store " + path)))
+			}
+		})
+
+		it("requires a path", func() {
+			_, err := watch.OpenStore("")
+			Expect(err).To(MatchError(ContainSubstring("missing store")))
+		})
+	})
+
+	it("writes one event per line", func() {
+		var buf bytes.Buffer
+		Expect(watch.NewStream(&buf).Send(append(events, events...))).To(Succeed())
+
+		Expect(bytes.Count(buf.Bytes(), []byte("\n"))).To(Equal(2))
+		Expect(buf.String()).To(HavePrefix(`{"type":"new_place","query":"Whole Foods in Ohio","detected_at":"2024-05-14T16:00:00Z",`))
+	})
+
+	it("posts the events to the webhook", func() {
+		poster := &fakePoster{}
+		Expect(watch.NewWebhook("https://example.com/hook", poster).Send(events)).To(Succeed())
+
+		var posted []watch.Event
+		Expect(json.Unmarshal(poster.body, &posted)).To(Succeed())
+		Expect(poster.url).To(Equal("https://example.com/hook"))
+		Expect(posted).To(Equal(events))
+
+		poster.err = errors.New("http status 500")
+		Expect(watch.NewWebhook("https://example.com/hook", poster).Send(events)).
+			To(MatchError("failed to post events to https://example.com/hook: http status 500"))
+	})
+}